@@ -0,0 +1,456 @@
+package apu
+
+import (
+	"math"
+	"sync"
+)
+
+const cyclesPerSecond = 4194304
+
+// The frame sequencer runs at 512Hz
+const cyclesPerFrameSequencerStep = cyclesPerSecond / 512
+
+const DefaultSampleRate = 44100
+
+// Keep at most one second of stereo samples if the frontend isn't reading
+// them fast enough
+const maxBufferedSeconds = 1
+
+const registersStart = 0xFF10
+const registersEnd = 0xFF3F
+const waveRAMStart = 0xFF30
+
+const (
+	nr10 = 0xFF10
+	nr11 = 0xFF11
+	nr12 = 0xFF12
+	nr13 = 0xFF13
+	nr14 = 0xFF14
+	nr21 = 0xFF16
+	nr22 = 0xFF17
+	nr23 = 0xFF18
+	nr24 = 0xFF19
+	nr30 = 0xFF1A
+	nr31 = 0xFF1B
+	nr32 = 0xFF1C
+	nr33 = 0xFF1D
+	nr34 = 0xFF1E
+	nr41 = 0xFF20
+	nr42 = 0xFF21
+	nr43 = 0xFF22
+	nr44 = 0xFF23
+	nr50 = 0xFF24
+	nr51 = 0xFF25
+	nr52 = 0xFF26
+)
+
+// Bits that always read back as 1 for each register from NR10 to 0xFF2F
+var readMasks = [0x20]uint8{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF, // NR20-NR24
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+	0xFF, 0xFF, 0x00, 0x00, 0xBF, // NR40-NR44
+	0x00, 0x00, 0x70, // NR50-NR52
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // Unused
+}
+
+type Apu struct {
+	square1 *squareChannel
+	square2 *squareChannel
+	wave    *waveChannel
+	noise   *noiseChannel
+
+	registers [0x20]uint8
+	powered   bool
+
+	frameSequencerCounter uint
+	frameStep             uint8
+
+	sampleRate    int
+	sampleCounter int
+	capacitorL    float64
+	capacitorR    float64
+	charge        float64
+
+	samples     []int16
+	samplesLock sync.Mutex
+	muted       bool
+}
+
+func CreateAPU() *Apu {
+	a := &Apu{
+		square1: createSquareChannel(true),
+		square2: createSquareChannel(false),
+		wave:    createWaveChannel(),
+		noise:   createNoiseChannel(),
+		samples: make([]int16, 0),
+	}
+	a.SetSampleRate(DefaultSampleRate)
+
+	return a
+}
+
+func (a *Apu) Reset() {
+	a.square1.reset()
+	a.square2.reset()
+	a.wave.reset()
+	a.noise.reset()
+
+	for x := range a.registers {
+		a.registers[x] = 0x00
+	}
+
+	// The boot ROM expects to be able to write to the registers before it
+	// powers on the APU so start powered
+	a.powered = true
+	a.frameSequencerCounter = 0
+	a.frameStep = 0
+	a.sampleCounter = 0
+	a.capacitorL = 0
+	a.capacitorR = 0
+
+	a.samplesLock.Lock()
+	a.samples = a.samples[:0]
+	a.samplesLock.Unlock()
+}
+
+// Sets the rate in Hz that stereo samples are produced at for the host
+func (a *Apu) SetSampleRate(rate int) {
+	if rate <= 0 {
+		rate = DefaultSampleRate
+	}
+
+	a.sampleRate = rate
+	a.sampleCounter = 0
+	// High pass filter to remove the DC offset the same way the hardware
+	// capacitor does
+	a.charge = math.Pow(0.999958, float64(cyclesPerSecond)/float64(rate))
+}
+
+func (a *Apu) SampleRate() int {
+	return a.sampleRate
+}
+
+// Copies any produced samples into the buffer as interleaved left and right
+// values and returns the number of values copied
+func (a *Apu) ReadSamples(buffer []int16) int {
+	a.samplesLock.Lock()
+	defer a.samplesLock.Unlock()
+
+	count := copy(buffer, a.samples)
+	a.samples = a.samples[:copy(a.samples, a.samples[count:])]
+
+	return count
+}
+
+// While muted the channels keep running but the samples waiting for the host
+// are left alone, for when the system runs frames the host has already heard
+func (a *Apu) SetMuted(muted bool) {
+	a.muted = muted
+}
+
+func (a *Apu) UpdateForCycles(cycles uint) {
+	for x := uint(0); x < cycles; x++ {
+		a.frameSequencerCounter++
+		if a.frameSequencerCounter >= cyclesPerFrameSequencerStep {
+			a.frameSequencerCounter = 0
+			a.clockFrameSequencer()
+		}
+
+		if a.powered {
+			a.square1.step()
+			a.square2.step()
+			a.wave.step()
+			a.noise.step()
+		}
+
+		a.sampleCounter += a.sampleRate
+		if a.sampleCounter >= cyclesPerSecond {
+			a.sampleCounter -= cyclesPerSecond
+			a.outputSample()
+		}
+	}
+}
+
+func (a *Apu) clockFrameSequencer() {
+	if !a.powered {
+		return
+	}
+
+	switch a.frameStep {
+	case 0, 4:
+		a.clockLengths()
+	case 2, 6:
+		a.clockLengths()
+		a.square1.clockSweep()
+	case 7:
+		a.square1.clockEnvelope()
+		a.square2.clockEnvelope()
+		a.noise.clockEnvelope()
+	}
+
+	a.frameStep = (a.frameStep + 1) % 8
+}
+
+func (a *Apu) clockLengths() {
+	a.square1.clockLength()
+	a.square2.clockLength()
+	a.wave.clockLength()
+	a.noise.clockLength()
+}
+
+func (a *Apu) outputSample() {
+	var left float64 = 0
+	var right float64 = 0
+	panning := a.registers[nr51-registersStart]
+
+	channels := [4]struct {
+		output uint8
+		dac    bool
+	}{
+		{a.square1.output(), a.square1.dacEnabled()},
+		{a.square2.output(), a.square2.dacEnabled()},
+		{a.wave.output(), a.wave.dacEnabled()},
+		{a.noise.output(), a.noise.dacEnabled()},
+	}
+
+	for x, channel := range channels {
+		if !a.powered || !channel.dac {
+			continue
+		}
+
+		analog := (float64(channel.output) / 7.5) - 1.0
+
+		if panning&(0x10<<x) != 0 {
+			left += analog
+		}
+		if panning&(0x01<<x) != 0 {
+			right += analog
+		}
+	}
+
+	volume := a.registers[nr50-registersStart]
+	left = left / 4 * float64(((volume>>4)&0x07)+1) / 8
+	right = right / 4 * float64((volume&0x07)+1) / 8
+
+	left, a.capacitorL = highPass(left, a.capacitorL, a.charge)
+	right, a.capacitorR = highPass(right, a.capacitorR, a.charge)
+
+	if a.muted {
+		return
+	}
+
+	a.samplesLock.Lock()
+	defer a.samplesLock.Unlock()
+
+	if len(a.samples) >= a.sampleRate*2*maxBufferedSeconds {
+		a.samples = a.samples[:copy(a.samples, a.samples[2:])]
+	}
+
+	a.samples = append(a.samples, toSample(left), toSample(right))
+}
+
+func highPass(in float64, capacitor float64, charge float64) (out float64, newCapacitor float64) {
+	out = in - capacitor
+	newCapacitor = in - out*charge
+	return out, newCapacitor
+}
+
+func toSample(value float64) int16 {
+	value = math.Max(-1, math.Min(1, value))
+	return int16(value * math.MaxInt16)
+}
+
+func (a *Apu) ReadBit(address uint16, bit uint8) bool {
+	return (a.ReadByte(address)>>bit)&0x01 == 0x01
+}
+
+func (a *Apu) ReadByte(address uint16) byte {
+	if address >= waveRAMStart {
+		return a.wave.readRAM(uint8(address - waveRAMStart))
+	}
+
+	index := address - registersStart
+
+	if address == nr52 {
+		value := readMasks[index]
+		if a.powered {
+			value |= 0x80
+		}
+		if a.square1.enabled {
+			value |= 0x01
+		}
+		if a.square2.enabled {
+			value |= 0x02
+		}
+		if a.wave.enabled {
+			value |= 0x04
+		}
+		if a.noise.enabled {
+			value |= 0x08
+		}
+		return value
+	}
+
+	return a.registers[index] | readMasks[index]
+}
+
+func (a *Apu) ReadShort(address uint16) uint16 {
+	lsb := a.ReadByte(address)
+	msb := a.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (a *Apu) WriteBit(address uint16, bit uint8, value bool) {
+	current := a.ReadByte(address)
+	if value {
+		current = current | 0x01<<bit
+	} else {
+		current = current &^ (0x01 << bit)
+	}
+	a.WriteByte(address, current)
+}
+
+func (a *Apu) WriteShort(address uint16, value uint16) {
+	a.WriteByte(address, uint8(value))
+	a.WriteByte(address+1, uint8(value>>8))
+}
+
+func (a *Apu) WriteByte(address uint16, value byte) {
+	if address >= waveRAMStart {
+		a.wave.writeRAM(uint8(address-waveRAMStart), value)
+		return
+	}
+
+	if address == nr52 {
+		a.writePower(value&0x80 == 0x80)
+		return
+	}
+
+	// When powered off only the length counters can be written to on DMG
+	if !a.powered {
+		switch address {
+		case nr11:
+			a.square1.length.load(uint16(value & 0x3F))
+		case nr21:
+			a.square2.length.load(uint16(value & 0x3F))
+		case nr31:
+			a.wave.writeLength(value)
+		case nr41:
+			a.noise.writeLength(value)
+		}
+		return
+	}
+
+	a.registers[address-registersStart] = value
+
+	switch address {
+	case nr10:
+		a.square1.writeSweep(value)
+	case nr11:
+		a.square1.writeLengthDuty(value)
+	case nr12:
+		a.square1.writeEnvelope(value)
+	case nr13:
+		a.square1.writeFrequencyLow(value)
+	case nr14:
+		a.square1.writeFrequencyHigh(value)
+		a.writeControl(&a.square1.length, &a.square1.enabled, value, a.square1.trigger)
+	case nr21:
+		a.square2.writeLengthDuty(value)
+	case nr22:
+		a.square2.writeEnvelope(value)
+	case nr23:
+		a.square2.writeFrequencyLow(value)
+	case nr24:
+		a.square2.writeFrequencyHigh(value)
+		a.writeControl(&a.square2.length, &a.square2.enabled, value, a.square2.trigger)
+	case nr30:
+		a.wave.writeDAC(value)
+	case nr31:
+		a.wave.writeLength(value)
+	case nr32:
+		a.wave.writeVolume(value)
+	case nr33:
+		a.wave.writeFrequencyLow(value)
+	case nr34:
+		a.wave.writeFrequencyHigh(value)
+		a.writeControl(&a.wave.length, &a.wave.enabled, value, a.wave.trigger)
+	case nr41:
+		a.noise.writeLength(value)
+	case nr42:
+		a.noise.writeEnvelope(value)
+	case nr43:
+		a.noise.writePolynomial(value)
+	case nr44:
+		a.writeControl(&a.noise.length, &a.noise.enabled, value, a.noise.trigger)
+	}
+}
+
+// Handles the length enable and trigger bits that are shared by all NRx4
+// registers
+func (a *Apu) writeControl(length *lengthCounter, enabled *bool, value uint8, trigger func()) {
+	wasEnabled := length.enabled
+	length.enabled = value&0x40 == 0x40
+	triggered := value&0x80 == 0x80
+
+	// If the next frame sequencer step doesn't clock the length counter then
+	// enabling the length counter clocks it an extra time
+	nextStepSkipsLength := a.frameStep%2 == 1
+
+	if nextStepSkipsLength && !wasEnabled && length.enabled && length.counter > 0 {
+		length.counter--
+
+		if length.counter == 0 && !triggered {
+			*enabled = false
+		}
+	}
+
+	if !triggered {
+		return
+	}
+
+	trigger()
+
+	if length.counter == 0 {
+		length.counter = length.max
+
+		if length.enabled && nextStepSkipsLength {
+			length.counter--
+		}
+	}
+}
+
+func (a *Apu) writePower(on bool) {
+	if on == a.powered {
+		return
+	}
+
+	if !on {
+		// Length counters are unaffected by power on DMG
+		lengths := [4]uint16{
+			a.square1.length.counter,
+			a.square2.length.counter,
+			a.wave.length.counter,
+			a.noise.length.counter,
+		}
+
+		a.square1.reset()
+		a.square2.reset()
+		a.wave.reset()
+		a.noise.reset()
+
+		a.square1.length.counter = lengths[0]
+		a.square2.length.counter = lengths[1]
+		a.wave.length.counter = lengths[2]
+		a.noise.length.counter = lengths[3]
+
+		for x := nr10 - registersStart; x < nr52-registersStart; x++ {
+			a.registers[x] = 0x00
+		}
+	} else {
+		a.frameStep = 0
+	}
+
+	a.powered = on
+}
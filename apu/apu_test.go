@@ -0,0 +1,95 @@
+package apu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterReadMasks(t *testing.T) {
+	a := CreateAPU()
+	a.Reset()
+
+	a.WriteByte(nr10, 0x00)
+	a.WriteByte(nr11, 0x00)
+	a.WriteByte(nr13, 0x00)
+
+	assert.Equal(t, uint8(0x80), a.ReadByte(nr10))
+	assert.Equal(t, uint8(0x3F), a.ReadByte(nr11))
+	assert.Equal(t, uint8(0xFF), a.ReadByte(nr13))
+	assert.Equal(t, uint8(0xFF), a.ReadByte(0xFF27))
+}
+
+func TestLengthCounterDisablesChannel(t *testing.T) {
+	a := CreateAPU()
+	a.Reset()
+
+	a.WriteByte(nr12, 0xF0)
+	a.WriteByte(nr11, 0x3E) // Length of 2
+	a.WriteByte(nr14, 0xC0) // Trigger with length enabled
+
+	assert.Equal(t, uint8(0xF1), a.ReadByte(nr52))
+
+	// Length is clocked every other frame sequencer step
+	a.UpdateForCycles(cyclesPerFrameSequencerStep * 4)
+
+	assert.Equal(t, uint8(0xF0), a.ReadByte(nr52))
+}
+
+func TestPowerOffClearsRegisters(t *testing.T) {
+	a := CreateAPU()
+	a.Reset()
+
+	a.WriteByte(nr50, 0x77)
+	a.WriteByte(nr52, 0x00)
+
+	assert.Equal(t, uint8(0x70), a.ReadByte(nr52))
+	assert.Equal(t, uint8(0x00), a.ReadByte(nr50))
+
+	// Writes are ignored while powered off
+	a.WriteByte(nr50, 0x77)
+	assert.Equal(t, uint8(0x00), a.ReadByte(nr50))
+}
+
+func TestSamplesProducedAtSampleRate(t *testing.T) {
+	a := CreateAPU()
+	a.Reset()
+	a.SetSampleRate(32768)
+
+	a.UpdateForCycles(cyclesPerSecond / 64)
+
+	buffer := make([]int16, 4096)
+	assert.Equal(t, (32768/64)*2, a.ReadSamples(buffer))
+	assert.Equal(t, 0, a.ReadSamples(buffer))
+}
+
+func TestMutedKeepsRunningWithoutSamples(t *testing.T) {
+	createPlaying := func(muted bool) *Apu {
+		a := CreateAPU()
+		a.Reset()
+		a.SetSampleRate(32768)
+		a.SetMuted(muted)
+
+		a.WriteByte(nr12, 0xF0)
+		a.WriteByte(nr11, 0x3E) // Length of 2
+		a.WriteByte(nr14, 0xC0) // Trigger with length enabled
+		a.UpdateForCycles(cyclesPerSecond / 64)
+		return a
+	}
+	playing := createPlaying(false)
+	muted := createPlaying(true)
+
+	buffer := make([]int16, 4096)
+	assert.Equal(t, 0, muted.ReadSamples(buffer))
+	assert.Equal(t, (32768/64)*2, playing.ReadSamples(buffer))
+
+	assert.Equal(t, playing.ReadByte(nr52), muted.ReadByte(nr52))
+	assert.Equal(t, playing.square1.saveState(), muted.square1.saveState())
+	assert.Equal(t, playing.capacitorL, muted.capacitorL)
+
+	// Samples waiting for the host are kept
+	playing.UpdateForCycles(cyclesPerSecond / 64)
+	playing.SetMuted(true)
+	playing.UpdateForCycles(cyclesPerSecond / 64)
+	assert.Equal(t, (32768/64)*2, playing.ReadSamples(buffer))
+}
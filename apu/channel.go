@@ -0,0 +1,88 @@
+package apu
+
+// Shared building blocks used by the channels: the length counter and the
+// volume envelope. Both are clocked by the frame sequencer.
+
+type lengthCounter struct {
+	enabled bool
+	counter uint16
+	max     uint16
+}
+
+func (l *lengthCounter) reset() {
+	l.enabled = false
+	l.counter = 0
+}
+
+func (l *lengthCounter) load(value uint16) {
+	l.counter = l.max - value
+}
+
+// Returns true if the channel should be disabled
+func (l *lengthCounter) clock() bool {
+	if !l.enabled || l.counter == 0 {
+		return false
+	}
+
+	l.counter--
+	return l.counter == 0
+}
+
+type envelope struct {
+	initialVolume uint8
+	increase      bool
+	period        uint8
+
+	volume uint8
+	timer  uint8
+}
+
+func (e *envelope) reset() {
+	e.initialVolume = 0
+	e.increase = false
+	e.period = 0
+	e.volume = 0
+	e.timer = 0
+}
+
+func (e *envelope) write(value uint8) {
+	e.initialVolume = value >> 4
+	e.increase = value&0x08 == 0x08
+	e.period = value & 0x07
+}
+
+func (e *envelope) trigger() {
+	e.volume = e.initialVolume
+	e.timer = e.period
+	if e.timer == 0 {
+		e.timer = 8
+	}
+}
+
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+
+	if e.timer > 0 {
+		e.timer--
+	}
+
+	if e.timer != 0 {
+		return
+	}
+
+	e.timer = e.period
+
+	if e.increase && e.volume < 15 {
+		e.volume++
+	} else if !e.increase && e.volume > 0 {
+		e.volume--
+	}
+}
+
+// The DAC is powered when any of the upper 5 bits of the envelope register
+// are set
+func (e *envelope) dacEnabled() bool {
+	return e.initialVolume != 0 || e.increase
+}
@@ -0,0 +1,112 @@
+package apu
+
+var noiseDivisors = [8]uint16{8, 16, 32, 48, 64, 80, 96, 112}
+
+type noiseChannel struct {
+	enabled    bool
+	clockShift uint8
+	widthMode  bool
+	divisor    uint8
+	timer      uint16
+	lfsr       uint16
+
+	length   lengthCounter
+	envelope envelope
+}
+
+func createNoiseChannel() *noiseChannel {
+	return &noiseChannel{
+		length: lengthCounter{max: 64},
+	}
+}
+
+func (n *noiseChannel) reset() {
+	n.enabled = false
+	n.clockShift = 0
+	n.widthMode = false
+	n.divisor = 0
+	n.timer = 0
+	n.lfsr = 0
+	n.length.reset()
+	n.envelope.reset()
+}
+
+func (n *noiseChannel) writeLength(value uint8) {
+	n.length.load(uint16(value & 0x3F))
+}
+
+func (n *noiseChannel) writeEnvelope(value uint8) {
+	n.envelope.write(value)
+
+	if !n.envelope.dacEnabled() {
+		n.enabled = false
+	}
+}
+
+func (n *noiseChannel) writePolynomial(value uint8) {
+	n.clockShift = value >> 4
+	n.widthMode = value&0x08 == 0x08
+	n.divisor = value & 0x07
+}
+
+func (n *noiseChannel) trigger() {
+	n.enabled = n.envelope.dacEnabled()
+	n.timer = n.period()
+	n.lfsr = 0x7FFF
+	n.envelope.trigger()
+}
+
+func (n *noiseChannel) period() uint16 {
+	return noiseDivisors[n.divisor] << n.clockShift
+}
+
+func (n *noiseChannel) step() {
+	if n.timer > 0 {
+		n.timer--
+	}
+
+	if n.timer != 0 {
+		return
+	}
+
+	n.timer = n.period()
+
+	// Shift clocks 14 and 15 don't clock the LFSR
+	if n.clockShift >= 14 {
+		return
+	}
+
+	xor := (n.lfsr & 0x01) ^ ((n.lfsr >> 1) & 0x01)
+	n.lfsr = (n.lfsr >> 1) | (xor << 14)
+
+	if n.widthMode {
+		n.lfsr = (n.lfsr &^ 0x40) | (xor << 6)
+	}
+}
+
+func (n *noiseChannel) clockLength() {
+	if n.length.clock() {
+		n.enabled = false
+	}
+}
+
+func (n *noiseChannel) clockEnvelope() {
+	n.envelope.clock()
+}
+
+func (n *noiseChannel) output() uint8 {
+	if !n.enabled {
+		return 0
+	}
+
+	// The output is the inverse of bit 0
+	if n.lfsr&0x01 == 0x01 {
+		return 0
+	}
+
+	return n.envelope.volume
+}
+
+func (n *noiseChannel) dacEnabled() bool {
+	return n.envelope.dacEnabled()
+}
@@ -0,0 +1,188 @@
+package apu
+
+var dutyPatterns = [4][8]uint8{
+	{0, 0, 0, 0, 0, 0, 0, 1}, // 12.5%
+	{1, 0, 0, 0, 0, 0, 0, 1}, // 25%
+	{1, 0, 0, 0, 0, 1, 1, 1}, // 50%
+	{0, 1, 1, 1, 1, 1, 1, 0}, // 75%
+}
+
+type squareChannel struct {
+	hasSweep bool
+
+	enabled   bool
+	duty      uint8
+	dutyStep  uint8
+	frequency uint16
+	timer     uint16
+
+	length   lengthCounter
+	envelope envelope
+
+	// Sweep is only used by channel 1
+	sweepPeriod       uint8
+	sweepNegate       bool
+	sweepShift        uint8
+	sweepTimer        uint8
+	sweepEnabled      bool
+	sweepShadow       uint16
+	sweepNegateCalced bool
+}
+
+func createSquareChannel(hasSweep bool) *squareChannel {
+	return &squareChannel{
+		hasSweep: hasSweep,
+		length:   lengthCounter{max: 64},
+	}
+}
+
+func (s *squareChannel) reset() {
+	s.enabled = false
+	s.duty = 0
+	s.dutyStep = 0
+	s.frequency = 0
+	s.timer = 0
+	s.length.reset()
+	s.envelope.reset()
+	s.sweepPeriod = 0
+	s.sweepNegate = false
+	s.sweepShift = 0
+	s.sweepTimer = 0
+	s.sweepEnabled = false
+	s.sweepShadow = 0
+	s.sweepNegateCalced = false
+}
+
+func (s *squareChannel) writeSweep(value uint8) {
+	s.sweepPeriod = (value >> 4) & 0x07
+	s.sweepNegate = value&0x08 == 0x08
+	s.sweepShift = value & 0x07
+
+	// Switching from subtraction to addition after a subtraction has been
+	// used in a calculation disables the channel
+	if !s.sweepNegate && s.sweepNegateCalced {
+		s.enabled = false
+	}
+}
+
+func (s *squareChannel) writeLengthDuty(value uint8) {
+	s.duty = value >> 6
+	s.length.load(uint16(value & 0x3F))
+}
+
+func (s *squareChannel) writeEnvelope(value uint8) {
+	s.envelope.write(value)
+
+	if !s.envelope.dacEnabled() {
+		s.enabled = false
+	}
+}
+
+func (s *squareChannel) writeFrequencyLow(value uint8) {
+	s.frequency = (s.frequency & 0x0700) | uint16(value)
+}
+
+func (s *squareChannel) writeFrequencyHigh(value uint8) {
+	s.frequency = (s.frequency & 0x00FF) | (uint16(value&0x07) << 8)
+}
+
+func (s *squareChannel) trigger() {
+	s.enabled = s.envelope.dacEnabled()
+	s.timer = (2048 - s.frequency) * 4
+	s.envelope.trigger()
+
+	if s.hasSweep {
+		s.sweepShadow = s.frequency
+		s.sweepNegateCalced = false
+		s.reloadSweepTimer()
+		s.sweepEnabled = s.sweepPeriod != 0 || s.sweepShift != 0
+
+		// An overflow check is done straight away if there is a shift
+		if s.sweepShift != 0 {
+			s.calculateSweep()
+		}
+	}
+}
+
+func (s *squareChannel) step() {
+	if s.timer > 0 {
+		s.timer--
+	}
+
+	if s.timer == 0 {
+		s.timer = (2048 - s.frequency) * 4
+		s.dutyStep = (s.dutyStep + 1) % 8
+	}
+}
+
+func (s *squareChannel) clockLength() {
+	if s.length.clock() {
+		s.enabled = false
+	}
+}
+
+func (s *squareChannel) clockEnvelope() {
+	s.envelope.clock()
+}
+
+func (s *squareChannel) clockSweep() {
+	if s.sweepTimer > 0 {
+		s.sweepTimer--
+	}
+
+	if s.sweepTimer != 0 {
+		return
+	}
+
+	s.reloadSweepTimer()
+
+	if !s.sweepEnabled || s.sweepPeriod == 0 {
+		return
+	}
+
+	newFrequency := s.calculateSweep()
+	if newFrequency <= 2047 && s.sweepShift != 0 {
+		s.sweepShadow = newFrequency
+		s.frequency = newFrequency
+
+		// Do the overflow check again with the new frequency
+		s.calculateSweep()
+	}
+}
+
+func (s *squareChannel) reloadSweepTimer() {
+	s.sweepTimer = s.sweepPeriod
+	if s.sweepTimer == 0 {
+		s.sweepTimer = 8
+	}
+}
+
+func (s *squareChannel) calculateSweep() uint16 {
+	change := s.sweepShadow >> s.sweepShift
+	var newFrequency uint16
+
+	if s.sweepNegate {
+		newFrequency = s.sweepShadow - change
+		s.sweepNegateCalced = true
+	} else {
+		newFrequency = s.sweepShadow + change
+	}
+
+	if newFrequency > 2047 {
+		s.enabled = false
+	}
+
+	return newFrequency
+}
+
+func (s *squareChannel) output() uint8 {
+	if !s.enabled {
+		return 0
+	}
+
+	return dutyPatterns[s.duty][s.dutyStep] * s.envelope.volume
+}
+
+func (s *squareChannel) dacEnabled() bool {
+	return s.envelope.dacEnabled()
+}
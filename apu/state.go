@@ -0,0 +1,231 @@
+package apu
+
+import "encoding/gob"
+
+type lengthCounterState struct {
+	Enabled bool
+	Counter uint16
+}
+
+type envelopeState struct {
+	InitialVolume uint8
+	Increase      bool
+	Period        uint8
+	Volume        uint8
+	Timer         uint8
+}
+
+type squareChannelState struct {
+	Enabled           bool
+	Duty              uint8
+	DutyStep          uint8
+	Frequency         uint16
+	Timer             uint16
+	Length            lengthCounterState
+	Envelope          envelopeState
+	SweepPeriod       uint8
+	SweepNegate       bool
+	SweepShift        uint8
+	SweepTimer        uint8
+	SweepEnabled      bool
+	SweepShadow       uint16
+	SweepNegateCalced bool
+}
+
+type waveChannelState struct {
+	Enabled    bool
+	DACOn      bool
+	VolumeCode uint8
+	Frequency  uint16
+	Timer      uint16
+	Position   uint8
+	Sample     uint8
+	JustRead   bool
+	Length     lengthCounterState
+	RAM        [waveRAMSize]uint8
+}
+
+type noiseChannelState struct {
+	Enabled    bool
+	ClockShift uint8
+	WidthMode  bool
+	Divisor    uint8
+	Timer      uint16
+	LFSR       uint16
+	Length     lengthCounterState
+	Envelope   envelopeState
+}
+
+type apuState struct {
+	Square1 squareChannelState
+	Square2 squareChannelState
+	Wave    waveChannelState
+	Noise   noiseChannelState
+
+	Registers             [0x20]uint8
+	Powered               bool
+	FrameSequencerCounter uint
+	FrameStep             uint8
+	SampleCounter         int
+	CapacitorL            float64
+	CapacitorR            float64
+}
+
+// The sample rate is a host setting so isn't saved and any samples waiting
+// to be read are thrown away when loading, unless muted
+func (a *Apu) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(apuState{
+		Square1:               a.square1.saveState(),
+		Square2:               a.square2.saveState(),
+		Wave:                  a.wave.saveState(),
+		Noise:                 a.noise.saveState(),
+		Registers:             a.registers,
+		Powered:               a.powered,
+		FrameSequencerCounter: a.frameSequencerCounter,
+		FrameStep:             a.frameStep,
+		SampleCounter:         a.sampleCounter,
+		CapacitorL:            a.capacitorL,
+		CapacitorR:            a.capacitorR,
+	})
+}
+
+func (a *Apu) LoadState(dec *gob.Decoder) error {
+	var state apuState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	a.square1.loadState(state.Square1)
+	a.square2.loadState(state.Square2)
+	a.wave.loadState(state.Wave)
+	a.noise.loadState(state.Noise)
+	a.registers = state.Registers
+	a.powered = state.Powered
+	a.frameSequencerCounter = state.FrameSequencerCounter
+	a.frameStep = state.FrameStep
+	a.sampleCounter = state.SampleCounter
+	a.capacitorL = state.CapacitorL
+	a.capacitorR = state.CapacitorR
+
+	if !a.muted {
+		a.samplesLock.Lock()
+		a.samples = a.samples[:0]
+		a.samplesLock.Unlock()
+	}
+	return nil
+}
+
+func (l *lengthCounter) saveState() lengthCounterState {
+	return lengthCounterState{Enabled: l.enabled, Counter: l.counter}
+}
+
+func (l *lengthCounter) loadState(state lengthCounterState) {
+	l.enabled = state.Enabled
+	l.counter = state.Counter
+}
+
+func (e *envelope) saveState() envelopeState {
+	return envelopeState{
+		InitialVolume: e.initialVolume,
+		Increase:      e.increase,
+		Period:        e.period,
+		Volume:        e.volume,
+		Timer:         e.timer,
+	}
+}
+
+func (e *envelope) loadState(state envelopeState) {
+	e.initialVolume = state.InitialVolume
+	e.increase = state.Increase
+	e.period = state.Period
+	e.volume = state.Volume
+	e.timer = state.Timer
+}
+
+func (s *squareChannel) saveState() squareChannelState {
+	return squareChannelState{
+		Enabled:           s.enabled,
+		Duty:              s.duty,
+		DutyStep:          s.dutyStep,
+		Frequency:         s.frequency,
+		Timer:             s.timer,
+		Length:            s.length.saveState(),
+		Envelope:          s.envelope.saveState(),
+		SweepPeriod:       s.sweepPeriod,
+		SweepNegate:       s.sweepNegate,
+		SweepShift:        s.sweepShift,
+		SweepTimer:        s.sweepTimer,
+		SweepEnabled:      s.sweepEnabled,
+		SweepShadow:       s.sweepShadow,
+		SweepNegateCalced: s.sweepNegateCalced,
+	}
+}
+
+func (s *squareChannel) loadState(state squareChannelState) {
+	s.enabled = state.Enabled
+	s.duty = state.Duty
+	s.dutyStep = state.DutyStep
+	s.frequency = state.Frequency
+	s.timer = state.Timer
+	s.length.loadState(state.Length)
+	s.envelope.loadState(state.Envelope)
+	s.sweepPeriod = state.SweepPeriod
+	s.sweepNegate = state.SweepNegate
+	s.sweepShift = state.SweepShift
+	s.sweepTimer = state.SweepTimer
+	s.sweepEnabled = state.SweepEnabled
+	s.sweepShadow = state.SweepShadow
+	s.sweepNegateCalced = state.SweepNegateCalced
+}
+
+func (w *waveChannel) saveState() waveChannelState {
+	return waveChannelState{
+		Enabled:    w.enabled,
+		DACOn:      w.dacOn,
+		VolumeCode: w.volumeCode,
+		Frequency:  w.frequency,
+		Timer:      w.timer,
+		Position:   w.position,
+		Sample:     w.sample,
+		JustRead:   w.justRead,
+		Length:     w.length.saveState(),
+		RAM:        w.ram,
+	}
+}
+
+func (w *waveChannel) loadState(state waveChannelState) {
+	w.enabled = state.Enabled
+	w.dacOn = state.DACOn
+	w.volumeCode = state.VolumeCode
+	w.frequency = state.Frequency
+	w.timer = state.Timer
+	w.position = state.Position
+	w.sample = state.Sample
+	w.justRead = state.JustRead
+	w.length.loadState(state.Length)
+	w.ram = state.RAM
+}
+
+func (n *noiseChannel) saveState() noiseChannelState {
+	return noiseChannelState{
+		Enabled:    n.enabled,
+		ClockShift: n.clockShift,
+		WidthMode:  n.widthMode,
+		Divisor:    n.divisor,
+		Timer:      n.timer,
+		LFSR:       n.lfsr,
+		Length:     n.length.saveState(),
+		Envelope:   n.envelope.saveState(),
+	}
+}
+
+func (n *noiseChannel) loadState(state noiseChannelState) {
+	n.enabled = state.Enabled
+	n.clockShift = state.ClockShift
+	n.widthMode = state.WidthMode
+	n.divisor = state.Divisor
+	n.timer = state.Timer
+	n.lfsr = state.LFSR
+	n.length.loadState(state.Length)
+	n.envelope.loadState(state.Envelope)
+}
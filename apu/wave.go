@@ -0,0 +1,154 @@
+package apu
+
+const waveRAMSize = 16
+
+type waveChannel struct {
+	enabled    bool
+	dacOn      bool
+	volumeCode uint8
+	frequency  uint16
+	timer      uint16
+	position   uint8
+	sample     uint8
+
+	// Set when the channel has just read a sample from wave RAM. On DMG the
+	// CPU can only access wave RAM in that window while the channel is on.
+	justRead bool
+
+	length lengthCounter
+
+	ram [waveRAMSize]uint8
+}
+
+func createWaveChannel() *waveChannel {
+	return &waveChannel{
+		length: lengthCounter{max: 256},
+	}
+}
+
+func (w *waveChannel) reset() {
+	w.enabled = false
+	w.dacOn = false
+	w.volumeCode = 0
+	w.frequency = 0
+	w.timer = 0
+	w.position = 0
+	w.sample = 0
+	w.justRead = false
+	w.length.reset()
+	// Wave RAM is not affected by power
+}
+
+func (w *waveChannel) writeDAC(value uint8) {
+	w.dacOn = value&0x80 == 0x80
+
+	if !w.dacOn {
+		w.enabled = false
+	}
+}
+
+func (w *waveChannel) writeLength(value uint8) {
+	w.length.load(uint16(value))
+}
+
+func (w *waveChannel) writeVolume(value uint8) {
+	w.volumeCode = (value >> 5) & 0x03
+}
+
+func (w *waveChannel) writeFrequencyLow(value uint8) {
+	w.frequency = (w.frequency & 0x0700) | uint16(value)
+}
+
+func (w *waveChannel) writeFrequencyHigh(value uint8) {
+	w.frequency = (w.frequency & 0x00FF) | (uint16(value&0x07) << 8)
+}
+
+func (w *waveChannel) trigger() {
+	// Triggering while the channel is reading corrupts the first bytes of
+	// wave RAM on DMG
+	if w.enabled && w.timer == 2 {
+		index := ((w.position + 1) % 32) / 2
+		if index < 4 {
+			w.ram[0] = w.ram[index]
+		} else {
+			start := index &^ 0x03
+			copy(w.ram[0:4], w.ram[start:start+4])
+		}
+	}
+
+	w.enabled = w.dacOn
+	w.position = 0
+	// There is a delay of 6 cycles before the first sample is read
+	w.timer = (2048-w.frequency)*2 + 6
+}
+
+func (w *waveChannel) step() {
+	w.justRead = false
+
+	if w.timer > 0 {
+		w.timer--
+	}
+
+	if w.timer == 0 {
+		w.timer = (2048 - w.frequency) * 2
+		w.position = (w.position + 1) % 32
+		w.sample = w.ram[w.position/2]
+		w.justRead = true
+	}
+}
+
+func (w *waveChannel) clockLength() {
+	if w.length.clock() {
+		w.enabled = false
+	}
+}
+
+func (w *waveChannel) readRAM(index uint8) uint8 {
+	if w.enabled {
+		if !w.justRead {
+			return 0xFF
+		}
+		return w.ram[w.position/2]
+	}
+
+	return w.ram[index]
+}
+
+func (w *waveChannel) writeRAM(index uint8, value uint8) {
+	if w.enabled {
+		if w.justRead {
+			w.ram[w.position/2] = value
+		}
+		return
+	}
+
+	w.ram[index] = value
+}
+
+func (w *waveChannel) output() uint8 {
+	if !w.enabled {
+		return 0
+	}
+
+	sample := w.sample
+	if w.position%2 == 0 {
+		sample = sample >> 4
+	} else {
+		sample = sample & 0x0F
+	}
+
+	switch w.volumeCode {
+	case 0:
+		return 0
+	case 1:
+		return sample
+	case 2:
+		return sample >> 1
+	default:
+		return sample >> 2
+	}
+}
+
+func (w *waveChannel) dacEnabled() bool {
+	return w.dacOn
+}
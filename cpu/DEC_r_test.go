@@ -3,8 +3,6 @@ package cpu
 import (
 	"testing"
 
-	"github.com/f1gopher/gbpixellib/log"
-	"github.com/f1gopher/gbpixellib/memory"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -39,7 +37,7 @@ func Test_DEC_A(t *testing.T) {
 func Benchmark_DEC(b *testing.B) {
 	opcode := createDEC_r(0x00, B)
 	regs := &Registers{}
-	mem := memory.CreateMemory(&log.Log{})
+	mem := &testMemory_RAM{}
 
 	for x := 0; x < b.N; x++ {
 		opcode.doCycle(1, regs, mem)
@@ -69,13 +67,13 @@ func (i *decTestSuite) test(initial uint8, expected uint8, carry bool) {
 	i.Equal(expected, regs.Get8(i.reg))
 	i.Equal(carry, regs.GetFlag(HFlag))
 	i.Equal(expected == 0, regs.GetFlag(ZFlag))
-	i.False(regs.GetFlag(NFlag))
+	i.True(regs.GetFlag(NFlag))
 
 	i.True(completed)
 }
 
 func (i *decTestSuite) Test_0() {
-	i.test(0, 255, false)
+	i.test(0, 255, true)
 }
 
 func (i *decTestSuite) Test_1() {
@@ -95,5 +93,5 @@ func (i *decTestSuite) Test_15() {
 }
 
 func (i *decTestSuite) Test_16() {
-	i.test(16, 15, false)
+	i.test(16, 15, true)
 }
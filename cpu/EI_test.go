@@ -0,0 +1,51 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func createProgramCPU(program []uint8) (*Cpu, *Registers) {
+	regs := &Registers{}
+	mem := &testMemory_RAM{}
+	copy(mem.data[0x0100:], program)
+
+	regs.Set16(PC, 0x0100)
+	return CreateCPU(&log.Log{}, regs, mem), regs
+}
+
+func TestEIDelayedByOneInstruction(t *testing.T) {
+	c, regs := createProgramCPU([]uint8{eiOpcode, 0x00, 0x00})
+
+	runInstruction(t, c)
+	assert.False(t, regs.GetIME())
+
+	// Set once the instruction after EI has finished so interrupts are
+	// checked before the next one
+	runInstruction(t, c)
+	assert.True(t, regs.GetIME())
+}
+
+func TestDIAfterEIKeepsInterruptsDisabled(t *testing.T) {
+	c, regs := createProgramCPU([]uint8{eiOpcode, diOpcode, 0x00})
+
+	runInstruction(t, c)
+	runInstruction(t, c)
+	assert.False(t, regs.GetIME())
+
+	runInstruction(t, c)
+	assert.False(t, regs.GetIME())
+}
+
+func TestEIDelayClearedByReset(t *testing.T) {
+	c, regs := createProgramCPU([]uint8{eiOpcode, 0x00})
+
+	runInstruction(t, c)
+	c.Reset()
+	regs.Set16(PC, 0x0101)
+
+	runInstruction(t, c)
+	assert.False(t, regs.GetIME())
+}
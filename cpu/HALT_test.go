@@ -0,0 +1,70 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// HALT, INC B, JR -2
+var haltProgram = []uint8{haltOpcode, 0x04, 0x18, 0xFE}
+
+func createHaltTestCPU(ime bool, pending bool) (*Cpu, *Registers, *testMemory_RAM) {
+	regs := &Registers{}
+	mem := &testMemory_RAM{}
+	copy(mem.data[0x0100:], haltProgram)
+
+	mem.WriteByte(interruptEnableAddress, 0x04)
+	if pending {
+		mem.WriteByte(interruptFlagAddress, 0x04)
+	}
+
+	regs.Set16(PC, 0x0100)
+	regs.SetIME(ime)
+	return CreateCPU(&log.Log{}, regs, mem), regs, mem
+}
+
+// Runs until an instruction finishes and returns the PC of the next one
+func runInstruction(t *testing.T, c *Cpu) uint16 {
+	for {
+		_, completed, _, _, err := c.ExecuteMCycle()
+		require.NoError(t, err)
+		if completed {
+			return c.GetOpcodePC()
+		}
+	}
+}
+
+func TestHALTWithoutInterrupt(t *testing.T) {
+	c, regs, _ := createHaltTestCPU(false, false)
+
+	assert.Equal(t, uint16(0x0101), runInstruction(t, c))
+	assert.True(t, regs.GetHALT())
+	assert.Equal(t, uint16(0x0102), regs.Get16(PC))
+}
+
+func TestHALTWithIMEAndPendingInterrupt(t *testing.T) {
+	c, regs, _ := createHaltTestCPU(true, true)
+
+	// Halts and is woken by the interrupt being dispatched
+	assert.Equal(t, uint16(0x0101), runInstruction(t, c))
+	assert.True(t, regs.GetHALT())
+	assert.Equal(t, uint16(0x0102), regs.Get16(PC))
+}
+
+func TestHALTBugReadsNextOpcodeTwice(t *testing.T) {
+	c, regs, _ := createHaltTestCPU(false, true)
+
+	// Doesn't halt and PC isn't increased after reading INC B
+	assert.Equal(t, uint16(0x0101), runInstruction(t, c))
+	assert.False(t, regs.GetHALT())
+	assert.Equal(t, uint16(0x0101), regs.Get16(PC))
+
+	// So INC B runs twice
+	assert.Equal(t, uint16(0x0101), runInstruction(t, c))
+	assert.Equal(t, uint16(0x0102), runInstruction(t, c))
+	assert.Equal(t, uint8(2), regs.Get8(B))
+	assert.False(t, regs.GetIME())
+}
@@ -3,8 +3,6 @@ package cpu
 import (
 	"testing"
 
-	"github.com/f1gopher/gbpixellib/log"
-	"github.com/f1gopher/gbpixellib/memory"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -39,7 +37,7 @@ func Test_INC_A(t *testing.T) {
 func Benchmark_INC(b *testing.B) {
 	opcode := createINC_r(0x00, B)
 	regs := &Registers{}
-	mem := memory.CreateMemory(&log.Log{})
+	mem := &testMemory_RAM{}
 
 	for x := 0; x < b.N; x++ {
 		opcode.doCycle(1, regs, mem)
@@ -61,7 +59,7 @@ func (i *incTestSuite) test(initial uint8, expected uint8, carry bool) {
 	}
 	mem := &testMemory_NoAccess{test: i.Suite.T()}
 
-	regs.set8(i.reg, initial)
+	regs.Set8(i.reg, initial)
 
 	completed, err := opcode.doCycle(1, regs, mem)
 
@@ -1,6 +1,7 @@
 package cpu
 
 import (
+	"encoding/gob"
 	"errors"
 	"fmt"
 
@@ -26,6 +27,9 @@ type RegistersInterface interface {
 	SetHALT(enabled bool)
 	GetHALT() bool
 
+	SetSTOP(enabled bool)
+	GetSTOP() bool
+
 	Reset()
 }
 
@@ -41,9 +45,6 @@ type MemoryInterface interface {
 
 	DisplaySetScanline(value uint8)
 	DisplaySetStatus(value uint8)
-
-	WriteDividerRegister(value uint8)
-	ExecuteDMAIfPending() bool
 }
 
 type Cpu struct {
@@ -62,6 +63,9 @@ type Cpu struct {
 	cbOpcodes [256]opcode
 
 	interruptHappened bool
+
+	// EI takes effect after the following instruction
+	imeDelayed bool
 }
 
 func CreateCPU(log *log.Log, regs RegistersInterface, memory MemoryInterface) *Cpu {
@@ -157,30 +161,15 @@ func (c *Cpu) Reset() {
 	c.prevOpcode = 0
 	c.isCB = false
 	c.interruptHappened = false
+	c.imeDelayed = false
 }
 
+// Called once an interrupt has been dispatched. The opcode fetched before the
+// interrupt is thrown away and the next M-cycle fetches from the handler.
 func (c *Cpu) DoInterruptCycle() error {
-	c.executeOpcodesMCycle++
-
-	// If an interrupt with jump happens reset and fetch the next instruction
 	c.executeOpcodesMCycle = 0
-
 	c.prevOpcodePC = c.executeOpcodePC
-	//c.executeOpcodePC = c.reg.Get16(PC)
-	//c.prevOpcode = c.executeOpcode.opcode()
-	//opcode := readAndIncPC(c.reg, c.memory)
-	//var cbOpcode uint8 = 0x00
-	//if opcode == 0xCB {
-	//	cbOpcode = readAndIncPC(c.reg, c.memory)
-	//}
-	//c.isCB = opcode == 0xCB
-	//
-	//var err error
-	//c.executeOpcode, err = c.getOpcode(opcode, cbOpcode)
-
 	c.interruptHappened = true
-
-	// If we had an error fetching the opcode fail
 	return nil
 }
 
@@ -261,10 +250,27 @@ func (c *Cpu) ExecuteMCycle() (breakpoint bool, instructionCompleted bool, opcod
 		opcodeRanDescription = c.executeOpcode.name()
 		opcodeRanId = c.executeOpcode.opcode()
 
+		// HALT only completes without halting when it hits the HALT bug
+		haltBug := c.executeOpcode == c.opcodes[haltOpcode] && !c.reg.GetHALT()
+
+		// DI straight after EI stops interrupts being enabled
+		if c.imeDelayed {
+			c.imeDelayed = false
+			if c.executeOpcode != c.opcodes[diOpcode] {
+				c.reg.SetIME(true)
+			}
+		}
+		if c.executeOpcode == c.opcodes[eiOpcode] {
+			c.imeDelayed = true
+		}
+
 		c.prevOpcodePC = c.executeOpcodePC
 		c.executeOpcodePC = c.reg.Get16(PC)
 		c.prevOpcode = c.executeOpcode.opcode()
 		opcode := readAndIncPC(c.reg, c.memory)
+		if haltBug {
+			c.reg.Set16(PC, c.reg.Get16(PC)-1)
+		}
 		var cbOpcode uint8 = 0x00
 		if opcode == 0xCB {
 			cbOpcode = readAndIncPC(c.reg, c.memory)
@@ -283,6 +289,11 @@ func (c *Cpu) ExecuteMCycle() (breakpoint bool, instructionCompleted bool, opcod
 	return breakpointHit, completed, opcodeRanId, opcodeRanDescription, nil
 }
 
+// False while part way through an instruction
+func (c *Cpu) IsBetweenInstructions() bool {
+	return c.executeOpcodesMCycle == 0
+}
+
 func (c *Cpu) GetOpcode() string {
 	if c.executeOpcode == nil {
 		return "N/A"
@@ -365,3 +376,121 @@ func (c *Cpu) getOpcode(opcode uint8, cbOpcode uint8) (executer opcode, err erro
 
 	return executor, nil
 }
+
+type cpuState struct {
+	AF   uint16
+	BC   uint16
+	DE   uint16
+	HL   uint16
+	SP   uint16
+	PC   uint16
+	IME  bool
+	HALT bool
+	STOP bool
+
+	HasOpcode         bool
+	OpcodeIsCB        bool
+	OpcodeIndex       uint8
+	OpcodeFields      map[string]int64
+	OpcodeMCycle      int
+	ExecuteOpcodePC   uint16
+	PrevOpcodePC      uint16
+	PrevOpcode        uint8
+	IsCB              bool
+	InterruptHappened bool
+	IMEDelayed        bool
+}
+
+// Saves the registers and the instruction being executed
+func (c *Cpu) SaveState(enc *gob.Encoder) error {
+	state := cpuState{
+		AF:                c.reg.Get16(AF),
+		BC:                c.reg.Get16(BC),
+		DE:                c.reg.Get16(DE),
+		HL:                c.reg.Get16(HL),
+		SP:                c.reg.Get16(SP),
+		PC:                c.reg.Get16(PC),
+		IME:               c.reg.GetIME(),
+		HALT:              c.reg.GetHALT(),
+		STOP:              c.reg.GetSTOP(),
+		OpcodeMCycle:      c.executeOpcodesMCycle,
+		ExecuteOpcodePC:   c.executeOpcodePC,
+		PrevOpcodePC:      c.prevOpcodePC,
+		PrevOpcode:        c.prevOpcode,
+		IsCB:              c.isCB,
+		InterruptHappened: c.interruptHappened,
+		IMEDelayed:        c.imeDelayed,
+	}
+
+	if c.executeOpcode != nil {
+		isCB, index, err := c.opcodeIndex(c.executeOpcode)
+		if err != nil {
+			return err
+		}
+
+		state.HasOpcode = true
+		state.OpcodeIsCB = isCB
+		state.OpcodeIndex = index
+		state.OpcodeFields = saveOpcodeFields(c.executeOpcode)
+	}
+
+	return enc.Encode(state)
+}
+
+func (c *Cpu) LoadState(dec *gob.Decoder) error {
+	var state cpuState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	c.executeOpcode = nil
+	if state.HasOpcode {
+		table := &c.opcodes
+		if state.OpcodeIsCB {
+			table = &c.cbOpcodes
+		}
+
+		c.executeOpcode = table[state.OpcodeIndex]
+		if c.executeOpcode == nil {
+			return errors.New(fmt.Sprintf("Saved opcode 0x%02X is not supported", state.OpcodeIndex))
+		}
+		loadOpcodeFields(c.executeOpcode, state.OpcodeFields)
+	}
+
+	c.reg.Set16(AF, state.AF)
+	c.reg.Set16(BC, state.BC)
+	c.reg.Set16(DE, state.DE)
+	c.reg.Set16(HL, state.HL)
+	c.reg.Set16(SP, state.SP)
+	c.reg.Set16(PC, state.PC)
+	c.reg.SetIME(state.IME)
+	c.reg.SetHALT(state.HALT)
+	c.reg.SetSTOP(state.STOP)
+
+	c.executeOpcodesMCycle = state.OpcodeMCycle
+	c.executeOpcodePC = state.ExecuteOpcodePC
+	c.prevOpcodePC = state.PrevOpcodePC
+	c.prevOpcode = state.PrevOpcode
+	c.isCB = state.IsCB
+	c.interruptHappened = state.InterruptHappened
+	c.imeDelayed = state.IMEDelayed
+	return nil
+}
+
+// Finds where an opcode is in the tables because the id alone doesn't say
+// whether it is a CB opcode
+func (c *Cpu) opcodeIndex(o opcode) (isCB bool, index uint8, err error) {
+	for x := range c.opcodes {
+		if c.opcodes[x] == o {
+			return false, uint8(x), nil
+		}
+	}
+
+	for x := range c.cbOpcodes {
+		if c.cbOpcodes[x] == o {
+			return true, uint8(x), nil
+		}
+	}
+
+	return false, 0, errors.New(fmt.Sprintf("Opcode %s is not in the opcode tables", o.name()))
+}
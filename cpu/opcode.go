@@ -1,5 +1,10 @@
 package cpu
 
+import (
+	"reflect"
+	"unsafe"
+)
+
 type opcode interface {
 	doCycle(cycleNumber int, reg RegistersInterface, mem MemoryInterface) (completed bool, err error)
 	name() string
@@ -18,3 +23,55 @@ func (o *opcodeBase) name() string { return o.opcodeName }
 func (o *opcodeBase) opcode() uint8 { return o.opcodeId }
 
 func (o *opcodeBase) length() uint8 { return o.opcodeLength }
+
+// Opcodes keep values they have read in earlier M-cycles in their own fields
+// so to save an instruction part way through we save every integer and bool
+// field. The opcode name, id and length in opcodeBase never change.
+func saveOpcodeFields(o opcode) map[string]int64 {
+	fields := make(map[string]int64)
+	value := reflect.ValueOf(o).Elem()
+
+	for x := 0; x < value.NumField(); x++ {
+		field := value.Field(x)
+		name := value.Type().Field(x).Name
+
+		switch field.Kind() {
+		case reflect.Bool:
+			if field.Bool() {
+				fields[name] = 1
+			} else {
+				fields[name] = 0
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fields[name] = field.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fields[name] = int64(field.Uint())
+		}
+	}
+
+	return fields
+}
+
+func loadOpcodeFields(o opcode, fields map[string]int64) {
+	value := reflect.ValueOf(o).Elem()
+
+	for x := 0; x < value.NumField(); x++ {
+		saved, exists := fields[value.Type().Field(x).Name]
+		if !exists {
+			continue
+		}
+
+		// The fields are unexported so get a settable version of it
+		field := value.Field(x)
+		field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+
+		switch field.Kind() {
+		case reflect.Bool:
+			field.SetBool(saved != 0)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(saved)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(uint64(saved))
+		}
+	}
+}
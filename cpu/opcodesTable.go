@@ -20,7 +20,7 @@ func createOpcodesTable() [256]opcode {
 	opcodes = append(opcodes, createLD_r_n(0x0E, C))
 	opcodes = append(opcodes, createRRCA(0x0F))
 
-	// STOP
+	opcodes = append(opcodes, createSTOP(0x10))
 	opcodes = append(opcodes, createLD_rr_nn(0x11, DE))
 	opcodes = append(opcodes, createLD_abs_rr_r(0x12, DE, A))
 	opcodes = append(opcodes, createINC_rr(0x13, DE))
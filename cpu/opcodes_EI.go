@@ -4,6 +4,9 @@ import (
 	"errors"
 )
 
+const eiOpcode = 0xFB
+const diOpcode = 0xF3
+
 type opcode_EI struct {
 	opcodeBase
 }
@@ -20,8 +23,8 @@ func createEI(opcode uint8) *opcode_EI {
 
 func (o *opcode_EI) doCycle(cycleNumber int, reg RegistersInterface, mem MemoryInterface) (completed bool, err error) {
 
+	// IME is set by the CPU once the next instruction has finished
 	if cycleNumber == 1 {
-		reg.SetIME(true)
 		return true, nil
 	}
 
@@ -4,6 +4,11 @@ import (
 	"errors"
 )
 
+const haltOpcode = 0x76
+
+const interruptFlagAddress = 0xFF0F
+const interruptEnableAddress = 0xFFFF
+
 type opcode_HALT struct {
 	opcodeBase
 }
@@ -21,9 +26,20 @@ func createHALT(opcode uint8) *opcode_HALT {
 func (o *opcode_HALT) doCycle(cycleNumber int, reg RegistersInterface, mem MemoryInterface) (completed bool, err error) {
 
 	if cycleNumber == 1 {
+		// With interrupts disabled and one already waiting the CPU doesn't
+		// halt. Instead the HALT bug stops PC increasing after reading the
+		// next opcode.
+		if !reg.GetIME() && isInterruptPending(mem) {
+			return true, nil
+		}
+
 		reg.SetHALT(true)
 		return true, nil
 	}
 
 	return false, errors.New("Invalid cycle")
 }
+
+func isInterruptPending(mem MemoryInterface) bool {
+	return mem.ReadByte(interruptFlagAddress)&mem.ReadByte(interruptEnableAddress)&0x1F != 0
+}
@@ -0,0 +1,32 @@
+package cpu
+
+import (
+	"errors"
+)
+
+type opcode_STOP struct {
+	opcodeBase
+}
+
+func createSTOP(opcode uint8) *opcode_STOP {
+	return &opcode_STOP{
+		opcodeBase: opcodeBase{
+			opcodeId:     opcode,
+			opcodeName:   "STOP",
+			opcodeLength: 2,
+		},
+	}
+}
+
+// The byte after STOP is skipped. What happens while stopped is up to the
+// system which checks the STOP flag after each instruction.
+func (o *opcode_STOP) doCycle(cycleNumber int, reg RegistersInterface, mem MemoryInterface) (completed bool, err error) {
+
+	if cycleNumber == 1 {
+		readAndIncPC(reg, mem)
+		reg.SetSTOP(true)
+		return true, nil
+	}
+
+	return false, errors.New("Invalid cycle")
+}
@@ -103,6 +103,7 @@ type Registers struct {
 
 	imeEnabled  bool
 	haltEnabled bool
+	stopEnabled bool
 }
 
 func (r *Registers) Reset() {
@@ -114,6 +115,7 @@ func (r *Registers) Reset() {
 	r.regPC = 0x0000
 	r.imeEnabled = false
 	r.haltEnabled = false
+	r.stopEnabled = false
 }
 
 func (r *Registers) SetIME(enabled bool) {
@@ -132,6 +134,14 @@ func (r *Registers) GetHALT() bool {
 	return r.haltEnabled
 }
 
+func (r *Registers) SetSTOP(enabled bool) {
+	r.stopEnabled = enabled
+}
+
+func (r *Registers) GetSTOP() bool {
+	return r.stopEnabled
+}
+
 func (r *Registers) Get8(source Register) uint8 {
 	switch source {
 	case A:
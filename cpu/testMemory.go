@@ -33,3 +33,50 @@ func (t *testMemory_NoAccess) Write(address uint16, data []uint8) error {
 	t.test.FailNow()
 	return nil
 }
+
+func (t *testMemory_NoAccess) Reset() {
+}
+
+func (t *testMemory_NoAccess) DisplaySetScanline(value uint8) {
+	t.test.FailNow()
+}
+
+func (t *testMemory_NoAccess) DisplaySetStatus(value uint8) {
+	t.test.FailNow()
+}
+
+// The whole address space as plain RAM
+type testMemory_RAM struct {
+	data [0x10000]uint8
+}
+
+func (t *testMemory_RAM) Reset() {
+	t.data = [0x10000]uint8{}
+}
+
+func (t *testMemory_RAM) ReadBit(address uint16, bit uint8) bool {
+	return (t.data[address]>>bit)&0x01 == 0x01
+}
+
+func (t *testMemory_RAM) ReadByte(address uint16) uint8 {
+	return t.data[address]
+}
+
+func (t *testMemory_RAM) ReadShort(address uint16) uint16 {
+	return uint16(t.data[address+1])<<8 | uint16(t.data[address])
+}
+
+func (t *testMemory_RAM) WriteByte(address uint16, value uint8) {
+	t.data[address] = value
+}
+
+func (t *testMemory_RAM) WriteShort(address uint16, value uint16) {
+	t.data[address] = uint8(value)
+	t.data[address+1] = uint8(value >> 8)
+}
+
+func (t *testMemory_RAM) DisplaySetScanline(value uint8) {
+}
+
+func (t *testMemory_RAM) DisplaySetStatus(value uint8) {
+}
@@ -26,7 +26,7 @@ type memoryRecord struct {
 }
 
 type debugMemory struct {
-	memory       *memory.Bus
+	memory       *memory.CPUBus
 	currentCycle uint
 	currentPC    uint16
 
@@ -36,7 +36,8 @@ type debugMemory struct {
 	breakpoints   map[uint16][]memoryBreakpoint
 	bpLock        sync.RWMutex
 
-	records map[uint16]*memoryRecord
+	records         map[uint16]*memoryRecord
+	recordersPaused bool
 }
 
 func (d *debugMemory) Reset() {
@@ -192,6 +193,17 @@ func (d *debugMemory) recordValues(address uint16) []MemoryRecordEntry {
 	return entry.history
 }
 
+// The starting value is always kept
+func (d *debugMemory) trimRecorders(mCycle uint) {
+	for _, recorder := range d.records {
+		keep := 1
+		for keep < len(recorder.history) && recorder.history[keep].MCycle < mCycle {
+			keep++
+		}
+		recorder.history = recorder.history[:keep]
+	}
+}
+
 func (d *debugMemory) ReadBit(address uint16, bit uint8) bool {
 	return d.memory.ReadBit(address, bit)
 }
@@ -225,7 +237,7 @@ func (d *debugMemory) WriteByte(address uint16, value uint8) {
 	}
 
 	recorder, exists := d.records[address]
-	if exists {
+	if exists && !d.recordersPaused {
 		recorder.history = append(recorder.history, MemoryRecordEntry{
 			MCycle: d.currentCycle,
 			PC:     d.currentPC,
@@ -250,14 +262,6 @@ func (d *debugMemory) DisplaySetStatus(value uint8) {
 	d.memory.DisplaySetStatus(value)
 }
 
-func (d *debugMemory) DumpCode(area memory.Area, bank uint8) (data []uint8, startAddress uint16) {
+func (d *debugMemory) DumpCode(area memory.Area, bank uint16) (data []uint8, startAddress uint16) {
 	return d.memory.DumpCode(area, bank)
 }
-
-func (d *debugMemory) WriteDividerRegister(value uint8) {
-	d.memory.WriteDividerRegister(value)
-}
-
-func (d *debugMemory) ExecuteDMAIfPending() bool {
-	return d.memory.ExecuteDMAIfPending()
-}
@@ -160,6 +160,14 @@ func (d *debugRegisters) GetHALT() bool {
 	return d.registers.GetHALT()
 }
 
+func (d *debugRegisters) SetSTOP(enabled bool) {
+	d.registers.SetSTOP(enabled)
+}
+
+func (d *debugRegisters) GetSTOP() bool {
+	return d.registers.GetSTOP()
+}
+
 func (d *debugRegisters) addBP(
 	reg cpu.Register,
 	comparison BreakpointComparison,
@@ -37,6 +37,10 @@ type Debugger interface {
 	AddMemoryRecorder(address uint16)
 	DeleteMemoryRecorder(address uint16)
 	MemoryRecordValues(address uint16) []MemoryRecordEntry
+	// While paused writes aren't added to the recorded values
+	SetMemoryRecordersPaused(paused bool)
+	// Drops recorded values written at or after the M-cycle
+	TrimMemoryRecorders(mCycle uint)
 }
 
 func CreateDebugger(l *log.Log, debug bool) (Debugger, cpu.RegistersInterface, cpu.MemoryInterface, *memory.Bus) {
@@ -12,7 +12,7 @@ type fakeDebugger struct {
 
 func createFakeDebugger(log *log.Log) (Debugger, cpu.RegistersInterface, cpu.MemoryInterface, *memory.Bus) {
 	mem := memory.CreateBus(log)
-	return &fakeDebugger{}, &cpu.Registers{}, mem, mem
+	return &fakeDebugger{}, &cpu.Registers{}, memory.CreateCPUBus(mem), mem
 }
 
 func (d *fakeDebugger) StartCycle(cycle uint, pc uint16) {
@@ -75,3 +75,10 @@ func (d *fakeDebugger) DeleteMemoryRecorder(address uint16) {
 func (d *fakeDebugger) MemoryRecordValues(address uint16) []MemoryRecordEntry {
 	panic("Not supported")
 }
+
+// There are no recorders so nothing to do
+func (d *fakeDebugger) SetMemoryRecordersPaused(paused bool) {
+}
+
+func (d *fakeDebugger) TrimMemoryRecorders(mCycle uint) {
+}
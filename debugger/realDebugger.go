@@ -57,7 +57,7 @@ func createRealDebugger(log *log.Log) (Debugger, cpu.RegistersInterface, cpu.Mem
 		bpLock:      sync.RWMutex{},
 	}
 	m := debugMemory{
-		memory:      memory.CreateBus(log),
+		memory:      memory.CreateCPUBus(memory.CreateBus(log)),
 		records:     make(map[uint16]*memoryRecord, 0),
 		breakpoints: make(map[uint16][]memoryBreakpoint),
 		bpLock:      sync.RWMutex{},
@@ -68,7 +68,7 @@ func createRealDebugger(log *log.Log) (Debugger, cpu.RegistersInterface, cpu.Mem
 		memory: m,
 	}
 
-	return d, &d.regs, &d.memory, d.memory.memory
+	return d, &d.regs, &d.memory, d.memory.memory.Bus
 }
 
 func (d *realDebugger) StartCycle(cycle uint, pc uint16) {
@@ -157,3 +157,11 @@ func (d *realDebugger) DeleteMemoryRecorder(address uint16) {
 func (d *realDebugger) MemoryRecordValues(address uint16) []MemoryRecordEntry {
 	return d.memory.recordValues(address)
 }
+
+func (d *realDebugger) SetMemoryRecordersPaused(paused bool) {
+	d.memory.recordersPaused = paused
+}
+
+func (d *realDebugger) TrimMemoryRecorders(mCycle uint) {
+	d.memory.trimRecorders(mCycle)
+}
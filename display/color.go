@@ -0,0 +1,170 @@
+package display
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/f1gopher/gbpixellib/memory"
+)
+
+// Game Boy Color output is a 15 bit colour, 5 bits each for red, green and
+// blue with red in the lowest bits. The flag keeps them apart from the
+// monochrome shades.
+const rgb555Flag ScreenColor = 0x10000
+
+func RGB555(value uint16) ScreenColor {
+	return rgb555Flag | ScreenColor(value&0x7FFF)
+}
+
+func (s ScreenColor) IsRGB555() bool {
+	return s&rgb555Flag == rgb555Flag
+}
+
+func (s ScreenColor) RGB555() uint16 {
+	return uint16(s & 0x7FFF)
+}
+
+// Converts a 15 bit colour to 8 bits per channel. Monochrome shades use the
+// same colours as DisplayConfig.
+func (s ScreenColor) RGBA() color.RGBA {
+	if !s.IsRGB555() {
+		switch s {
+		case White:
+			return screenWhite
+		case LightGray:
+			return screenLightGrey
+		case DarkGray:
+			return screenDarkGrey
+		case Black:
+			return screenBlack
+		default:
+			return screenOff
+		}
+	}
+
+	value := s.RGB555()
+	expand := func(channel uint16) uint8 {
+		channel &= 0x1F
+		return uint8(channel<<3 | channel>>2)
+	}
+
+	return color.RGBA{
+		R: expand(value),
+		G: expand(value >> 5),
+		B: expand(value >> 10),
+		A: 255,
+	}
+}
+
+func (s ScreenColor) rgb555String() string {
+	return fmt.Sprintf("RGB555 0x%04X", s.RGB555())
+}
+
+// The Game Boy Color VRAM bank and palettes
+type colorVideo interface {
+	ReadVideoBank(bank uint8, address uint16) uint8
+	BackgroundColor(palette uint8, index uint8) uint16
+	ObjectColor(palette uint8, index uint8) uint16
+	IsCompatibilityMode() bool
+}
+
+// Told about each H-blank, used for H-blank VRAM DMA
+type hblankListener interface {
+	HBlank()
+}
+
+func (s *Screen) SetColorVideo(video colorVideo) {
+	s.color = video
+}
+
+func (s *Screen) SetHBlankListener(listener hblankListener) {
+	s.hblankListener = listener
+}
+
+// Colour games on a Game Boy Color. Monochrome games on a Game Boy Color use
+// the monochrome rendering with the colours from the palettes.
+func (s *Screen) isColorMode() bool {
+	return s.color != nil && !s.color.IsCompatibilityMode()
+}
+
+func (s *Screen) compatibilityColor(c ScreenColor, palette Palette) ScreenColor {
+	if s.color == nil || c < White || c > Black {
+		return c
+	}
+
+	shade := uint8(c - White)
+	switch palette {
+	case Obj0:
+		return RGB555(s.color.ObjectColor(0, shade))
+	case Obj1:
+		return RGB555(s.color.ObjectColor(1, shade))
+	default:
+		return RGB555(s.color.BackgroundColor(0, shade))
+	}
+}
+
+func pixelIndex(block uint16, index byte) uint8 {
+	highFlag := uint8(block >> (8 + index) & 0x0001)
+	lowFlag := uint8(block >> index & 0x0001)
+	return highFlag<<1 | lowFlag
+}
+
+// Bit 3 of the attributes picks the VRAM bank for the tile data
+func colorTileBank(attributes uint8) uint8 {
+	if memory.GetBit(attributes, 3) {
+		return 1
+	}
+	return 0
+}
+
+func (s *Screen) readColorTile(bank uint8, address uint16) uint16 {
+	lsb := s.color.ReadVideoBank(bank, address)
+	msb := s.color.ReadVideoBank(bank, address+1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+// BG map attributes are in VRAM bank 1 at the same address as the tile number
+//
+// Bit 7 BG over OBJ priority
+// Bit 6 Y flip
+// Bit 5 X flip
+// Bit 3 Tile VRAM bank
+// Bit 0-2 Palette
+func (s *Screen) colorTilePixel(pixel byte, mapAddress uint16, tileData uint16, xPos byte, yPos byte) ScreenColor {
+	tileNum := uint16(s.color.ReadVideoBank(0, mapAddress))
+	attributes := s.color.ReadVideoBank(1, mapAddress)
+
+	line := int(yPos % 8)
+	if memory.GetBit(attributes, 6) {
+		line = 7 - line
+	}
+
+	colourBit := 7 - int(xPos%8)
+	if memory.GetBit(attributes, 5) {
+		colourBit = int(xPos % 8)
+	}
+
+	bank := uint8(0)
+	if memory.GetBit(attributes, 3) {
+		bank = 1
+	}
+
+	tile := s.readColorTile(bank, s.tileNumberToAddress(tileData, tileNum, line))
+	index := pixelIndex(tile, byte(colourBit))
+
+	s.bgIndex[pixel] = index
+	s.bgPriority[pixel] = memory.GetBit(attributes, 7)
+
+	return RGB555(s.color.BackgroundColor(attributes&0x07, index))
+}
+
+// OBJ attributes on the Game Boy Color use bit 3 for the tile VRAM bank and
+// bits 0-2 for the palette. Returns false for pixels behind the background.
+func (s *Screen) colorObjPixel(pixel int, index uint8, attributes uint8) (color ScreenColor, render bool) {
+	// LCDC bit 0 turns off all background priority
+	if s.BgWindowEnablePriority() && s.bgIndex[pixel] != 0 && (memory.GetBit(attributes, 7) || s.bgPriority[pixel]) {
+		return Off, false
+	}
+
+	return RGB555(s.color.ObjectColor(attributes&0x07, index)), true
+}
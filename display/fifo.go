@@ -0,0 +1,583 @@
+package display
+
+import (
+	"github.com/f1gopher/gbpixellib/memory"
+)
+
+// Selects how the screen is drawn
+type Renderer int
+
+const (
+	// Runs the background and sprite fetchers and the pixel FIFO a dot at a
+	// time so changes part way through a line are seen and mode 3 has the
+	// correct length
+	PixelFIFO Renderer = iota
+	// Draws each line in one go at the end of the line. Faster but mid line
+	// effects are lost and mode 3 is always 172 dots.
+	Scanline
+)
+
+func (r Renderer) String() string {
+	return [...]string{"Pixel FIFO", "Scanline"}[r]
+}
+
+const oamStart = 0xFE00
+const oamSprites = 40
+
+// The OAM scan picks at most 10 sprites for each line
+const maxSpritesPerLine = 10
+
+const mode2Duration = 80
+
+// Mode 3 is 172 dots with nothing slowing it down
+const scanlineMode3Duration = 172
+
+// The first tile is fetched and thrown away when mode 3 starts
+const fifoStartDelay = 6
+
+// Reading the tile number and both bytes of data
+const spriteFetchDots = 6
+
+type fetcherStep int
+
+const (
+	fetchTile fetcherStep = iota
+	fetchDataLow
+	fetchDataHigh
+	fetchPush
+)
+
+type fifoPixel struct {
+	// Colour index 0-3
+	color uint8
+	// OBP0/OBP1 for sprites on the Game Boy, the colour palette on the Game
+	// Boy Color
+	palette uint8
+	// BG over OBJ
+	priority bool
+	// OAM index, the Game Boy Color uses it for sprite priority
+	sprite uint8
+}
+
+type lineSprite struct {
+	index      uint8
+	y          uint8
+	x          uint8
+	tile       uint8
+	attributes uint8
+	fetched    bool
+}
+
+type pixelFIFO struct {
+	lcdX int
+	// SCX fine scroll pixels thrown away at the start of the line
+	discard int
+	delay   int
+	done    bool
+
+	bg    [8]fifoPixel
+	bgLen int
+	// Lines up with the next pixels shifted out of the background FIFO
+	obj [8]fifoPixel
+
+	step           fetcherStep
+	stepDots       int
+	tileX          uint8
+	window         bool
+	tileNum        uint8
+	tileAttributes uint8
+	dataLow        uint8
+	dataHigh       uint8
+
+	sprites     [maxSpritesPerLine]lineSprite
+	spriteCount int
+	// Index into sprites being fetched, -1 when fetching the background
+	spriteFetch int
+	spriteDots  int
+}
+
+// Finds the sprites on the current line in OAM order
+func (s *Screen) oamScan() (sprites [maxSpritesPerLine]lineSprite, count int) {
+	scanline := int(s.LY())
+	height := int(s.ObjSize())
+
+	for sprite := 0; sprite < oamSprites && count < maxSpritesPerLine; sprite++ {
+		address := uint16(oamStart + sprite*4)
+		y := s.memory.ReadByte(address)
+
+		top := int(y) - 16
+		if scanline < top || scanline >= top+height {
+			continue
+		}
+
+		sprites[count] = lineSprite{
+			index:      uint8(sprite),
+			y:          y,
+			x:          s.memory.ReadByte(address + 1),
+			tile:       s.memory.ReadByte(address + 2),
+			attributes: s.memory.ReadByte(address + 3),
+		}
+		count++
+	}
+
+	return sprites, count
+}
+
+// Called at the start of mode 3
+func (s *Screen) startFIFO() {
+	s.checkWindowY()
+	sprites, count := s.oamScan()
+
+	s.fifo = pixelFIFO{
+		discard:     int(s.SCX() % 8),
+		delay:       fifoStartDelay,
+		sprites:     sprites,
+		spriteCount: count,
+		spriteFetch: -1,
+	}
+}
+
+// Runs a single dot of mode 3. Returns true once the last pixel of the line
+// has been drawn.
+func (s *Screen) fifoDot() bool {
+	f := &s.fifo
+
+	if f.done {
+		return true
+	}
+
+	if f.delay > 0 {
+		f.delay--
+		return false
+	}
+
+	// Nothing is drawn while a sprite is fetched
+	if f.spriteFetch >= 0 {
+		f.spriteDots++
+		if f.spriteDots < spriteFetchDots {
+			return false
+		}
+		s.fetchSprite(&f.sprites[f.spriteFetch])
+		f.spriteFetch = -1
+	}
+
+	s.checkWindow()
+
+	// The background fetch has to finish before a sprite can be fetched
+	if sprite := s.dueSprite(); sprite >= 0 {
+		if f.bgLen > 0 && f.step == fetchPush {
+			f.spriteFetch = sprite
+			f.spriteDots = 0
+			return false
+		}
+
+		// The sprite fetch overlaps the last dot of the background fetch
+		s.fetcherDot()
+		if f.bgLen > 0 && f.step == fetchPush {
+			f.spriteFetch = sprite
+			f.spriteDots = 1
+		}
+		return false
+	}
+
+	s.fetcherDot()
+	s.shiftPixel()
+
+	return f.done
+}
+
+func (s *Screen) checkWindow() {
+	f := &s.fifo
+	if f.window {
+		return
+	}
+
+	start, visible := s.windowStart()
+	if !visible || (start > 0 && f.lcdX != start) || (start <= 0 && f.lcdX != 0) {
+		return
+	}
+
+	// The fetcher starts again with the first window tile
+	f.window = true
+	f.bgLen = 0
+	f.tileX = 0
+	f.step = fetchTile
+	f.stepDots = 0
+	s.windowDrawn = true
+
+	// Starting left of the screen hides the first few window pixels
+	f.discard = 0
+	if start < 0 {
+		f.discard = -start
+	}
+}
+
+func (s *Screen) dueSprite() int {
+	if !s.ObjEnable() {
+		return -1
+	}
+
+	f := &s.fifo
+	for x := 0; x < f.spriteCount; x++ {
+		if !f.sprites[x].fetched && int(f.sprites[x].x)-8 <= f.lcdX {
+			return x
+		}
+	}
+
+	return -1
+}
+
+func (s *Screen) fetcherDot() {
+	f := &s.fifo
+	f.stepDots++
+
+	switch f.step {
+	case fetchTile:
+		if f.stepDots < 2 {
+			return
+		}
+		mapAddress := s.fetcherMapAddress()
+		if s.isColorMode() {
+			f.tileNum = s.color.ReadVideoBank(0, mapAddress)
+			f.tileAttributes = s.color.ReadVideoBank(1, mapAddress)
+		} else {
+			f.tileNum = s.memory.ReadByte(mapAddress)
+			f.tileAttributes = 0
+		}
+		f.step = fetchDataLow
+		f.stepDots = 0
+	case fetchDataLow:
+		if f.stepDots < 2 {
+			return
+		}
+		f.dataLow = s.readBackgroundTile(0)
+		f.step = fetchDataHigh
+		f.stepDots = 0
+	case fetchDataHigh:
+		if f.stepDots < 2 {
+			return
+		}
+		f.dataHigh = s.readBackgroundTile(1)
+		f.step = fetchPush
+		f.stepDots = 0
+	case fetchPush:
+		if f.bgLen > 0 {
+			return
+		}
+		s.pushBackground()
+		f.tileX++
+		f.step = fetchTile
+		f.stepDots = 0
+	}
+}
+
+func (s *Screen) fetcherMapAddress() uint16 {
+	f := &s.fifo
+	if f.window {
+		row := uint16(s.windowLine) / 8
+		return s.WindowTileMapStart() + row*32 + uint16(f.tileX%32)
+	}
+
+	row := uint16(s.LY()+s.SCY()) / 8
+	column := (s.SCX()/8 + f.tileX) % 32
+	return s.BackgroundTileMapStart() + row*32 + uint16(column)
+}
+
+func (s *Screen) fetcherLine() int {
+	if s.fifo.window {
+		return int(s.windowLine) % 8
+	}
+
+	return int(s.LY()+s.SCY()) % 8
+}
+
+func (s *Screen) readBackgroundTile(offset uint16) uint8 {
+	f := &s.fifo
+	line := s.fetcherLine()
+	if memory.GetBit(f.tileAttributes, 6) {
+		line = 7 - line
+	}
+
+	address := s.tileNumberToAddress(s.BgWindowTileDataArea(), uint16(f.tileNum), line) + offset
+	if !s.isColorMode() {
+		return s.memory.ReadByte(address)
+	}
+
+	bank := uint8(0)
+	if memory.GetBit(f.tileAttributes, 3) {
+		bank = 1
+	}
+	return s.color.ReadVideoBank(bank, address)
+}
+
+func (s *Screen) pushBackground() {
+	f := &s.fifo
+	xFlip := memory.GetBit(f.tileAttributes, 5)
+
+	for x := 0; x < 8; x++ {
+		bit := 7 - x
+		if xFlip {
+			bit = x
+		}
+
+		f.bg[x] = fifoPixel{
+			color:    (f.dataHigh>>bit)&0x01<<1 | (f.dataLow>>bit)&0x01,
+			palette:  f.tileAttributes & 0x07,
+			priority: memory.GetBit(f.tileAttributes, 7),
+		}
+	}
+	f.bgLen = 8
+}
+
+// Sprite pixels only replace transparent pixels already in the FIFO so the
+// sprite loaded first wins. On the Game Boy Color the lowest OAM index wins.
+func (s *Screen) fetchSprite(sprite *lineSprite) {
+	f := &s.fifo
+	sprite.fetched = true
+
+	height := int(s.ObjSize())
+	tile := sprite.tile
+	if height == 16 {
+		tile &= 0xFE
+	}
+
+	line := int(s.LY()) - (int(sprite.y) - 16)
+	if memory.GetBit(sprite.attributes, 6) {
+		line = height - 1 - line
+	}
+
+	address := oamTileAddress(tile, line)
+	var low, high uint8
+	if s.isColorMode() {
+		bank := uint8(0)
+		if memory.GetBit(sprite.attributes, 3) {
+			bank = 1
+		}
+		low = s.color.ReadVideoBank(bank, address)
+		high = s.color.ReadVideoBank(bank, address+1)
+	} else {
+		low = s.memory.ReadByte(address)
+		high = s.memory.ReadByte(address + 1)
+	}
+
+	palette := sprite.attributes & 0x07
+	if !s.isColorMode() {
+		palette = (sprite.attributes >> 4) & 0x01
+	}
+
+	// Sprites partly off the left of the screen lose their first pixels
+	skip := f.lcdX - (int(sprite.x) - 8)
+	xFlip := memory.GetBit(sprite.attributes, 5)
+
+	for x := skip; x < 8; x++ {
+		bit := 7 - x
+		if xFlip {
+			bit = x
+		}
+
+		pixel := fifoPixel{
+			color:    (high>>bit)&0x01<<1 | (low>>bit)&0x01,
+			palette:  palette,
+			priority: memory.GetBit(sprite.attributes, 7),
+			sprite:   sprite.index,
+		}
+		if pixel.color == 0 {
+			continue
+		}
+
+		current := &f.obj[x-skip]
+		if current.color == 0 || (s.isColorMode() && pixel.sprite < current.sprite) {
+			*current = pixel
+		}
+	}
+}
+
+// Both sprite heights use the 0x8000 tile data
+func oamTileAddress(tile uint8, line int) uint16 {
+	return 0x8000 + uint16(tile)*tileSize + uint16(line)*2
+}
+
+func (s *Screen) shiftPixel() {
+	f := &s.fifo
+	if f.bgLen == 0 {
+		return
+	}
+
+	bg := f.bg[8-f.bgLen]
+	f.bgLen--
+
+	if f.discard > 0 {
+		f.discard--
+		return
+	}
+
+	obj := f.obj[0]
+	copy(f.obj[:], f.obj[1:])
+	f.obj[7] = fifoPixel{}
+
+	s.buffer[int(s.LY())*screenWidth+f.lcdX] = s.mixPixel(bg, obj)
+
+	f.lcdX++
+	if f.lcdX == screenWidth {
+		f.done = true
+	}
+}
+
+func (s *Screen) mixPixel(bg fifoPixel, obj fifoPixel) ScreenColor {
+	bgEnabled := s.BgWindowEnablePriority()
+	objVisible := obj.color != 0 && s.ObjEnable()
+
+	if s.isColorMode() {
+		// LCDC bit 0 turns off all background priority
+		if objVisible && !(bgEnabled && bg.color != 0 && (obj.priority || bg.priority)) {
+			return RGB555(s.color.ObjectColor(obj.palette, obj.color))
+		}
+		return RGB555(s.color.BackgroundColor(bg.palette, bg.color))
+	}
+
+	// With the background off it is white and sprites are always on top
+	if !bgEnabled {
+		bg.color = 0
+	}
+
+	if objVisible && !(obj.priority && bg.color != 0) {
+		if obj.palette == 1 {
+			return s.compatibilityColor(s.paletteColor(0xFF49, obj.color*2), Obj1)
+		}
+		return s.compatibilityColor(s.paletteColor(0xFF48, obj.color*2), Obj0)
+	}
+
+	if !bgEnabled {
+		return s.compatibilityColor(White, Background)
+	}
+	return s.compatibilityColor(s.paletteColor(0xFF47, bg.color*2), Background)
+}
+
+type fifoPixelState struct {
+	Color    uint8
+	Palette  uint8
+	Priority bool
+	Sprite   uint8
+}
+
+type lineSpriteState struct {
+	Index      uint8
+	Y          uint8
+	X          uint8
+	Tile       uint8
+	Attributes uint8
+	Fetched    bool
+}
+
+// Save states can be made part way through mode 3
+type fifoState struct {
+	LCDX           int
+	Discard        int
+	Delay          int
+	Done           bool
+	BG             [8]fifoPixelState
+	BGLen          int
+	OBJ            [8]fifoPixelState
+	Step           int
+	StepDots       int
+	TileX          uint8
+	Window         bool
+	TileNum        uint8
+	TileAttributes uint8
+	DataLow        uint8
+	DataHigh       uint8
+	Sprites        [maxSpritesPerLine]lineSpriteState
+	SpriteCount    int
+	SpriteFetch    int
+	SpriteDots     int
+}
+
+func (f *pixelFIFO) saveState() fifoState {
+	state := fifoState{
+		LCDX:           f.lcdX,
+		Discard:        f.discard,
+		Delay:          f.delay,
+		Done:           f.done,
+		BGLen:          f.bgLen,
+		Step:           int(f.step),
+		StepDots:       f.stepDots,
+		TileX:          f.tileX,
+		Window:         f.window,
+		TileNum:        f.tileNum,
+		TileAttributes: f.tileAttributes,
+		DataLow:        f.dataLow,
+		DataHigh:       f.dataHigh,
+		SpriteCount:    f.spriteCount,
+		SpriteFetch:    f.spriteFetch,
+		SpriteDots:     f.spriteDots,
+	}
+
+	for x := 0; x < 8; x++ {
+		state.BG[x] = f.bg[x].saveState()
+		state.OBJ[x] = f.obj[x].saveState()
+	}
+	for x, sprite := range f.sprites {
+		state.Sprites[x] = lineSpriteState{
+			Index:      sprite.index,
+			Y:          sprite.y,
+			X:          sprite.x,
+			Tile:       sprite.tile,
+			Attributes: sprite.attributes,
+			Fetched:    sprite.fetched,
+		}
+	}
+
+	return state
+}
+
+func (f *pixelFIFO) loadState(state fifoState) {
+	f.lcdX = state.LCDX
+	f.discard = state.Discard
+	f.delay = state.Delay
+	f.done = state.Done
+	f.bgLen = state.BGLen
+	f.step = fetcherStep(state.Step)
+	f.stepDots = state.StepDots
+	f.tileX = state.TileX
+	f.window = state.Window
+	f.tileNum = state.TileNum
+	f.tileAttributes = state.TileAttributes
+	f.dataLow = state.DataLow
+	f.dataHigh = state.DataHigh
+	f.spriteCount = state.SpriteCount
+	f.spriteFetch = state.SpriteFetch
+	f.spriteDots = state.SpriteDots
+
+	for x := 0; x < 8; x++ {
+		f.bg[x] = state.BG[x].load()
+		f.obj[x] = state.OBJ[x].load()
+	}
+	for x, sprite := range state.Sprites {
+		f.sprites[x] = lineSprite{
+			index:      sprite.Index,
+			y:          sprite.Y,
+			x:          sprite.X,
+			tile:       sprite.Tile,
+			attributes: sprite.Attributes,
+			fetched:    sprite.Fetched,
+		}
+	}
+}
+
+func (p fifoPixel) saveState() fifoPixelState {
+	return fifoPixelState{
+		Color:    p.color,
+		Palette:  p.palette,
+		Priority: p.priority,
+		Sprite:   p.sprite,
+	}
+}
+
+func (p fifoPixelState) load() fifoPixel {
+	return fifoPixel{
+		color:    p.Color,
+		palette:  p.Palette,
+		priority: p.Priority,
+		sprite:   p.Sprite,
+	}
+}
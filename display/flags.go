@@ -77,17 +77,7 @@ func (s *Screen) LCDStatusLycLy() bool {
 }
 
 func (s *Screen) LCDStatusMode() lcdStatusMode {
-	value := s.memory.ReadByte(lcdStatus)
-
-	if value&0x0000 == 0x0000 {
-		return hblank
-	} else if value&0x0001 == 0x0001 {
-		return vblank
-	} else if value&0x0002 == 0x0002 {
-		return searchOAM
-	} else {
-		return transferringToController
-	}
+	return lcdStatusMode(s.memory.ReadByte(lcdStatus) & 0x03)
 }
 
 func (s *Screen) LCDSInterrptEnabled() bool {
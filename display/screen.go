@@ -1,11 +1,14 @@
 package display
 
 import (
+	"encoding/gob"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"os"
+	"sort"
 
 	"github.com/f1gopher/gbpixellib/cpu"
 	"github.com/f1gopher/gbpixellib/interupt"
@@ -52,6 +55,9 @@ const (
 )
 
 func (s ScreenColor) String() string {
+	if s.IsRGB555() {
+		return s.rgb555String()
+	}
 	return [...]string{"Off", "White", "Light Grey", "Dark Grey", "Black"}[s]
 }
 
@@ -60,6 +66,9 @@ type DisplayConfig struct {
 	Height int
 
 	Colors map[ScreenColor]color.RGBA
+
+	// Game Boy Color output uses 15 bit colours, see ScreenColor.RGBA
+	Color bool
 }
 
 var screenOff = color.RGBA{R: 255, G: 0, B: 0, A: 255}
@@ -76,28 +85,76 @@ type Screen struct {
 	log             *os.File
 	memory          cpu.MemoryInterface
 	interuptHandler interuptHandler
+	color           colorVideo
+	hblankListener  hblankListener
+	frameListener   frameListener
 
 	buffer []ScreenColor
 
+	// Background colour index and priority for the current line so sprites
+	// can be drawn behind the background
+	bgIndex    [screenWidth]uint8
+	bgPriority [screenWidth]bool
+
+	renderer Renderer
+	fifo     pixelFIFO
+
+	// Window line counter and if WY has matched LY this frame
+	windowLine      uint8
+	windowTriggered bool
+	windowDrawn     bool
+	windowWholeLine bool
+
+	// Mode 3 finishes when the pixel FIFO has drawn the whole line
+	mode3End uint
+
 	currentCycleForScanline uint
 }
 
-func CreateScreen(memory cpu.MemoryInterface, interuptHandler interuptHandler) *Screen {
+func CreateScreen(memory cpu.MemoryInterface, interuptHandler interuptHandler, renderer Renderer) *Screen {
 	f, _ := os.Create("./gpu-log.txt")
 	return &Screen{
 		log:                     f,
 		memory:                  memory,
 		interuptHandler:         interuptHandler,
 		buffer:                  make([]ScreenColor, screenWidth*screenHeight),
+		renderer:                renderer,
+		mode3End:                mode2Duration + scanlineMode3Duration,
 		currentCycleForScanline: 0,
 	}
 }
 
+func (s *Screen) Renderer() Renderer {
+	return s.renderer
+}
+
+// Told when each frame has been drawn, used by the Super Game Boy
+type frameListener interface {
+	FrameComplete(buffer []ScreenColor)
+}
+
+func (s *Screen) SetFrameListener(listener frameListener) {
+	s.frameListener = listener
+}
+
 func (s *Screen) Reset() {
 	for x := 0; x < len(s.buffer); x++ {
 		s.buffer[x] = Off
 	}
 	s.currentCycleForScanline = 0
+	s.fifo = pixelFIFO{spriteFetch: -1}
+	s.resetWindow()
+	s.mode3End = mode2Duration + scanlineMode3Duration
+	if s.renderer == PixelFIFO {
+		s.mode3End = cyclesToDrawScanline + 1
+	}
+}
+
+// The LCD shows nothing while the CPU is stopped
+func (s *Screen) Blank() {
+	for x := 0; x < len(s.buffer); x++ {
+		s.buffer[x] = White
+	}
 }
 
 func (s *Screen) DisplayConfig() DisplayConfig {
@@ -110,11 +167,10 @@ func (s *Screen) DisplayConfig() DisplayConfig {
 			LightGray: screenLightGrey,
 			DarkGray:  screenDarkGrey,
 		},
+		Color: s.color != nil,
 	}
 }
 
-// TODO - need to update/set LYC LY compare and LCD status interrupts?
-
 func (s *Screen) DumpTileset() image.Image {
 	// A tileset contains 255 tiles each 8x8 pixels
 	//
@@ -370,6 +426,19 @@ func (s *Screen) UpdateForCycles(cyclesCompleted uint) {
 		return
 	}
 
+	if s.renderer == Scanline {
+		s.advance(cyclesCompleted)
+		return
+	}
+
+	// The pixel FIFO runs a dot at a time so it sees register writes made part
+	// way through a line
+	for x := uint(0); x < cyclesCompleted; x++ {
+		s.advance(1)
+	}
+}
+
+func (s *Screen) advance(cyclesCompleted uint) {
 	s.currentCycleForScanline += cyclesCompleted
 
 	if s.currentCycleForScanline > cyclesToDrawScanline {
@@ -378,11 +447,19 @@ func (s *Screen) UpdateForCycles(cyclesCompleted uint) {
 
 		if currentScanline == 144 {
 			s.interuptHandler.Request(interupt.VBlank)
+
+			if s.frameListener != nil {
+				s.frameListener.FrameComplete(s.buffer)
+			}
 		} else if currentScanline > 153 {
 			s.memory.DisplaySetScanline(0)
+			s.resetWindow()
 			resetToZero = true
 		} else if currentScanline < 144 {
-			s.drawScanline()
+			if s.renderer == Scanline {
+				s.drawScanline()
+			}
+			s.endWindowLine()
 		}
 
 		s.currentCycleForScanline -= cyclesToDrawScanline
@@ -391,52 +468,58 @@ func (s *Screen) UpdateForCycles(cyclesCompleted uint) {
 			currentScanline = s.LY() + 1
 			s.memory.DisplaySetScanline(currentScanline)
 		}
+
+		if s.renderer == PixelFIFO {
+			s.mode3End = cyclesToDrawScanline + 1
+		}
+	}
+
+	if s.renderer == PixelFIFO && s.LY() < screenHeight {
+		s.updateFIFO()
 	}
 
 	s.setLcdMode()
 }
 
+func (s *Screen) updateFIFO() {
+	if s.currentCycleForScanline < mode2Duration || s.currentCycleForScanline >= s.mode3End {
+		return
+	}
+
+	if s.currentCycleForScanline == mode2Duration {
+		s.startFIFO()
+	}
+
+	if s.fifoDot() {
+		s.mode3End = s.currentCycleForScanline + 1
+	}
+}
+
 func (s *Screen) Cycles() uint {
 	return s.currentCycleForScanline
 }
 
 func (s *Screen) setLcdMode() {
 	status := s.memory.ReadByte(lcdStatus)
+	wasHBlank := status&0x03 == 0x00
+	wasRequesting := statInterruptLine(status)
 
 	currentLine := s.memory.ReadByte(lcdScanline)
 
 	// Set LYC == LY flag
-	lycSelect := s.LY() == s.LYC()
-	status = memory.SetBit(status, 2, lycSelect)
-	if lycSelect && memory.GetBit(status, 6) {
-		s.interuptHandler.Request(interupt.LCD)
-	}
-
-	currentMode := s.LCDStatusMode()
+	status = memory.SetBit(status, 2, s.LY() == s.LYC())
 
 	if currentLine >= 144 {
 		// VBlank - 1
 		status = memory.SetBit(status, 0, true)
 		status = memory.SetBit(status, 1, false)
 
-		if currentMode != vblank && memory.GetBit(status, 4) {
-			s.interuptHandler.Request(interupt.LCD)
-		}
-
 	} else {
-		const mode2Duration = 80
-		// TODO - this value varies so is atleast 172 but can be more
-		const mode3Duration = 172
-
 		if s.currentCycleForScanline < mode2Duration {
 			status = memory.SetBit(status, 0, false)
 			status = memory.SetBit(status, 1, true)
-
-			if currentMode != searchOAM && memory.GetBit(status, 5) {
-				s.interuptHandler.Request(interupt.LCD)
-			}
 		} else {
-			if s.currentCycleForScanline < mode2Duration+mode3Duration {
+			if s.currentCycleForScanline < s.mode3End {
 				status = memory.SetBit(status, 0, true)
 				status = memory.SetBit(status, 1, true)
 
@@ -444,18 +527,49 @@ func (s *Screen) setLcdMode() {
 				status = memory.SetBit(status, 0, false)
 				status = memory.SetBit(status, 1, false)
 
-				if currentMode != hblank && memory.GetBit(status, 3) {
-					s.interuptHandler.Request(interupt.LCD)
+				if !wasHBlank && s.hblankListener != nil {
+					s.hblankListener.HBlank()
 				}
 			}
 		}
 	}
 
+	// The enabled sources share one line and the interrupt is only requested
+	// when it goes high, so a source doesn't request it again while it or
+	// another source is still active
+	if statInterruptLine(status) && !wasRequesting {
+		s.interuptHandler.Request(interupt.LCD)
+	}
+
 	s.memory.DisplaySetStatus(status)
 }
 
+func statInterruptLine(status uint8) bool {
+	if memory.GetBit(status, 6) && memory.GetBit(status, 2) {
+		return true
+	}
+
+	switch lcdStatusMode(status & 0x03) {
+	case hblank:
+		return memory.GetBit(status, 3)
+	case vblank:
+		return memory.GetBit(status, 4)
+	case searchOAM:
+		return memory.GetBit(status, 5)
+	default:
+		return false
+	}
+}
+
 func (s *Screen) drawScanline() {
-	if s.BgWindowEnablePriority() {
+	s.checkWindowY()
+
+	// With the background off sprites are always on top
+	s.bgIndex = [screenWidth]uint8{}
+
+	// On the Game Boy Color the background is always drawn and the flag only
+	// controls priority
+	if s.BgWindowEnablePriority() || s.isColorMode() {
 		s.renderTiles()
 	}
 
@@ -465,143 +579,142 @@ func (s *Screen) drawScanline() {
 }
 
 func (s *Screen) renderTiles() {
-
-	var backgroundMemory uint16 = 0
-
 	scrollY := s.SCY()
 	scrollX := s.SCX()
-	windowY := s.WY()
-	windowX := s.WX() - 7
-
-	usingWindow := false
-
-	if s.WindowEnable() {
-		usingWindow = windowY <= s.LY()
-	}
+	windowStart, usingWindow := s.windowStart()
 
 	tileData := s.BgWindowTileDataArea()
 
-	if !usingWindow {
-		backgroundMemory = s.BackgroundTileMapStart()
-	} else {
-		backgroundMemory = s.WindowTileMapStart()
-	}
-
-	var yPos byte = 0
-
-	if !usingWindow {
-		yPos = scrollY + s.LY()
-	} else {
-		yPos = s.LY() - windowY
-	}
-
-	tileRow := ((uint16(yPos) % 0x100) / 8) * 32
-
-	var pixel byte = 0
-	for pixel = 0; pixel < screenWidth; pixel++ {
-		xPos := pixel + scrollX
-
-		if usingWindow {
-			if pixel >= windowX {
-				xPos = pixel - windowX
-			}
+	for pixel := 0; pixel < screenWidth; pixel++ {
+		backgroundMemory := s.BackgroundTileMapStart()
+		xPos := uint8(pixel) + scrollX
+		yPos := scrollY + s.LY()
+
+		// The window covers everything to the right of WX-7
+		if usingWindow && pixel >= windowStart {
+			backgroundMemory = s.WindowTileMapStart()
+			xPos = uint8(pixel - windowStart)
+			yPos = s.windowLine
+			s.windowDrawn = true
 		}
 
+		tileRow := uint16(yPos/8) * 32
 		tileCol := uint16(xPos) / 8 % 32
 		var tileNum uint16 = 0
 		tileAddress := backgroundMemory + tileRow + tileCol
 
-		abc := s.memory.ReadByte(tileAddress)
-		tileNum = uint16(abc)
+		var color ScreenColor
+		if s.isColorMode() {
+			color = s.colorTilePixel(byte(pixel), tileAddress, tileData, xPos, yPos)
+		} else {
+			abc := s.memory.ReadByte(tileAddress)
+			tileNum = uint16(abc)
 
-		var colourBit int = int(xPos % 8)
-		colourBit -= 7
-		colourBit = colourBit * -1
+			var colourBit int = int(xPos % 8)
+			colourBit -= 7
+			colourBit = colourBit * -1
 
-		tileAddres := s.tileNumberToAddress(tileData, tileNum, int(yPos))
+			tileAddres := s.tileNumberToAddress(tileData, tileNum, int(yPos))
 
-		tile := s.memory.ReadShort(tileAddres)
+			tile := s.memory.ReadShort(tileAddres)
 
-		color := s.colorForBGPixel(tile, byte(colourBit))
+			s.bgIndex[pixel] = pixelIndex(tile, byte(colourBit))
+			color = s.compatibilityColor(s.colorForBGPixel(tile, byte(colourBit)), Background)
+		}
 
 		finalY := s.LY()
-		if finalY < 0 || finalY >= screenHeight || pixel < 0 || pixel >= screenWidth {
+		if finalY >= screenHeight {
 			panic(fmt.Sprintf("Invalid pixel location %d,%d", pixel, finalY))
 		}
 
-		offset := (uint16(finalY) * uint16(screenWidth)) + uint16(pixel)
+		offset := (int(finalY) * screenWidth) + pixel
 
 		s.buffer[offset] = color
 	}
 }
 
 func (s *Screen) renderSprites() {
+	sprites, count := s.oamScan()
+	selected := sprites[:count]
+
+	// On the Game Boy the sprite with the lowest X is on top and OAM order
+	// decides between sprites with the same X. The Game Boy Color only uses
+	// OAM order which is the order they were found in.
+	if !s.isColorMode() {
+		sort.SliceStable(selected, func(a, b int) bool {
+			return selected[a].x < selected[b].x
+		})
+	}
 
-	var sprite uint16 = 0
-	for sprite = 0; sprite < 40; sprite++ {
+	scanline := s.LY()
+	height := int(s.ObjSize())
 
-		index := sprite * 4
-		yPos := s.memory.ReadByte(0xFE00+index) - 16
-		xPos := s.memory.ReadByte(0xFE00+index+1) - 8
-		tileLocation := s.memory.ReadByte(0xFE00 + index + 2)
-		attributes := s.memory.ReadByte(0xFE00 + index + 3)
+	// The first sprite with a visible pixel wins even if the background is
+	// drawn over it
+	var drawn [screenWidth]bool
 
-		usePalette1 := memory.GetBit(attributes, 4)
-		xFlip := memory.GetBit(attributes, 5)
-		yFlip := memory.GetBit(attributes, 6)
-		priority := memory.GetBit(attributes, 7)
+	for _, sprite := range selected {
+		usePalette1 := memory.GetBit(sprite.attributes, 4)
+		xFlip := memory.GetBit(sprite.attributes, 5)
+		yFlip := memory.GetBit(sprite.attributes, 6)
+		priority := memory.GetBit(sprite.attributes, 7)
 
-		ySize := s.ObjSize()
-		scanline := s.LY()
+		// 8x16 sprites ignore the lowest bit of the tile number
+		tileLocation := sprite.tile
+		if height == 16 {
+			tileLocation &= 0xFE
+		}
 
-		if scanline >= yPos && scanline < (yPos+ySize) {
+		line := int(scanline) - (int(sprite.y) - 16)
+		if yFlip {
+			line = height - 1 - line
+		}
 
-			line := scanline - yPos
+		dataAddress := oamTileAddress(tileLocation, line)
+		tile := s.memory.ReadShort(dataAddress)
 
-			if yFlip {
-				if line <= 3 {
-					line += (7 - line)
-				} else {
-					line = (7 - line)
-				}
+		for tilePixel := 0; tilePixel < 8; tilePixel++ {
+			pixel := int(sprite.x) - 8 + tilePixel
+			if pixel < 0 || pixel >= screenWidth || drawn[pixel] {
+				continue
 			}
 
-			line *= 2
-
-			dataAddress := (0x8000 + (uint16(tileLocation) * 16)) + uint16(line)
-
-			tile := s.memory.ReadShort(dataAddress)
-
-			for tilePixel := 7; tilePixel >= 0; tilePixel-- {
-
-				colorBit := tilePixel
-				if xFlip {
-					colorBit -= 7
-					colorBit *= -1
-				}
-
-				// If priority is true and color is 1,2,3 then we don't render
-				color, render := s.colorForObjPixel(tile, byte(colorBit), usePalette1, priority)
+			colorBit := 7 - tilePixel
+			if xFlip {
+				colorBit = tilePixel
+			}
 
-				// Is transparent for sprites
-				if !render {
-					continue
-				}
+			var index uint8
+			if s.isColorMode() {
+				index = pixelIndex(s.readColorTile(colorTileBank(sprite.attributes), dataAddress), byte(colorBit))
+			} else {
+				index = pixelIndex(tile, byte(colorBit))
+			}
 
-				xPix := 0 - tilePixel
-				xPix += 7
-				pixel := uint16(xPos) + uint16(xPix)
+			// Is transparent for sprites
+			if index == 0 {
+				continue
+			}
 
-				// This happens sometimes (Mario)
-				if scanline < 0 || scanline >= screenHeight || pixel < 0 || pixel >= screenWidth {
-					// panic("Invalid pixel location")
-					continue
+			var color ScreenColor
+			var render bool
+			if s.isColorMode() {
+				color, render = s.colorObjPixel(pixel, index, sprite.attributes)
+			} else {
+				// Only background colours 1-3 are drawn over the sprite
+				render = !priority || s.bgIndex[pixel] == 0
+				if usePalette1 {
+					color = s.compatibilityColor(s.paletteColor(0xFF49, index*2), Obj1)
+				} else {
+					color = s.compatibilityColor(s.paletteColor(0xFF48, index*2), Obj0)
 				}
+			}
 
-				s.buffer[pixel+(uint16(scanline)*uint16(screenWidth))] = color
+			drawn[pixel] = true
+			if render {
+				s.buffer[pixel+(int(scanline)*screenWidth)] = color
 			}
 		}
-
 	}
 }
 
@@ -661,3 +774,51 @@ func (s *Screen) colorForObjPixel(block uint16, index byte, usePalette1 bool, pr
 		return s.ObjPalette0Index3Color(), !priority
 	}
 }
+
+type screenState struct {
+	CurrentCycleForScanline uint
+	Buffer                  []ScreenColor
+	Mode3End                uint
+	FIFO                    fifoState
+	WindowLine              uint8
+	WindowTriggered         bool
+	WindowDrawn             bool
+	WindowWholeLine         bool
+}
+
+func (s *Screen) SaveState(enc *gob.Encoder) error {
+	buffer := make([]ScreenColor, len(s.buffer))
+	copy(buffer, s.buffer)
+
+	return enc.Encode(screenState{
+		CurrentCycleForScanline: s.currentCycleForScanline,
+		Buffer:                  buffer,
+		Mode3End:                s.mode3End,
+		FIFO:                    s.fifo.saveState(),
+		WindowLine:              s.windowLine,
+		WindowTriggered:         s.windowTriggered,
+		WindowDrawn:             s.windowDrawn,
+		WindowWholeLine:         s.windowWholeLine,
+	})
+}
+
+func (s *Screen) LoadState(dec *gob.Decoder) error {
+	var state screenState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if len(state.Buffer) != len(s.buffer) {
+		return errors.New(fmt.Sprintf("Saved screen has %d pixels but expected %d", len(state.Buffer), len(s.buffer)))
+	}
+
+	copy(s.buffer, state.Buffer)
+	s.currentCycleForScanline = state.CurrentCycleForScanline
+	s.mode3End = state.Mode3End
+	s.fifo.loadState(state.FIFO)
+	s.windowLine = state.WindowLine
+	s.windowTriggered = state.WindowTriggered
+	s.windowDrawn = state.WindowDrawn
+	s.windowWholeLine = state.WindowWholeLine
+	return nil
+}
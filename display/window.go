@@ -0,0 +1,54 @@
+package display
+
+// With WX at 166 the window isn't shown on the line but covers the whole of
+// the next line
+const windowNextLineX = 166
+
+// The window only shows once LY has matched WY during the frame
+func (s *Screen) checkWindowY() {
+	if s.LY() == s.WY() {
+		s.windowTriggered = true
+	}
+}
+
+// The first pixel of the window on the current line. It is negative when WX
+// is 0-6 and the start of the window is off the left of the screen.
+func (s *Screen) windowStart() (start int, visible bool) {
+	if !s.WindowEnable() || !s.windowTriggered {
+		return 0, false
+	}
+
+	// On the Game Boy LCDC bit 0 turns off the window as well
+	if !s.isColorMode() && !s.BgWindowEnablePriority() {
+		return 0, false
+	}
+
+	if s.windowWholeLine {
+		return 0, true
+	}
+
+	windowX := int(s.WX())
+	if windowX >= windowNextLineX {
+		return 0, false
+	}
+
+	return windowX - 7, true
+}
+
+// The window has its own line counter that only goes up on lines where it was
+// drawn so hiding it part way down the screen doesn't skip any of it
+func (s *Screen) endWindowLine() {
+	if s.windowDrawn {
+		s.windowLine++
+	}
+	s.windowDrawn = false
+
+	s.windowWholeLine = s.WindowEnable() && s.windowTriggered && s.WX() == windowNextLineX
+}
+
+func (s *Screen) resetWindow() {
+	s.windowLine = 0
+	s.windowTriggered = false
+	s.windowDrawn = false
+	s.windowWholeLine = false
+}
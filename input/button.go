@@ -0,0 +1,56 @@
+package input
+
+import "github.com/f1gopher/gbpixellib/interupt"
+
+// A set of buttons held down. The low 4 bits are the button row and the high 4
+// bits the direction row, both in P10-P13 order.
+type Button uint8
+
+const (
+	ButtonA Button = 1 << iota
+	ButtonB
+	ButtonSelect
+	ButtonStart
+	ButtonRight
+	ButtonLeft
+	ButtonUp
+	ButtonDown
+)
+
+const NoButtons Button = 0
+
+func (b Button) String() string {
+	if b == NoButtons {
+		return "None"
+	}
+
+	names := [...]string{"A", "B", "Select", "Start", "Right", "Left", "Up", "Down"}
+	result := ""
+	for x, name := range names {
+		if b&(1<<x) == 0 {
+			continue
+		}
+		if result != "" {
+			result += "+"
+		}
+		result += name
+	}
+	return result
+}
+
+// Sets every button at once, any not in the mask are released. The
+// interrupt happens when a selected line goes from high to low.
+func (i *Input) SetButtons(buttons Button) {
+	before := i.SelectedLines()
+	i.standard = ^uint8(buttons) & 0x0F
+	i.directional = ^uint8(buttons>>4) & 0x0F
+
+	if before&^i.SelectedLines() != 0 {
+		i.interupt.Request(interupt.Joypad)
+	}
+}
+
+// The buttons currently held down
+func (i *Input) Buttons() Button {
+	return Button(^i.standard&0x0F) | Button(^i.directional&0x0F)<<4
+}
@@ -1,8 +1,9 @@
 package input
 
 import (
+	"encoding/gob"
+
 	"github.com/f1gopher/gbpixellib/interupt"
-	"github.com/f1gopher/gbpixellib/memory"
 )
 
 const P13 = 3
@@ -26,6 +27,10 @@ type Input struct {
 
 	directional uint8
 	standard    uint8
+
+	// Pressing left and right or up and down together can't happen on a
+	// real joypad and some games break when it does
+	blockOpposite bool
 }
 
 func CreateInput(memory inputMemory, interrupt inputInterupt) *Input {
@@ -45,125 +50,132 @@ func (i *Input) Reset() {
 }
 
 func (i *Input) PressStart() {
-	i.inputStart(true)
+	i.setButton(ButtonStart, true)
 }
 
 func (i *Input) ReleaseStart() {
-	i.inputStart(false)
+	i.setButton(ButtonStart, false)
 }
 
 func (i *Input) PressSelect() {
-	i.inputSelect(true)
+	i.setButton(ButtonSelect, true)
 }
 
 func (i *Input) ReleaseSelect() {
-	i.inputSelect(false)
+	i.setButton(ButtonSelect, false)
 }
 func (i *Input) PressA() {
-	i.inputA(true)
+	i.setButton(ButtonA, true)
 }
 
 func (i *Input) ReleaseA() {
-	i.inputA(false)
+	i.setButton(ButtonA, false)
 }
 func (i *Input) PressB() {
-	i.inputB(true)
+	i.setButton(ButtonB, true)
 }
 
 func (i *Input) ReleaseB() {
-	i.inputB(false)
+	i.setButton(ButtonB, false)
 }
 func (i *Input) PressUp() {
-	i.inputUp(true)
+	i.setButton(ButtonUp, true)
 }
 
 func (i *Input) ReleaseUp() {
-	i.inputUp(false)
+	i.setButton(ButtonUp, false)
 }
 func (i *Input) PressDown() {
-	i.inputDown(true)
+	i.setButton(ButtonDown, true)
 }
 
 func (i *Input) ReleaseDown() {
-	i.inputDown(false)
+	i.setButton(ButtonDown, false)
 }
 
 func (i *Input) PressLeft() {
-	i.inputLeft(true)
+	i.setButton(ButtonLeft, true)
 }
 
 func (i *Input) ReleaseLeft() {
-	i.inputLeft(false)
+	i.setButton(ButtonLeft, false)
 }
 func (i *Input) PressRight() {
-	i.inputRight(true)
+	i.setButton(ButtonRight, true)
 }
 func (i *Input) ReleaseRight() {
-	i.inputRight(false)
+	i.setButton(ButtonRight, false)
 }
+
+// When blocked opposite directions pressed together read as neither pressed
+func (i *Input) SetOppositeDirections(allowed bool) {
+	i.blockOpposite = !allowed
+}
+
 func (i *Input) ReadDirectional() uint8 {
-	return i.directional
+	value := i.directional
+	if i.blockOpposite {
+		// Right and left
+		if value&0x03 == 0x00 {
+			value |= 0x03
+		}
+		// Up and down
+		if value&0x0C == 0x00 {
+			value |= 0x0C
+		}
+	}
+	return value
 }
 
 func (i *Input) ReadStandard() uint8 {
 	return i.standard
 }
 
-// NOTE: 0 is the button is pressed and 1 means not pressed
+// The P10-P13 lines for the rows selected with P14 and P15. A line is low
+// when a button on a selected row is pressed.
+func (i *Input) SelectedLines() uint8 {
+	lines := uint8(0x0F)
 
-func (i *Input) inputStart(pressed bool) {
-	i.standard = memory.SetBit(i.standard, P13, !pressed)
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
+	if !i.memory.ReadBit(0xFF00, 4) {
+		lines &= i.ReadDirectional()
 	}
-}
 
-func (i *Input) inputSelect(pressed bool) {
-	i.standard = memory.SetBit(i.standard, P12, !pressed)
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
+	if !i.memory.ReadBit(0xFF00, 5) {
+		lines &= i.standard
 	}
-}
 
-func (i *Input) inputA(pressed bool) {
-	i.standard = memory.SetBit(i.standard, P10, !pressed)
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
-	}
+	return lines
 }
 
-func (i *Input) inputB(pressed bool) {
-	i.standard = memory.SetBit(i.standard, P11, !pressed)
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
-	}
-}
+// NOTE: 0 is the button is pressed and 1 means not pressed
 
-func (i *Input) inputUp(pressed bool) {
-	i.directional = memory.SetBit(i.directional, P12, !pressed)
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
+func (i *Input) setButton(button Button, pressed bool) {
+	if pressed {
+		i.SetButtons(i.Buttons() | button)
+	} else {
+		i.SetButtons(i.Buttons() &^ button)
 	}
 }
 
-func (i *Input) inputDown(pressed bool) {
-	i.directional = memory.SetBit(i.directional, P13, !pressed)
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
-	}
+type inputState struct {
+	Directional uint8
+	Standard    uint8
 }
 
-func (i *Input) inputLeft(pressed bool) {
-	i.directional = memory.SetBit(i.directional, P11, !pressed)
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
-	}
+func (i *Input) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(inputState{
+		Directional: i.directional,
+		Standard:    i.standard,
+	})
 }
 
-func (i *Input) inputRight(pressed bool) {
-	i.directional = memory.SetBit(i.directional, P10, !pressed)
-
-	if !pressed {
-		i.interupt.Request(interupt.Joypad)
+func (i *Input) LoadState(dec *gob.Decoder) error {
+	var state inputState
+	if err := dec.Decode(&state); err != nil {
+		return err
 	}
+
+	i.directional = state.Directional
+	i.standard = state.Standard
+	return nil
 }
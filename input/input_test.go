@@ -0,0 +1,97 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/interupt"
+	"github.com/stretchr/testify/assert"
+)
+
+type joypadRegister struct {
+	value uint8
+}
+
+func (j *joypadRegister) WriteBit(address uint16, bit uint8, value bool) {
+}
+
+func (j *joypadRegister) WriteByte(address uint16, value uint8) {
+	j.value = value
+}
+
+func (j *joypadRegister) ReadBit(address uint16, bit uint8) bool {
+	return (j.value>>bit)&0x01 == 0x01
+}
+
+type interruptRecorder struct {
+	requested []interupt.Interupt
+}
+
+func (i *interruptRecorder) Request(value interupt.Interupt) {
+	i.requested = append(i.requested, value)
+}
+
+func createInput(selected uint8) (*Input, *interruptRecorder) {
+	interrupts := &interruptRecorder{}
+	input := CreateInput(&joypadRegister{value: selected}, interrupts)
+	input.Reset()
+	return input, interrupts
+}
+
+func TestInterruptOnPress(t *testing.T) {
+	// Buttons selected with P15 low
+	input, interrupts := createInput(0x10)
+
+	input.PressA()
+	assert.Equal(t, []interupt.Interupt{interupt.Joypad}, interrupts.requested)
+
+	input.ReleaseA()
+	assert.Len(t, interrupts.requested, 1)
+}
+
+func TestNoInterruptWhenRowNotSelected(t *testing.T) {
+	// Directions selected with P14 low
+	input, interrupts := createInput(0x20)
+
+	input.PressStart()
+	assert.Empty(t, interrupts.requested)
+
+	input.PressUp()
+	assert.Len(t, interrupts.requested, 1)
+}
+
+func TestNoInterruptWhenLineAlreadyLow(t *testing.T) {
+	// Both rows selected so A and right share P10
+	input, interrupts := createInput(0x00)
+
+	input.PressRight()
+	input.PressA()
+	assert.Len(t, interrupts.requested, 1)
+}
+
+func TestOppositeDirections(t *testing.T) {
+	input, _ := createInput(0x20)
+
+	input.PressLeft()
+	input.PressRight()
+	assert.Equal(t, uint8(0x0C), input.ReadDirectional())
+
+	input.SetOppositeDirections(false)
+	assert.Equal(t, uint8(0x0F), input.ReadDirectional())
+
+	input.ReleaseLeft()
+	assert.Equal(t, uint8(0x0E), input.ReadDirectional())
+}
+
+func TestSetButtons(t *testing.T) {
+	input, interrupts := createInput(0x10)
+
+	input.SetButtons(ButtonStart | ButtonUp)
+	assert.Equal(t, ButtonStart|ButtonUp, input.Buttons())
+	assert.Equal(t, uint8(0x07), input.ReadStandard())
+	assert.Equal(t, uint8(0x0B), input.ReadDirectional())
+	assert.Len(t, interrupts.requested, 1)
+
+	input.SetButtons(NoButtons)
+	assert.Equal(t, NoButtons, input.Buttons())
+	assert.Equal(t, "Start+Up", (ButtonStart | ButtonUp).String())
+}
@@ -22,9 +22,15 @@ func (i Interupt) String() string {
 	return [...]string{"V-Blank", "LCD", "Timer", "Serial", "Joypad"}[i]
 }
 
+const noInterupt = -1
+
 type Handler struct {
 	memory cpu.MemoryInterface
 	regs   cpu.RegistersInterface
+
+	dispatchMCycle   int
+	dispatchPC       uint16
+	dispatchInterupt int
 }
 
 func CreateHandler(memory cpu.MemoryInterface, registers cpu.RegistersInterface) *Handler {
@@ -35,16 +41,21 @@ func CreateHandler(memory cpu.MemoryInterface, registers cpu.RegistersInterface)
 }
 
 func (h *Handler) Reset() {
+	h.dispatchMCycle = 0
+	h.dispatchPC = 0
+	h.dispatchInterupt = noInterupt
 }
 
 func (h *Handler) TriggerTimerOverflow() {
 	h.Request(Time)
 }
 
+func (h *Handler) TriggerJoypad() {
+	h.Request(Joypad)
+}
+
 func (h *Handler) Request(i Interupt) {
 	value := h.memory.ReadByte(InteruptFlag)
-	// Todo - why??
-	value = value | 0xE0
 
 	var bit uint8 = 0
 	switch i {
@@ -66,73 +77,92 @@ func (h *Handler) Request(i Interupt) {
 	h.memory.WriteByte(InteruptFlag, value)
 }
 
+// An enabled interrupt is waiting to be serviced
 func (h *Handler) HasInterrupt() bool {
-	if h.regs.GetIME() {
-		req := h.memory.ReadByte(InteruptFlag)
-		enabled := h.memory.ReadByte(InteruptEnableRegister)
-
-		if req > 0 {
-			for i := 0; i < 5; i++ {
-				if memory.GetBit(req, i) {
-					if memory.GetBit(enabled, i) {
-						return true
-					}
-				}
-			}
-		}
+	return h.regs.GetIME() && h.IsPending()
+}
+
+// An enabled interrupt has been requested, whether or not IME is set. Wakes
+// the CPU from HALT.
+func (h *Handler) IsPending() bool {
+	req := h.memory.ReadByte(InteruptFlag)
+	enabled := h.memory.ReadByte(InteruptEnableRegister)
+
+	return req&enabled&0x1F != 0
+}
+
+// Interrupt dispatch takes 5 M-cycles. Two internal cycles, pushing each byte
+// of the PC and then jumping to the handler.
+const dispatchMCycles = 5
+
+// Starts dispatching an interrupt if one is enabled. DispatchMCycle must then
+// be called until it completes.
+func (h *Handler) Update(lastPC uint16) bool {
+	if !h.HasInterrupt() {
+		return false
 	}
-	return false
+
+	h.regs.SetIME(false)
+	h.dispatchPC = lastPC
+	h.dispatchMCycle = 0
+	h.dispatchInterupt = noInterupt
+	return true
 }
 
-func (h *Handler) Update(lastPC uint16) (interupted bool, name string) {
-	if h.regs.GetIME() {
-		req := h.memory.ReadByte(InteruptFlag)
-		enabled := h.memory.ReadByte(InteruptEnableRegister)
-
-		if req > 0 {
-			for i := 0; i < 5; i++ {
-				if memory.GetBit(req, i) {
-					if memory.GetBit(enabled, i) {
-						return true, h.serviceInterupt(lastPC, uint8(i))
-					}
-				}
-			}
+func (h *Handler) DispatchMCycle() (completed bool, name string) {
+	h.dispatchMCycle++
+
+	switch h.dispatchMCycle {
+	case 3:
+		cpu.DecAndWriteSP(h.regs, h.memory, cpu.Msb(h.dispatchPC))
+	case 4:
+		// The interrupt is picked after the high byte is pushed so if the push
+		// wrote to IE it can change which interrupt happens or cancel it
+		h.dispatchInterupt = h.highestPending()
+		if h.dispatchInterupt != noInterupt {
+			req := h.memory.ReadByte(InteruptFlag)
+			req = memory.SetBit(req, uint8(h.dispatchInterupt), false)
+			h.memory.WriteByte(InteruptFlag, req)
 		}
+		cpu.DecAndWriteSP(h.regs, h.memory, cpu.Lsb(h.dispatchPC))
+	case dispatchMCycles:
+		programCounter, name := vector(h.dispatchInterupt)
+		h.regs.Set16(cpu.PC, programCounter)
+		return true, name
 	}
 
 	return false, ""
 }
 
-func (h *Handler) serviceInterupt(currentPC uint16, interupt uint8) string {
-	h.regs.SetIME(false)
+func (h *Handler) highestPending() int {
 	req := h.memory.ReadByte(InteruptFlag)
-	req = memory.SetBit(req, interupt, false)
-	h.memory.WriteByte(InteruptFlag, req)
-	var name string
+	enabled := h.memory.ReadByte(InteruptEnableRegister)
+
+	for i := 0; i < 5; i++ {
+		if memory.GetBit(req, i) && memory.GetBit(enabled, i) {
+			return i
+		}
+	}
 
-	var programCounter uint16 = 0
+	return noInterupt
+}
+
+// A cancelled dispatch jumps to 0x0000
+func vector(interupt int) (programCounter uint16, name string) {
 	switch interupt {
 	case 0: // Vertical Blank
-		programCounter = 0x0040
-		name = "Vertical Blank"
+		return 0x0040, "Vertical Blank"
 	case 1: // LCDC Status
-		programCounter = 0x0048
-		name = "LCDC Status"
+		return 0x0048, "LCDC Status"
 	case 2: // Timer Overflow
-		programCounter = 0x0050
-		name = "Timer Overflow"
+		return 0x0050, "Timer Overflow"
 	case 3: // Serial Transfer
-		programCounter = 0x0058
-		name = "Serial Transfer"
+		return 0x0058, "Serial Transfer"
 	case 4: // Joypad
-		programCounter = 0x0060
-		name = "Joypad"
+		return 0x0060, "Joypad"
+	case noInterupt:
+		return 0x0000, "Cancelled"
 	default:
 		panic("Unhandled service interupt")
 	}
-
-	cpu.DecAndWriteSP(h.regs, h.memory, cpu.Msb(currentPC))
-	cpu.DecAndWriteSP(h.regs, h.memory, cpu.Lsb(currentPC))
-	h.regs.Set16(cpu.PC, programCounter)
-	return name
 }
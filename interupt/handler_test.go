@@ -0,0 +1,141 @@
+package interupt
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/cpu"
+	"github.com/stretchr/testify/assert"
+)
+
+// The whole address space as plain RAM
+type testMemory struct {
+	data [0x10000]uint8
+}
+
+func (t *testMemory) Reset() {
+}
+
+func (t *testMemory) ReadBit(address uint16, bit uint8) bool {
+	return (t.data[address]>>bit)&0x01 == 0x01
+}
+
+func (t *testMemory) ReadByte(address uint16) uint8 {
+	return t.data[address]
+}
+
+func (t *testMemory) ReadShort(address uint16) uint16 {
+	return uint16(t.data[address+1])<<8 | uint16(t.data[address])
+}
+
+func (t *testMemory) WriteByte(address uint16, value uint8) {
+	t.data[address] = value
+}
+
+func (t *testMemory) WriteShort(address uint16, value uint16) {
+	t.data[address] = uint8(value)
+	t.data[address+1] = uint8(value >> 8)
+}
+
+func (t *testMemory) DisplaySetScanline(value uint8) {
+}
+
+func (t *testMemory) DisplaySetStatus(value uint8) {
+}
+
+func createTestHandler(pc uint16, sp uint16, enabled uint8, requested uint8) (*Handler, *cpu.Registers, *testMemory) {
+	mem := &testMemory{}
+	regs := &cpu.Registers{}
+	regs.Set16(cpu.PC, pc)
+	regs.Set16(cpu.SP, sp)
+	regs.SetIME(true)
+	mem.WriteByte(InteruptEnableRegister, enabled)
+	mem.WriteByte(InteruptFlag, requested)
+
+	handler := CreateHandler(mem, regs)
+	handler.Reset()
+	return handler, regs, mem
+}
+
+type dispatchCycle struct {
+	pc        uint16
+	sp        uint16
+	requested uint8
+}
+
+func runDispatch(t *testing.T, h *Handler, regs *cpu.Registers, mem *testMemory, expected []dispatchCycle) string {
+	for x, cycle := range expected {
+		completed, name := h.DispatchMCycle()
+		assert.Equal(t, x == len(expected)-1, completed, "M-cycle %d", x+1)
+		assert.Equal(t, cycle.pc, regs.Get16(cpu.PC), "PC at M-cycle %d", x+1)
+		assert.Equal(t, cycle.sp, regs.Get16(cpu.SP), "SP at M-cycle %d", x+1)
+		assert.Equal(t, cycle.requested, mem.ReadByte(InteruptFlag), "IF at M-cycle %d", x+1)
+
+		if completed {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func TestDispatchTakesFiveMCycles(t *testing.T) {
+	h, regs, mem := createTestHandler(0x1234, 0xFFFE, 0x05, 0x05)
+
+	assert.True(t, h.Update(0x1234))
+	assert.False(t, regs.GetIME())
+
+	// The highest priority interrupt is cleared when the low byte is pushed
+	name := runDispatch(t, h, regs, mem, []dispatchCycle{
+		{0x1234, 0xFFFE, 0x05},
+		{0x1234, 0xFFFE, 0x05},
+		{0x1234, 0xFFFD, 0x05},
+		{0x1234, 0xFFFC, 0x04},
+		{0x0040, 0xFFFC, 0x04},
+	})
+
+	assert.Equal(t, "Vertical Blank", name)
+	assert.Equal(t, uint16(0x1234), mem.ReadShort(0xFFFC))
+}
+
+func TestNoDispatchWithoutIME(t *testing.T) {
+	h, regs, _ := createTestHandler(0x1234, 0xFFFE, 0x05, 0x05)
+	regs.SetIME(false)
+
+	assert.True(t, h.IsPending())
+	assert.False(t, h.Update(0x1234))
+}
+
+func TestIEWriteDuringDispatchCancels(t *testing.T) {
+	// Pushing the high byte of PC writes 0x02 to IE which disables the
+	// timer interrupt
+	h, regs, mem := createTestHandler(0x0234, 0x0000, 0x04, 0x04)
+
+	assert.True(t, h.Update(0x0234))
+	name := runDispatch(t, h, regs, mem, []dispatchCycle{
+		{0x0234, 0x0000, 0x04},
+		{0x0234, 0x0000, 0x04},
+		{0x0234, 0xFFFF, 0x04},
+		{0x0234, 0xFFFE, 0x04},
+		{0x0000, 0xFFFE, 0x04},
+	})
+
+	assert.Equal(t, "Cancelled", name)
+	assert.Equal(t, uint8(0x02), mem.ReadByte(InteruptEnableRegister))
+	assert.False(t, regs.GetIME())
+}
+
+func TestIEWriteDuringDispatchChangesInterrupt(t *testing.T) {
+	// The high byte enables LCD instead of the timer
+	h, regs, mem := createTestHandler(0x0234, 0x0000, 0x04, 0x06)
+
+	assert.True(t, h.Update(0x0234))
+	name := runDispatch(t, h, regs, mem, []dispatchCycle{
+		{0x0234, 0x0000, 0x06},
+		{0x0234, 0x0000, 0x06},
+		{0x0234, 0xFFFF, 0x06},
+		{0x0234, 0xFFFE, 0x04},
+		{0x0048, 0xFFFE, 0x04},
+	})
+
+	assert.Equal(t, "LCDC Status", name)
+}
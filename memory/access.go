@@ -0,0 +1,105 @@
+package memory
+
+const lcdControl = 0xFF40
+const lcdStatus = 0xFF41
+
+const videoRamStart = 0x8000
+const videoRamEnd = 0x9FFF
+
+const oamStart = 0xFE00
+
+const oamScanMode = 2
+const drawingMode = 3
+
+// The value read from memory the CPU can't currently access
+const blockedValue = 0xFF
+
+// The CPU's view of the bus. The PPU and DMA use the bus directly so they can
+// still read video memory while the CPU is locked out of it.
+type CPUBus struct {
+	*Bus
+}
+
+func CreateCPUBus(bus *Bus) *CPUBus {
+	return &CPUBus{
+		Bus: bus,
+	}
+}
+
+// Turn off the video memory and DMA restrictions so the CPU can always
+// access everything, useful when debugging
+func (b *Bus) SetAccessRestrictions(enabled bool) {
+	b.restrictAccess = enabled
+}
+
+func (b *Bus) AccessRestrictions() bool {
+	return b.restrictAccess
+}
+
+// Returns true and the value the CPU reads when it can't use the address
+func (b *Bus) cpuBlocked(address uint16) (blocked bool, value uint8) {
+	if !b.restrictAccess {
+		return false, 0
+	}
+
+	if conflict, value := b.dmaConflict(address); conflict {
+		return true, value
+	}
+
+	// The mode is left as it was when the LCD is turned off
+	if !GetBit(b.ram.ReadByte(lcdControl), 7) {
+		return false, 0
+	}
+
+	mode := b.ram.ReadByte(lcdStatus) & 0x03
+
+	if address >= videoRamStart && address <= videoRamEnd && mode == drawingMode {
+		return true, blockedValue
+	}
+
+	// The unused area after OAM is blocked along with it
+	if address >= oamStart && address <= unusableEnd && (mode == oamScanMode || mode == drawingMode) {
+		return true, blockedValue
+	}
+
+	return false, 0
+}
+
+func (c *CPUBus) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *CPUBus) ReadByte(address uint16) byte {
+	if blocked, value := c.cpuBlocked(address); blocked {
+		return value
+	}
+
+	return c.Bus.ReadByte(address)
+}
+
+func (c *CPUBus) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *CPUBus) WriteBit(address uint16, bit uint8, value bool) {
+	if blocked, _ := c.cpuBlocked(address); blocked {
+		return
+	}
+
+	c.Bus.WriteBit(address, bit, value)
+}
+
+func (c *CPUBus) WriteByte(address uint16, value byte) {
+	if blocked, _ := c.cpuBlocked(address); blocked {
+		return
+	}
+
+	c.Bus.WriteByte(address, value)
+}
+
+func (c *CPUBus) WriteShort(address uint16, value uint16) {
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
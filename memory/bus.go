@@ -1,6 +1,8 @@
 package memory
 
 import (
+	"encoding/gob"
+
 	"github.com/f1gopher/gbpixellib/log"
 )
 
@@ -27,10 +29,6 @@ func (a Area) String() string {
 		"Cartridge ROM Bank"}[a]
 }
 
-type timerDivide interface {
-	TimerDivideWrite()
-}
-
 type Bus struct {
 	log *log.Log
 
@@ -38,15 +36,29 @@ type Bus struct {
 	video     *videoRam
 	ram       *ram
 	cartridge Cartridge
-	timer     timerDivide
+	timer     RWMemory
+	audio     RWMemory
+	serial    RWMemory
+	color     *colorHardware
 
-	dmaPending bool
-	dmaAddress uint16
+	dma oamDMA
+
+	// Stop the CPU using video memory while the PPU has it
+	restrictAccess bool
 }
 
 const ramSize = 0x4000
 const ramOffset = 0xC000
 
+const timerStart = DividerRegister
+const timerEnd = 0xFF07
+
+const serialStart = 0xFF01
+const serialEnd = 0xFF02
+
+const audioStart = 0xFF10
+const audioEnd = 0xFF3F
+
 func CreateBus(log *log.Log) *Bus {
 	return &Bus{
 		log:       log,
@@ -55,13 +67,92 @@ func CreateBus(log *log.Log) *Bus {
 		ram:       CreateRam(),
 		cartridge: nil,
 		timer:     nil,
+		audio:     nil,
+		serial:    nil,
+
+		restrictAccess: true,
 	}
 }
 
-func (b *Bus) SetTimer(timer timerDivide) {
+func (b *Bus) SetTimer(timer RWMemory) {
 	b.timer = timer
 }
 
+func (b *Bus) SetAudio(audio RWMemory) {
+	b.audio = audio
+}
+
+func (b *Bus) SetSerial(serial RWMemory) {
+	b.serial = serial
+}
+
+// Adds the Game Boy Color hardware. Must be called before loading a game.
+func (b *Bus) EnableColor() {
+	b.color = createColorHardware(b)
+}
+
+func (b *Bus) IsColor() bool {
+	return b.color != nil
+}
+
+// Running a monochrome game on a Game Boy Color
+func (b *Bus) IsCompatibilityMode() bool {
+	return b.color != nil && b.color.compatibility
+}
+
+// Normally the boot ROM picks the mode. Without it the game header is used.
+func (b *Bus) SetCompatibilityMode(enabled bool) {
+	if b.color != nil {
+		b.color.setCompatibility(enabled)
+	}
+}
+
+func (b *Bus) DoubleSpeed() bool {
+	return b.color != nil && b.color.doubleSpeed
+}
+
+// Called for STOP which changes speed if KEY1 has been written to prepare
+func (b *Bus) SwitchSpeedIfPrepared() bool {
+	if b.color == nil || !b.color.speedSwitchPrepared {
+		return false
+	}
+
+	b.color.speedSwitchPrepared = false
+	b.color.doubleSpeed = !b.color.doubleSpeed
+	return true
+}
+
+// Returns the M-cycles the CPU was stopped for by VRAM DMA since the last call
+func (b *Bus) ExecuteHDMAIfPending() uint {
+	if b.color == nil {
+		return 0
+	}
+
+	stall := b.color.stallMCycles
+	b.color.stallMCycles = 0
+	return stall
+}
+
+// Called by the display at the start of each H-blank
+func (b *Bus) HBlank() {
+	if b.color != nil {
+		b.color.hblank()
+	}
+}
+
+// Reads VRAM from either bank without changing VBK
+func (b *Bus) ReadVideoBank(bank uint8, address uint16) uint8 {
+	return b.video.memoryForBank(bank).ReadByte(address)
+}
+
+func (b *Bus) BackgroundColor(palette uint8, index uint8) uint16 {
+	return b.color.bgPalette.color(palette, index)
+}
+
+func (b *Bus) ObjectColor(palette uint8, index uint8) uint16 {
+	return b.color.objPalette.color(palette, index)
+}
+
 func (b *Bus) Load(bios *[]byte, cartridge Cartridge) {
 	if bios != nil {
 		b.bios = CreateReadOnlyMemory("bios", bios, 0)
@@ -75,45 +166,47 @@ func (b *Bus) Load(bios *[]byte, cartridge Cartridge) {
 }
 
 func (b *Bus) Reset() {
-	b.dmaPending = false
-	b.dmaAddress = 0x0000
+	b.dma = oamDMA{}
 	b.video.Reset()
 	b.ram.Reset()
+	if b.color != nil {
+		b.color.Reset()
+	}
 	if b.cartridge != nil {
 		b.cartridge.Reset()
 	}
 }
 
 func (b *Bus) ReadBit(address uint16, bit uint8) bool {
+	address = echoAddress(address)
 	return b.target(address).ReadBit(address, bit)
 }
 
 func (b *Bus) ReadByte(address uint16) byte {
+	address = echoAddress(address)
 	return b.target(address).ReadByte(address)
 }
 
 func (b *Bus) ReadShort(address uint16) uint16 {
+	address = echoAddress(address)
 	return b.target(address).ReadShort(address)
 }
 func (b *Bus) WriteBit(address uint16, bit uint8, value bool) {
+	address = echoAddress(address)
 	b.target(address).WriteBit(address, bit, value)
 }
 func (b *Bus) WriteByte(address uint16, value byte) {
-	// Trigger DMA transfer
-	if address == 0xFF46 {
-		b.dmaPending = true
-		b.dmaAddress = uint16(value) << 8
-		return
-	}
+	address = echoAddress(address)
 
-	if address == DividerRegister {
-		b.timer.TimerDivideWrite()
-		return
+	// Trigger DMA transfer
+	if address == dmaRegister {
+		b.startDMA(value)
 	}
 
 	b.target(address).WriteByte(address, value)
 }
 func (b *Bus) WriteShort(address uint16, value uint16) {
+	address = echoAddress(address)
 	b.target(address).WriteShort(address, value)
 }
 
@@ -121,23 +214,8 @@ func (b *Bus) SetIO(io inputOutput, interupt interupt) {
 	b.ram.SetIO(io, interupt)
 }
 
-func (b *Bus) WriteDividerRegister(value uint8) {
-	b.ram.WriteDividerRegister(value)
-}
-
-func (b *Bus) ExecuteDMAIfPending() bool {
-	if !b.dmaPending {
-		return false
-	}
-
-	var i uint16 = 0
-	for i = 0; i < 0x9F; i++ {
-		b.WriteByte(0xFE00+i, b.ReadByte(b.dmaAddress+i))
-	}
-
-	b.dmaPending = false
-
-	return true
+func (b *Bus) SetJoypadListener(listener joypadListener) {
+	b.ram.joypadListener = listener
 }
 
 func (b *Bus) DisplaySetScanline(value uint8) {
@@ -148,7 +226,7 @@ func (b *Bus) DisplaySetStatus(value uint8) {
 	b.ram.DisplaySetStatus(value)
 }
 
-func (b *Bus) DumpCode(area Area, bank uint8) (data []uint8, startAddress uint16) {
+func (b *Bus) DumpCode(area Area, bank uint16) (data []uint8, startAddress uint16) {
 	switch area {
 	case BIOSROM:
 		return b.bios.DumpCode()
@@ -157,7 +235,7 @@ func (b *Bus) DumpCode(area Area, bank uint8) (data []uint8, startAddress uint16
 	case ConsoleRAM:
 		return b.ram.mem.DumpCode()
 	case CartridgeRAMBank:
-		return b.cartridge.DumpRAMBankCode(bank)
+		return b.cartridge.DumpRAMBankCode(uint8(bank))
 	case CartridgeRAM:
 		return b.cartridge.DumpRAMCode()
 	case CartridgeROMBank:
@@ -177,12 +255,124 @@ func (b *Bus) target(address uint16) RWMemory {
 			return b.video
 		}
 
-		if address <= 0x00FF && b.ram.ReadByte(0xFF50) == 0x00 {
-			return b.bios
+		if b.isBIOSMapped() {
+			// The colour boot ROM is split around the cartridge header
+			if address <= 0x00FF || (b.color != nil && address >= 0x0200 && int(address) < b.bios.size()) {
+				return b.bios
+			}
 		}
 
 		return b.cartridge
 	}
 
+	if b.color != nil {
+		if isColorRegister(address) {
+			return b.color
+		}
+
+		if bank := b.color.ramBank(address); bank != nil {
+			return bank
+		}
+	}
+
+	// DIV and the timer registers
+	if address >= timerStart && address <= timerEnd && b.timer != nil {
+		return b.timer
+	}
+
+	// Link cable
+	if address >= serialStart && address <= serialEnd && b.serial != nil {
+		return b.serial
+	}
+
+	// Sound registers and wave RAM
+	if address >= audioStart && address <= audioEnd && b.audio != nil {
+		return b.audio
+	}
+
 	return b.ram
 }
+
+func (b *Bus) isBIOSMapped() bool {
+	return b.bios != nil && b.ram.mem.ReadByte(bootROMRegister) == 0x00
+}
+
+type busState struct {
+	DMAActive     bool
+	DMASource     uint16
+	DMAIndex      uint16
+	DMAStarting   bool
+	DMADelay      uint8
+	DMANext       uint16
+	VideoRAM      []uint8
+	VideoRAMBank1 []uint8
+	VideoRAMBank  uint8
+	RAM           []uint8
+	Color         colorState
+}
+
+// Saves the bus, console memory and the cartridge
+func (b *Bus) SaveState(enc *gob.Encoder) error {
+	state := busState{
+		DMAActive:     b.dma.active,
+		DMASource:     b.dma.source,
+		DMAIndex:      b.dma.index,
+		DMAStarting:   b.dma.starting,
+		DMADelay:      b.dma.delay,
+		DMANext:       b.dma.next,
+		VideoRAM:      b.video.mem.saveState(),
+		VideoRAMBank1: b.video.bank1.saveState(),
+		VideoRAMBank:  b.video.bank,
+		RAM:           b.ram.mem.saveState(),
+	}
+	if b.color != nil {
+		state.Color = b.color.saveState()
+	}
+
+	err := enc.Encode(state)
+	if err != nil {
+		return err
+	}
+
+	return b.cartridge.SaveState(enc)
+}
+
+func (b *Bus) LoadState(dec *gob.Decoder) error {
+	var state busState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := b.video.mem.loadState(state.VideoRAM); err != nil {
+		return err
+	}
+
+	// States from before colour support don't have the second bank
+	if len(state.VideoRAMBank1) > 0 {
+		if err := b.video.bank1.loadState(state.VideoRAMBank1); err != nil {
+			return err
+		}
+	}
+	b.video.bank = state.VideoRAMBank
+
+	if err := b.ram.mem.loadState(state.RAM); err != nil {
+		return err
+	}
+
+	if b.color != nil {
+		if err := b.color.loadState(state.Color); err != nil {
+			return err
+		}
+	}
+
+	b.dma = oamDMA{
+		active:   state.DMAActive,
+		source:   state.DMASource,
+		index:    state.DMAIndex,
+		starting: state.DMAStarting,
+		delay:    state.DMADelay,
+		next:     state.DMANext,
+	}
+
+	return b.cartridge.LoadState(dec)
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provides the picture the Game Boy Camera sensor sees when it captures. The
+// image is scaled to the sensor size and converted to grey.
+type ImageSource interface {
+	Image() image.Image
+}
+
+// Captures the same image every time
+type StaticImageSource struct {
+	image image.Image
+}
+
+func CreateStaticImageSource(image image.Image) *StaticImageSource {
+	return &StaticImageSource{image: image}
+}
+
+func (s *StaticImageSource) Image() image.Image {
+	return s.image
+}
+
+// Each capture uses the next PNG in the folder, in name order, and loops back
+// to the first after the last one
+type FolderImageSource struct {
+	lock   sync.Mutex
+	images []image.Image
+	next   int
+}
+
+func CreateFolderImageSource(folder string) (*FolderImageSource, error) {
+	files, err := filepath.Glob(filepath.Join(folder, "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	images := make([]image.Image, 0)
+	for _, file := range files {
+		if strings.ToLower(filepath.Ext(file)) != ".png" {
+			continue
+		}
+
+		img, err := loadPNG(file)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	if len(images) == 0 {
+		return nil, errors.New(fmt.Sprintf("No PNG images found in %s", folder))
+	}
+
+	return &FolderImageSource{images: images}, nil
+}
+
+func (s *FolderImageSource) Image() image.Image {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	img := s.images[s.next]
+	s.next = (s.next + 1) % len(s.images)
+	return img
+}
+
+func loadPNG(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, errors.Join(errors.New(fmt.Sprintf("Failed to decode %s", file)), err)
+	}
+
+	return img, nil
+}
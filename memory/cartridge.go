@@ -1,6 +1,9 @@
 package memory
 
-import "fmt"
+import (
+	"encoding/gob"
+	"fmt"
+)
 
 const M_1Kb = 1024
 const M_8Kb = M_1Kb * 8
@@ -17,12 +20,26 @@ type Cartridge interface {
 	WriteByte(address uint16, value byte)
 	WriteShort(address uint16, value uint16)
 	DumpROMCode() (data []uint8, startAddress uint16)
-	DumpROMBankCode(bank uint8) (data []uint8, startAddress uint16)
+	DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16)
 	DumpRAMCode() (data []uint8, startAddress uint16)
 	DumpRAMBankCode(bank uint8) (data []uint8, startAddress uint16)
 
-	CurrentROMBank() uint8
+	CurrentROMBank() uint16
 	CurrentRAMBank() uint8
+
+	// Battery backed RAM using the common .sav file layout. Cartridges
+	// without a battery return nil.
+	ExportBatteryRAM() []uint8
+	ImportBatteryRAM(data []uint8) error
+
+	// Bank registers and RAM for save states
+	SaveState(enc *gob.Encoder) error
+	LoadState(dec *gob.Decoder) error
+}
+
+// Implemented by cartridges with hardware that runs from the system clock
+type ClockedCartridge interface {
+	UpdateForCycles(cycles uint)
 }
 
 // Cartridge types and implementations
@@ -62,7 +79,33 @@ func IsCartridgeSupported(cartType uint8) bool {
 		cartType == 0x02 || // MBC1+RAM
 		cartType == 0x03 || // MBC1+RAM+BATTERY
 		cartType == 0x05 || //MBC2
-		cartType == 0x06 // MBC2+BATTERY
+		cartType == 0x06 || // MBC2+BATTERY
+		cartType == 0x0B || // MMM01
+		cartType == 0x0C || // MMM01+RAM
+		cartType == 0x0D || // MMM01+RAM+BATTERY
+		cartType == 0x0F || // MBC3+TIMER+BATTERY
+		cartType == 0x10 || // MBC3+TIMER+RAM+BATTERY
+		cartType == 0x11 || // MBC3
+		cartType == 0x12 || // MBC3+RAM
+		cartType == 0x13 || // MBC3+RAM+BATTERY
+		cartType == 0x19 || // MBC5
+		cartType == 0x1A || // MBC5+RAM
+		cartType == 0x1B || // MBC5+RAM+BATTERY
+		cartType == 0x1C || // MBC5+RUMBLE
+		cartType == 0x1D || // MBC5+RUMBLE+RAM
+		cartType == 0x1E || // MBC5+RUMBLE+RAM+BATTERY
+		cartType == 0xFC || // POCKET CAMERA
+		cartType == 0xFE || // HuC3
+		cartType == 0xFF // HuC1+RAM+BATTERY
+}
+
+func hasBattery(cartType uint8) bool {
+	switch cartType {
+	case 0x03, 0x06, 0x09, 0x0D, 0x0F, 0x10, 0x13, 0x1B, 0x1E, 0x22, 0xFC, 0xFE, 0xFF:
+		return true
+	default:
+		return false
+	}
 }
 
 func CreateCartridge(cartType uint8, romSize uint32, ramSize uint32, data *[]byte) Cartridge {
@@ -74,19 +117,43 @@ func CreateCartridge(cartType uint8, romSize uint32, ramSize uint32, data *[]byt
 		panic("data doesn't match specified rom size")
 	}
 
+	battery := hasBattery(cartType)
+
 	switch cartType {
 	// ROM Only
 	case 0x00:
 		return createCartridgeNoMBC(romSize, ramSize, data)
 	// MBC1
 	case 0x01, 0x02, 0x03:
-		return createCartridgeMBC1(romSize, ramSize, data)
+		if isMBC1Multicart(data) {
+			return createCartridgeMBC1M(romSize, ramSize, data, battery)
+		}
+		return createCartridgeMBC1(romSize, ramSize, data, battery)
 	// MBC2
 	case 0x05, 0x06:
-		return createCartridgeMBC2(romSize, ramSize, data)
+		return createCartridgeMBC2(romSize, ramSize, data, battery)
+	// MMM01
+	case 0x0B, 0x0C, 0x0D:
+		return createCartridgeMMM01(romSize, ramSize, data, battery)
 	// MBC3
-	case 0x0F, 0x10, 0x11, 0x12, 0x13:
-		return createCartridgeMBC3(romSize, ramSize, data)
+	case 0x0F, 0x10:
+		return createCartridgeMBC3(romSize, ramSize, data, battery, true)
+	case 0x11, 0x12, 0x13:
+		return createCartridgeMBC3(romSize, ramSize, data, battery, false)
+	// MBC5
+	case 0x19, 0x1A, 0x1B:
+		return createCartridgeMBC5(romSize, ramSize, data, battery, false)
+	case 0x1C, 0x1D, 0x1E:
+		return createCartridgeMBC5(romSize, ramSize, data, battery, true)
+	// Game Boy Camera
+	case 0xFC:
+		return createCartridgeCamera(romSize, ramSize, data, battery)
+	// HuC3
+	case 0xFE:
+		return createCartridgeHuC3(romSize, ramSize, data, battery)
+	// HuC1
+	case 0xFF:
+		return createCartridgeHuC1(romSize, ramSize, data, battery)
 	default:
 		panic(fmt.Sprintf("Unsupported cartridge type: 0x%02X", cartType))
 	}
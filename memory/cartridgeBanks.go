@@ -1,26 +1,107 @@
 package memory
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
-func splitDataIntoBanks(bank0StartAddress uint16, otherBankStartAddress uint16, bankSize uint16, data *[]byte, name string) map[uint8]*Memory {
-	banks := make(map[uint8]*Memory)
-	var currentBank uint8 = 0
+func splitDataIntoBanks(bank0StartAddress uint16, otherBankStartAddress uint16, bankSize uint16, data *[]byte, name string, readonly bool) map[uint16]*Memory {
+	banks := make(map[uint16]*Memory)
+	var currentBank uint16 = 0
 
 	// Iterate the data and split into chunks of 0x4000 bytes per bank
 	for x := 0; x < len(*data); x += int(bankSize) {
-		bank := make([]byte, bankSize)
-
-		bank = (*data)[x : x+int(bankSize)]
+		// Small RAM sizes are less than a bank
+		end := x + int(bankSize)
+		if end > len(*data) {
+			end = len(*data)
+		}
+		bank := (*data)[x:end]
 
 		offset := bank0StartAddress
 		if x > 0 {
 			offset = otherBankStartAddress
 		}
 
-		result := CreateReadOnlyMemory(fmt.Sprintf("cartridge %s bank %d", name, currentBank), &bank, offset)
+		var result *Memory
+		if readonly {
+			result = CreateReadOnlyMemory(fmt.Sprintf("cartridge %s bank %d", name, currentBank), &bank, offset)
+		} else {
+			result = CreateMemory(fmt.Sprintf("cartridge %s bank %d", name, currentBank), &bank, offset)
+		}
 		banks[currentBank] = result
 		currentBank++
 	}
 
 	return banks
 }
+
+func saveBanks(banks map[uint16]*Memory) [][]uint8 {
+	data := make([][]uint8, len(banks))
+	for x := range data {
+		data[x] = banks[uint16(x)].saveState()
+	}
+	return data
+}
+
+// Joins the banks in order into a single block
+func joinBanks(banks map[uint16]*Memory) []uint8 {
+	data := make([]uint8, 0)
+	for x := 0; x < len(banks); x++ {
+		data = append(data, banks[uint16(x)].buffer...)
+	}
+	return data
+}
+
+// Fills the banks in order from a single block and returns anything left over
+func fillBanks(banks map[uint16]*Memory, data []uint8) (remaining []uint8, err error) {
+	size := 0
+	for _, bank := range banks {
+		size += bank.size()
+	}
+
+	if len(data) < size {
+		return nil, errors.New(fmt.Sprintf("Battery RAM is %d bytes but expected %d", len(data), size))
+	}
+
+	used := 0
+	for x := 0; x < len(banks); x++ {
+		used += copy(banks[uint16(x)].buffer, data[used:])
+	}
+	return data[used:], nil
+}
+
+func loadBanks(banks map[uint16]*Memory, data [][]uint8) error {
+	if len(data) != len(banks) {
+		return errors.New(fmt.Sprintf("Saved cartridge has %d banks but expected %d", len(data), len(banks)))
+	}
+
+	for x := range data {
+		if err := banks[uint16(x)].loadState(data[x]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads from a ROM bank mapped into either half of the cartridge ROM area.
+// Some mappers can put any bank at 0x0000 so the bank offset can't be used.
+func readBankByte(bank *Memory, address uint16) uint8 {
+	return bank.buffer[int(address%M_16Kb)%bank.size()]
+}
+
+// Banks are a power of two so bank numbers bigger than the ROM wrap
+func wrapBank(banks map[uint16]*Memory, bank uint16) uint16 {
+	if len(banks) == 0 {
+		return 0
+	}
+	return bank % uint16(len(banks))
+}
+
+// Combines the banks mapped at 0x0000 and 0x4000 for mappers that can map any
+// bank into either area
+func dumpROMArea(low *Memory, high *Memory) (data []uint8, startAddress uint16) {
+	code, _ := low.DumpCode()
+	currentCode, _ := high.DumpCode()
+	return append(code, currentCode...), 0x0000
+}
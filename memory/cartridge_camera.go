@@ -0,0 +1,515 @@
+package memory
+
+import (
+	"encoding/gob"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Selecting RAM bank 0x10 maps the sensor registers to 0xA000-0xBFFF. They
+// repeat every 0x80 bytes.
+const cameraRegisterSelect = 0x10
+const cameraRegisterCount = 0x36
+const cameraRegisterMirror = 0x7F
+
+// Sensor registers
+const (
+	cameraControl      = 0x00
+	cameraGain         = 0x01
+	cameraExposureHigh = 0x02
+	cameraExposureLow  = 0x03
+	cameraEdge         = 0x04
+	cameraMatrix       = 0x06
+)
+
+// Control register bits
+const cameraCaptureBit = 0x01
+const cameraControlReadable = 0x07
+
+// Set in the gain register when the sensor doesn't need the extra time to
+// handle negative pixel values
+const cameraNoNegative = 0x80
+
+// The rest of the gain register is the edge mode and the gain. Each step of
+// gain adds 1/32 to the brightness so the highest nearly doubles it.
+const cameraEdgeModeMask = 0x60
+const cameraEdgeModeShift = 5
+const cameraGainMask = 0x1F
+const cameraGainSteps = 32
+
+// Edge register bits. The output reference voltage in the low bits and the
+// offset register aren't emulated.
+const cameraEdgeExtract = 0x80
+const cameraEdgeRatioMask = 0x70
+const cameraEdgeRatioShift = 4
+const cameraInvert = 0x08
+
+// Edge modes
+const (
+	cameraEdgeNone = iota
+	cameraEdgeHorizontal
+	cameraEdgeVertical
+	cameraEdgeBoth
+)
+
+// Edge enhancement ratios in quarters, 50% to 500%
+var cameraEdgeRatios = [8]int32{2, 3, 4, 5, 8, 12, 16, 20}
+
+// The captured image is written as tiles to RAM bank 0
+const cameraWidth = 128
+const cameraHeight = 112
+const cameraImageAddress = 0xA100
+
+// An exposure of this leaves the source image brightness as it is. Shorter
+// exposures make it darker and longer ones brighter.
+const cameraNeutralExposure = 0x1000
+
+// Capture time in M-cycles is a fixed time, the negative pixel handling and
+// then the exposure time
+const cameraCaptureMCycles = 32446
+const cameraNegativeMCycles = 512
+const cameraExposureMCycles = 16
+
+// Implemented by the Game Boy Camera
+type CameraCartridge interface {
+	SetImageSource(source ImageSource)
+}
+
+type cartridgeCamera struct {
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
+
+	ramEnable     uint8
+	romBankNumber uint8
+	ramBankNumber uint8
+	ramStart      uint16
+
+	registers     [cameraRegisterCount]uint8
+	captureCycles uint
+	capture       []uint8
+
+	battery bool
+	source  ImageSource
+}
+
+func createCartridgeCamera(romSize uint32, ramSize uint32, data *[]byte, battery bool) Cartridge {
+	ram := make([]byte, ramSize)
+	return &cartridgeCamera{
+		romBanks:      splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBanks:      splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
+		ramEnable:     0x00,
+		romBankNumber: 0x01,
+		ramBankNumber: 0x00,
+		ramStart:      0xA000,
+		battery:       battery,
+	}
+}
+
+func (c *cartridgeCamera) Reset() {
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
+	}
+	// Don't reset the memory because that is the rom game
+
+	c.ramEnable = 0x00
+	c.romBankNumber = 0x01
+	c.ramBankNumber = 0x00
+	c.registers = [cameraRegisterCount]uint8{}
+	c.captureCycles = 0
+	c.capture = nil
+}
+
+func (c *cartridgeCamera) SetImageSource(source ImageSource) {
+	c.source = source
+}
+
+func (c *cartridgeCamera) UpdateForCycles(cycles uint) {
+	if c.captureCycles == 0 {
+		return
+	}
+
+	if cycles < c.captureCycles {
+		c.captureCycles -= cycles
+		return
+	}
+
+	c.captureCycles = 0
+	c.finishCapture()
+}
+
+func (c *cartridgeCamera) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *cartridgeCamera) ReadByte(address uint16) byte {
+	if address >= c.ramStart {
+		if c.isRegisterSelected() {
+			// Only the control register can be read
+			if (address-c.ramStart)&cameraRegisterMirror == cameraControl {
+				return c.registers[cameraControl] & cameraControlReadable
+			}
+			return 0x00
+		}
+
+		// RAM can always be read, the enable only protects writes
+		if len(c.ramBanks) == 0 {
+			return 0xFF
+		}
+	}
+
+	return c.memoryBank(address).ReadByte(address)
+}
+
+func (c *cartridgeCamera) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *cartridgeCamera) WriteBit(address uint16, bit uint8, value bool) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
+}
+
+func (c *cartridgeCamera) WriteByte(address uint16, value byte) {
+	if address <= 0x7FFF {
+		if address <= 0x1FFF {
+			c.ramEnable = value
+		} else if address <= 0x3FFF {
+			c.romBankNumber = value & 0b00111111
+		} else if address <= 0x5FFF {
+			c.ramBankNumber = value & 0b00011111
+		}
+
+		return
+	}
+
+	if c.isRegisterSelected() {
+		c.writeRegister(uint8((address-c.ramStart)&cameraRegisterMirror), value)
+		return
+	}
+
+	// If writing to RAM but RAM is disabled do nothing
+	if !c.isRamEnabled() || len(c.ramBanks) == 0 {
+		return
+	}
+
+	c.memoryBank(address).WriteByte(address, value)
+}
+
+func (c *cartridgeCamera) WriteShort(address uint16, value uint16) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
+
+func (c *cartridgeCamera) writeRegister(register uint8, value uint8) {
+	if register >= cameraRegisterCount {
+		return
+	}
+
+	if register != cameraControl {
+		c.registers[register] = value
+		return
+	}
+
+	// Clearing the capture bit cancels a capture in progress
+	c.registers[cameraControl] = value & cameraControlReadable
+	if value&cameraCaptureBit == 0 {
+		c.captureCycles = 0
+		c.capture = nil
+		return
+	}
+
+	if c.captureCycles == 0 {
+		c.startCapture()
+	}
+}
+
+func (c *cartridgeCamera) exposure() uint16 {
+	return uint16(c.registers[cameraExposureHigh])<<8 | uint16(c.registers[cameraExposureLow])
+}
+
+// The picture is taken when the capture starts and written to RAM when the
+// sensor has finished
+func (c *cartridgeCamera) startCapture() {
+	mCycles := uint(cameraCaptureMCycles) + uint(c.exposure())*cameraExposureMCycles
+	if c.registers[cameraGain]&cameraNoNegative == 0 {
+		mCycles += cameraNegativeMCycles
+	}
+
+	c.captureCycles = mCycles * 4
+	c.capture = c.processImage(c.sensorImage())
+}
+
+func (c *cartridgeCamera) finishCapture() {
+	c.registers[cameraControl] &^= cameraCaptureBit
+
+	if len(c.ramBanks) == 0 || c.capture == nil {
+		return
+	}
+
+	bank := c.ramBanks[0]
+	for x, value := range c.capture {
+		bank.WriteByte(cameraImageAddress+uint16(x), value)
+	}
+	c.capture = nil
+}
+
+// Scales the source to the sensor size and converts it to grey. With no
+// source the sensor sees no light.
+func (c *cartridgeCamera) sensorImage() *image.Gray {
+	sensor := image.NewGray(image.Rect(0, 0, cameraWidth, cameraHeight))
+	if c.source == nil {
+		return sensor
+	}
+
+	source := c.source.Image()
+	if source == nil {
+		return sensor
+	}
+
+	bounds := source.Bounds()
+	if bounds.Empty() {
+		return sensor
+	}
+
+	for y := 0; y < cameraHeight; y++ {
+		sourceY := bounds.Min.Y + y*bounds.Dy()/cameraHeight
+		for x := 0; x < cameraWidth; x++ {
+			sourceX := bounds.Min.X + x*bounds.Dx()/cameraWidth
+			sensor.Set(x, y, color.GrayModel.Convert(source.At(sourceX, sourceY)))
+		}
+	}
+
+	return sensor
+}
+
+// Applies the exposure, gain, edge enhancement and invert and then uses the
+// 4x4 matrix of thresholds to turn each pixel into one of the 4 shades,
+// stored as 2bpp tiles
+func (c *cartridgeCamera) processImage(sensor *image.Gray) []uint8 {
+	tiles := make([]uint8, (cameraWidth/8)*(cameraHeight/8)*16)
+	exposure := int32(c.exposure())
+	gain := int32(c.registers[cameraGain]&cameraGainMask) + cameraGainSteps
+
+	brightness := make([]int32, cameraWidth*cameraHeight)
+	for y := 0; y < cameraHeight; y++ {
+		for x := 0; x < cameraWidth; x++ {
+			value := int32(sensor.GrayAt(x, y).Y) * exposure / cameraNeutralExposure
+			brightness[y*cameraWidth+x] = value * gain / cameraGainSteps
+		}
+	}
+
+	for y := 0; y < cameraHeight; y++ {
+		for x := 0; x < cameraWidth; x++ {
+			value := c.edgeEnhance(brightness, x, y)
+			if c.registers[cameraEdge]&cameraInvert == cameraInvert {
+				value = 0xFF - min(value, 0xFF)
+			}
+			value = max(0, min(value, 0xFF))
+
+			matrix := cameraMatrix + ((y%4)*4+(x%4))*3
+			shade := uint8(0)
+			switch {
+			case value < int32(c.registers[matrix]):
+				shade = 3
+			case value < int32(c.registers[matrix+1]):
+				shade = 2
+			case value < int32(c.registers[matrix+2]):
+				shade = 1
+			}
+
+			tile := (y/8)*(cameraWidth/8) + x/8
+			row := tile*16 + (y%8)*2
+			bit := uint8(7 - x%8)
+			tiles[row] = SetBit(tiles[row], bit, shade&0x01 == 0x01)
+			tiles[row+1] = SetBit(tiles[row+1], bit, shade&0x02 == 0x02)
+		}
+	}
+
+	return tiles
+}
+
+// Adds the difference from the neighbouring pixels in the directions picked
+// by the edge mode, scaled by the ratio. In extraction mode only the
+// difference is kept. Pixels on the border use themselves for the missing
+// neighbours.
+func (c *cartridgeCamera) edgeEnhance(brightness []int32, x int, y int) int32 {
+	value := brightness[y*cameraWidth+x]
+	at := func(x int, y int) int32 {
+		x = max(0, min(x, cameraWidth-1))
+		y = max(0, min(y, cameraHeight-1))
+		return brightness[y*cameraWidth+x]
+	}
+
+	var edge int32
+	mode := (c.registers[cameraGain] & cameraEdgeModeMask) >> cameraEdgeModeShift
+	if mode == cameraEdgeHorizontal || mode == cameraEdgeBoth {
+		edge += 2*value - at(x-1, y) - at(x+1, y)
+	}
+	if mode == cameraEdgeVertical || mode == cameraEdgeBoth {
+		edge += 2*value - at(x, y-1) - at(x, y+1)
+	}
+
+	if mode == cameraEdgeNone {
+		return value
+	}
+
+	ratio := cameraEdgeRatios[(c.registers[cameraEdge]&cameraEdgeRatioMask)>>cameraEdgeRatioShift]
+	edge = edge * ratio / 4
+
+	if c.registers[cameraEdge]&cameraEdgeExtract == cameraEdgeExtract {
+		return edge
+	}
+	return value + edge
+}
+
+func (c *cartridgeCamera) DumpROMCode() (data []uint8, startAddress uint16) {
+
+	// Combine the first and current banks
+	code, startAddress := c.romBanks[0].DumpCode()
+	currentCode, _ := c.romBanks[c.romBank()].DumpCode()
+	code = append(code, currentCode...)
+
+	return code, startAddress
+}
+
+func (c *cartridgeCamera) DumpRAMCode() (data []uint8, startAddress uint16) {
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
+}
+
+func (c *cartridgeCamera) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.romBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.romBanks[bank].DumpCode()
+}
+
+func (c *cartridgeCamera) DumpRAMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.ramBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.ramBanks[uint16(bank)].DumpCode()
+}
+
+func (c *cartridgeCamera) CurrentROMBank() uint16 {
+	return c.romBank()
+}
+
+func (c *cartridgeCamera) CurrentRAMBank() uint8 {
+	return c.ramBank()
+}
+
+func (c *cartridgeCamera) memoryBank(address uint16) *Memory {
+	if address >= c.ramStart && address <= 0xBFFF {
+		bankNumber := c.ramBank()
+		bank, exists := c.ramBanks[uint16(bankNumber)]
+		if !exists {
+			panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
+		}
+		return bank
+	}
+
+	// If the address is in the first bank that isn't switchable
+	if address < 0x4000 {
+		return c.romBanks[0]
+	}
+
+	bankNumber := c.romBank()
+	bank, exists := c.romBanks[bankNumber]
+	if !exists {
+		panic(fmt.Sprintf("Cartridge ROM bank %d doesn't exist", bankNumber))
+	}
+	return bank
+}
+
+func (c *cartridgeCamera) isRamEnabled() bool {
+	// Lower 4 bits must be A
+	return (0b00001111 & c.ramEnable) == 0x0A
+}
+
+func (c *cartridgeCamera) isRegisterSelected() bool {
+	return c.ramBankNumber&cameraRegisterSelect == cameraRegisterSelect
+}
+
+func (c *cartridgeCamera) romBank() uint16 {
+	bank := c.romBankNumber
+	if bank == 0 {
+		bank = 1
+	}
+
+	return wrapBank(c.romBanks, uint16(bank))
+}
+
+func (c *cartridgeCamera) ramBank() uint8 {
+	return uint8(wrapBank(c.ramBanks, uint16(c.ramBankNumber&0x0F)))
+}
+
+func (c *cartridgeCamera) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return joinBanks(c.ramBanks)
+}
+
+func (c *cartridgeCamera) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	_, err := fillBanks(c.ramBanks, data)
+	return err
+}
+
+type cartridgeCameraState struct {
+	RAMEnable     uint8
+	ROMBankNumber uint8
+	RAMBankNumber uint8
+	RAM           [][]uint8
+	Registers     [cameraRegisterCount]uint8
+	CaptureCycles uint
+	Capture       []uint8
+}
+
+func (c *cartridgeCamera) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeCameraState{
+		RAMEnable:     c.ramEnable,
+		ROMBankNumber: c.romBankNumber,
+		RAMBankNumber: c.ramBankNumber,
+		RAM:           saveBanks(c.ramBanks),
+		Registers:     c.registers,
+		CaptureCycles: c.captureCycles,
+		Capture:       c.capture,
+	})
+}
+
+func (c *cartridgeCamera) LoadState(dec *gob.Decoder) error {
+	var state cartridgeCameraState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.ramEnable = state.RAMEnable
+	c.romBankNumber = state.ROMBankNumber
+	c.ramBankNumber = state.RAMBankNumber
+	c.registers = state.Registers
+	c.captureCycles = state.CaptureCycles
+	c.capture = state.Capture
+	return nil
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestCamera(source image.Image) *cartridgeCamera {
+	rom := make([]byte, 0x8000)
+	c := createCartridgeCamera(uint32(len(rom)), 0x2000, &rom, false).(*cartridgeCamera)
+	c.SetImageSource(CreateStaticImageSource(source))
+
+	c.WriteByte(0x4000, cameraRegisterSelect)
+	c.WriteByte(0xA000+cameraExposureHigh, uint8(cameraNeutralExposure>>8))
+	c.WriteByte(0xA000+cameraExposureLow, uint8(cameraNeutralExposure&0xFF))
+
+	// The same thresholds for every pixel
+	for x := uint16(0); x < 16; x++ {
+		c.WriteByte(0xA000+cameraMatrix+x*3, 0x40)
+		c.WriteByte(0xA000+cameraMatrix+x*3+1, 0x90)
+		c.WriteByte(0xA000+cameraMatrix+x*3+2, 0xC0)
+	}
+	return c
+}
+
+func greyImage(value uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, cameraWidth, cameraHeight))
+	for y := 0; y < cameraHeight; y++ {
+		for x := 0; x < cameraWidth; x++ {
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+// Takes a photo and returns the 2bpp tiles written to RAM
+func capturePhoto(c *cartridgeCamera) []uint8 {
+	c.WriteByte(0x4000, cameraRegisterSelect)
+	c.WriteByte(0xA000+cameraControl, cameraCaptureBit)
+	c.UpdateForCycles(c.captureCycles)
+
+	c.WriteByte(0x4000, 0x00)
+	tiles := make([]uint8, (cameraWidth/8)*(cameraHeight/8)*16)
+	for x := range tiles {
+		tiles[x] = c.ReadByte(cameraImageAddress + uint16(x))
+	}
+	return tiles
+}
+
+// Both bit planes of a tile row for a whole row of pixels in one shade
+func shadeRow(shade uint8) []uint8 {
+	var low, high uint8
+	if shade&0x01 == 0x01 {
+		low = 0xFF
+	}
+	if shade&0x02 == 0x02 {
+		high = 0xFF
+	}
+	return []uint8{low, high}
+}
+
+func assertAllShade(t *testing.T, tiles []uint8, shade uint8) {
+	expected := shadeRow(shade)
+	for x := 0; x < len(tiles); x += 2 {
+		if !assert.Equal(t, expected, tiles[x:x+2], "Tile row at 0x%04X", cameraImageAddress+x) {
+			return
+		}
+	}
+}
+
+func TestCameraCaptureWrites2bppTiles(t *testing.T) {
+	c := createTestCamera(greyImage(0x80))
+	tiles := capturePhoto(c)
+
+	assert.Equal(t, uint8(0x00), c.ReadByte(0xA0FF))
+	assertAllShade(t, tiles, 2)
+}
+
+func TestCameraCaptureGain(t *testing.T) {
+	c := createTestCamera(greyImage(0x80))
+	c.WriteByte(0xA000+cameraGain, cameraGainMask)
+
+	assertAllShade(t, capturePhoto(c), 0)
+}
+
+func TestCameraCaptureInvert(t *testing.T) {
+	c := createTestCamera(greyImage(0x20))
+	c.WriteByte(0xA000+cameraEdge, cameraInvert)
+
+	assertAllShade(t, capturePhoto(c), 0)
+}
+
+func TestCameraCaptureEdgeEnhancement(t *testing.T) {
+	// Left half is darker than the right
+	img := image.NewGray(image.Rect(0, 0, cameraWidth, cameraHeight))
+	for y := 0; y < cameraHeight; y++ {
+		for x := 0; x < cameraWidth; x++ {
+			value := uint8(0x80)
+			if x >= cameraWidth/2 {
+				value = 0xA0
+			}
+			img.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+
+	c := createTestCamera(img)
+	plain := capturePhoto(c)
+
+	// Horizontal enhancement at 300%
+	c.WriteByte(0x4000, cameraRegisterSelect)
+	c.WriteByte(0xA000+cameraGain, cameraEdgeHorizontal<<cameraEdgeModeShift)
+	c.WriteByte(0xA000+cameraEdge, 5<<cameraEdgeRatioShift)
+	enhanced := capturePhoto(c)
+
+	// Pixels either side of the edge in the first row of tiles 7 and 8
+	left := 7 * 16
+	right := 8 * 16
+	assert.Equal(t, uint8(0x00), plain[left]&0x01)
+	assert.Equal(t, uint8(0x01), plain[left+1]&0x01)
+	assert.Equal(t, uint8(0x80), plain[right]&0x80)
+	assert.Equal(t, uint8(0x00), plain[right+1]&0x80)
+
+	// The darker side gets darker and the lighter side lighter
+	assert.Equal(t, uint8(0x01), enhanced[left]&0x01)
+	assert.Equal(t, uint8(0x01), enhanced[left+1]&0x01)
+	assert.Equal(t, uint8(0x00), enhanced[right]&0x80)
+	assert.Equal(t, uint8(0x00), enhanced[right+1]&0x80)
+
+	// Away from the edge nothing changes
+	assert.Equal(t, plain[:left], enhanced[:left])
+}
@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"encoding/gob"
+	"fmt"
+)
+
+// Writing this to 0x0000-0x1FFF maps the infrared port to 0xA000-0xBFFF,
+// anything else maps RAM
+const hucInfraredSelect = 0x0E
+
+// Reading the infrared port with no light seen
+const hucInfraredNoLight = 0xC0
+
+type cartridgeHuC1 struct {
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
+
+	infraredSelect bool
+	infraredLED    bool
+	romBankNumber  uint8
+	ramBankNumber  uint8
+	ramStart       uint16
+
+	battery bool
+}
+
+func createCartridgeHuC1(romSize uint32, ramSize uint32, data *[]byte, battery bool) Cartridge {
+	ram := make([]byte, ramSize)
+	return &cartridgeHuC1{
+		romBanks:       splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBanks:       splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
+		infraredSelect: false,
+		infraredLED:    false,
+		romBankNumber:  0x01,
+		ramBankNumber:  0x00,
+		ramStart:       0xA000,
+		battery:        battery,
+	}
+}
+
+func (c *cartridgeHuC1) Reset() {
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
+	}
+	// Don't reset the memory because that is the rom game
+
+	c.infraredSelect = false
+	c.infraredLED = false
+	c.romBankNumber = 0x01
+	c.ramBankNumber = 0x00
+}
+
+func (c *cartridgeHuC1) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *cartridgeHuC1) ReadByte(address uint16) byte {
+	if address >= c.ramStart {
+		if c.infraredSelect {
+			return hucInfraredNoLight
+		}
+
+		if len(c.ramBanks) == 0 {
+			return 0xFF
+		}
+	}
+
+	return c.memoryBank(address).ReadByte(address)
+}
+
+func (c *cartridgeHuC1) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *cartridgeHuC1) WriteBit(address uint16, bit uint8, value bool) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
+}
+
+func (c *cartridgeHuC1) WriteByte(address uint16, value byte) {
+	if address <= 0x7FFF {
+		if address <= 0x1FFF {
+			// There is no RAM enable, RAM is mapped unless infrared is
+			c.infraredSelect = value&0x0F == hucInfraredSelect
+		} else if address <= 0x3FFF {
+			c.romBankNumber = value & 0b00111111
+		} else if address <= 0x5FFF {
+			c.ramBankNumber = value & 0b00000011
+		}
+
+		return
+	}
+
+	if c.infraredSelect {
+		c.infraredLED = value&0x01 == 0x01
+		return
+	}
+
+	if len(c.ramBanks) == 0 {
+		return
+	}
+
+	c.memoryBank(address).WriteByte(address, value)
+}
+
+func (c *cartridgeHuC1) WriteShort(address uint16, value uint16) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
+
+func (c *cartridgeHuC1) DumpROMCode() (data []uint8, startAddress uint16) {
+
+	// Combine the first and current banks
+	code, startAddress := c.romBanks[0].DumpCode()
+	currentCode, _ := c.romBanks[c.romBank()].DumpCode()
+	code = append(code, currentCode...)
+
+	return code, startAddress
+}
+
+func (c *cartridgeHuC1) DumpRAMCode() (data []uint8, startAddress uint16) {
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
+}
+
+func (c *cartridgeHuC1) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.romBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.romBanks[bank].DumpCode()
+}
+
+func (c *cartridgeHuC1) DumpRAMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.ramBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.ramBanks[uint16(bank)].DumpCode()
+}
+
+func (c *cartridgeHuC1) CurrentROMBank() uint16 {
+	return c.romBank()
+}
+
+func (c *cartridgeHuC1) CurrentRAMBank() uint8 {
+	return c.ramBank()
+}
+
+func (c *cartridgeHuC1) memoryBank(address uint16) *Memory {
+	if address >= c.ramStart && address <= 0xBFFF {
+		bankNumber := c.ramBank()
+		bank, exists := c.ramBanks[uint16(bankNumber)]
+		if !exists {
+			panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
+		}
+		return bank
+	}
+
+	// If the address is in the first bank that isn't switchable
+	if address < 0x4000 {
+		return c.romBanks[0]
+	}
+
+	bankNumber := c.romBank()
+	bank, exists := c.romBanks[bankNumber]
+	if !exists {
+		panic(fmt.Sprintf("Cartridge ROM bank %d doesn't exist", bankNumber))
+	}
+	return bank
+}
+
+func (c *cartridgeHuC1) romBank() uint16 {
+	bank := c.romBankNumber
+	if bank == 0 {
+		bank = 1
+	}
+
+	return wrapBank(c.romBanks, uint16(bank))
+}
+
+func (c *cartridgeHuC1) ramBank() uint8 {
+	return uint8(wrapBank(c.ramBanks, uint16(c.ramBankNumber)))
+}
+
+func (c *cartridgeHuC1) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return joinBanks(c.ramBanks)
+}
+
+func (c *cartridgeHuC1) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	_, err := fillBanks(c.ramBanks, data)
+	return err
+}
+
+type cartridgeHuC1State struct {
+	InfraredSelect bool
+	InfraredLED    bool
+	ROMBankNumber  uint8
+	RAMBankNumber  uint8
+	RAM            [][]uint8
+}
+
+func (c *cartridgeHuC1) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeHuC1State{
+		InfraredSelect: c.infraredSelect,
+		InfraredLED:    c.infraredLED,
+		ROMBankNumber:  c.romBankNumber,
+		RAMBankNumber:  c.ramBankNumber,
+		RAM:            saveBanks(c.ramBanks),
+	})
+}
+
+func (c *cartridgeHuC1) LoadState(dec *gob.Decoder) error {
+	var state cartridgeHuC1State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.infraredSelect = state.InfraredSelect
+	c.infraredLED = state.InfraredLED
+	c.romBankNumber = state.ROMBankNumber
+	c.ramBankNumber = state.RAMBankNumber
+	return nil
+}
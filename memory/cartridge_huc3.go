@@ -0,0 +1,421 @@
+package memory
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
+
+// Values written to 0x0000-0x1FFF select what is mapped at 0xA000-0xBFFF
+const (
+	huc3RAMReadOnly    = 0x00
+	huc3RAM            = 0x0A
+	huc3RTCCommand     = 0x0B
+	huc3RTCResponse    = 0x0C
+	huc3RTCSemaphore   = 0x0D
+	huc3InfraredSelect = hucInfraredSelect
+)
+
+// RTC commands are the upper nibble of the value written in command mode and
+// the argument is the lower nibble
+const (
+	huc3CommandRead       = 0x1
+	huc3CommandWrite      = 0x3
+	huc3CommandAddressLow = 0x4
+	huc3CommandAddressHi  = 0x5
+	huc3CommandExtended   = 0x6
+)
+
+// Extended command arguments
+const (
+	huc3ExtendedTimeToMemory = 0x0
+	huc3ExtendedMemoryToTime = 0x1
+	huc3ExtendedStatus       = 0x2
+)
+
+// The clock counts minutes in the day and days. The game reads and writes
+// them as nibbles at the start of the RTC memory.
+const huc3MinutesPerDay = 60 * 24
+const huc3DaysMask = 0xFFF
+const huc3CyclesPerMinute = rtcCyclesPerSecond * 60
+const huc3TimeNibbles = 6
+
+// The RTC block at the end of .sav files is the minutes and days followed by
+// the unix time it was saved at
+const huc3BlockSize = 16
+
+type huc3Clock struct {
+	minutes      uint16
+	days         uint16
+	cycleCounter uint
+
+	memory   [256]uint8
+	address  uint8
+	command  uint8
+	response uint8
+}
+
+func (h *huc3Clock) update(cycles uint) {
+	h.cycleCounter += cycles
+	for h.cycleCounter >= huc3CyclesPerMinute {
+		h.cycleCounter -= huc3CyclesPerMinute
+		h.advance(1)
+	}
+}
+
+func (h *huc3Clock) advance(minutes int64) {
+	if minutes <= 0 {
+		return
+	}
+
+	total := int64(h.minutes) + minutes
+	h.minutes = uint16(total % huc3MinutesPerDay)
+	h.days = uint16((int64(h.days) + total/huc3MinutesPerDay) & huc3DaysMask)
+}
+
+func (h *huc3Clock) execute(value uint8) {
+	h.command = value >> 4
+	argument := value & 0x0F
+
+	switch h.command {
+	case huc3CommandRead:
+		h.response = h.memory[h.address]
+		h.address++
+	case huc3CommandWrite:
+		h.memory[h.address] = argument
+		h.address++
+	case huc3CommandAddressLow:
+		h.address = (h.address & 0xF0) | argument
+	case huc3CommandAddressHi:
+		h.address = (h.address & 0x0F) | argument<<4
+	case huc3CommandExtended:
+		switch argument {
+		case huc3ExtendedTimeToMemory:
+			time := uint32(h.days)<<12 | uint32(h.minutes)
+			for x := 0; x < huc3TimeNibbles; x++ {
+				h.memory[x] = uint8(time>>(x*4)) & 0x0F
+			}
+		case huc3ExtendedMemoryToTime:
+			var time uint32
+			for x := 0; x < huc3TimeNibbles; x++ {
+				time |= uint32(h.memory[x]&0x0F) << (x * 4)
+			}
+			h.minutes = uint16(time&0xFFF) % huc3MinutesPerDay
+			h.days = uint16(time>>12) & huc3DaysMask
+			h.cycleCounter = 0
+		case huc3ExtendedStatus:
+			h.response = 0x01
+		}
+		// The rest of the extended commands play tones through the cartridge
+		// speaker which isn't emulated
+	}
+}
+
+func (h *huc3Clock) exportBlock(now int64) []uint8 {
+	data := make([]uint8, huc3BlockSize)
+	binary.LittleEndian.PutUint32(data[0:], uint32(h.minutes))
+	binary.LittleEndian.PutUint32(data[4:], uint32(h.days))
+	binary.LittleEndian.PutUint64(data[8:], uint64(now))
+	return data
+}
+
+// Loads the time and adds on the time since the block was saved
+func (h *huc3Clock) importBlock(data []uint8, now int64) error {
+	if len(data) != huc3BlockSize {
+		return errors.New(fmt.Sprintf("HuC3 RTC block is %d bytes but expected %d", len(data), huc3BlockSize))
+	}
+
+	h.minutes = uint16(binary.LittleEndian.Uint32(data[0:])) % huc3MinutesPerDay
+	h.days = uint16(binary.LittleEndian.Uint32(data[4:])) & huc3DaysMask
+	timestamp := int64(binary.LittleEndian.Uint64(data[8:]))
+
+	h.advance((now - timestamp) / 60)
+	h.cycleCounter = 0
+
+	return nil
+}
+
+type cartridgeHuC3 struct {
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
+
+	mode          uint8
+	infraredLED   bool
+	romBankNumber uint8
+	ramBankNumber uint8
+	ramStart      uint16
+
+	battery bool
+	rtc     huc3Clock
+	clock   ClockSource
+}
+
+func createCartridgeHuC3(romSize uint32, ramSize uint32, data *[]byte, battery bool) Cartridge {
+	ram := make([]byte, ramSize)
+	return &cartridgeHuC3{
+		romBanks:      splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBanks:      splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
+		mode:          huc3RAMReadOnly,
+		infraredLED:   false,
+		romBankNumber: 0x01,
+		ramBankNumber: 0x00,
+		ramStart:      0xA000,
+		battery:       battery,
+		clock:         systemClock{},
+	}
+}
+
+func (c *cartridgeHuC3) Reset() {
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
+	}
+	// Don't reset the memory because that is the rom game
+	// The clock keeps running from the battery
+
+	c.mode = huc3RAMReadOnly
+	c.infraredLED = false
+	c.romBankNumber = 0x01
+	c.ramBankNumber = 0x00
+}
+
+func (c *cartridgeHuC3) SetClockSource(clock ClockSource) {
+	c.clock = clock
+}
+
+func (c *cartridgeHuC3) UpdateForCycles(cycles uint) {
+	c.rtc.update(cycles)
+}
+
+func (c *cartridgeHuC3) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *cartridgeHuC3) ReadByte(address uint16) byte {
+	if address >= c.ramStart {
+		switch c.mode {
+		case huc3RAMReadOnly, huc3RAM:
+			if len(c.ramBanks) == 0 {
+				return 0xFF
+			}
+		case huc3RTCResponse:
+			return c.rtc.command<<4 | c.rtc.response&0x0F
+		case huc3RTCSemaphore:
+			// Always ready
+			return 0xFF
+		case huc3InfraredSelect:
+			return hucInfraredNoLight
+		default:
+			return 0xFF
+		}
+	}
+
+	return c.memoryBank(address).ReadByte(address)
+}
+
+func (c *cartridgeHuC3) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *cartridgeHuC3) WriteBit(address uint16, bit uint8, value bool) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
+}
+
+func (c *cartridgeHuC3) WriteByte(address uint16, value byte) {
+	if address <= 0x7FFF {
+		if address <= 0x1FFF {
+			c.mode = value & 0x0F
+		} else if address <= 0x3FFF {
+			c.romBankNumber = value & 0b01111111
+		} else if address <= 0x5FFF {
+			c.ramBankNumber = value & 0b00001111
+		}
+
+		return
+	}
+
+	switch c.mode {
+	case huc3RAM:
+		if len(c.ramBanks) == 0 {
+			return
+		}
+		c.memoryBank(address).WriteByte(address, value)
+	case huc3RTCCommand:
+		c.rtc.execute(value)
+	case huc3InfraredSelect:
+		c.infraredLED = value&0x01 == 0x01
+	}
+}
+
+func (c *cartridgeHuC3) WriteShort(address uint16, value uint16) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
+
+func (c *cartridgeHuC3) DumpROMCode() (data []uint8, startAddress uint16) {
+
+	// Combine the first and current banks
+	code, startAddress := c.romBanks[0].DumpCode()
+	currentCode, _ := c.romBanks[c.romBank()].DumpCode()
+	code = append(code, currentCode...)
+
+	return code, startAddress
+}
+
+func (c *cartridgeHuC3) DumpRAMCode() (data []uint8, startAddress uint16) {
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
+}
+
+func (c *cartridgeHuC3) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.romBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.romBanks[bank].DumpCode()
+}
+
+func (c *cartridgeHuC3) DumpRAMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.ramBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.ramBanks[uint16(bank)].DumpCode()
+}
+
+func (c *cartridgeHuC3) CurrentROMBank() uint16 {
+	return c.romBank()
+}
+
+func (c *cartridgeHuC3) CurrentRAMBank() uint8 {
+	return c.ramBank()
+}
+
+func (c *cartridgeHuC3) memoryBank(address uint16) *Memory {
+	if address >= c.ramStart && address <= 0xBFFF {
+		bankNumber := c.ramBank()
+		bank, exists := c.ramBanks[uint16(bankNumber)]
+		if !exists {
+			panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
+		}
+		return bank
+	}
+
+	// If the address is in the first bank that isn't switchable
+	if address < 0x4000 {
+		return c.romBanks[0]
+	}
+
+	bankNumber := c.romBank()
+	bank, exists := c.romBanks[bankNumber]
+	if !exists {
+		panic(fmt.Sprintf("Cartridge ROM bank %d doesn't exist", bankNumber))
+	}
+	return bank
+}
+
+func (c *cartridgeHuC3) romBank() uint16 {
+	bank := c.romBankNumber
+	if bank == 0 {
+		bank = 1
+	}
+
+	return wrapBank(c.romBanks, uint16(bank))
+}
+
+func (c *cartridgeHuC3) ramBank() uint8 {
+	return uint8(wrapBank(c.ramBanks, uint16(c.ramBankNumber)))
+}
+
+// The RTC block is after the RAM
+func (c *cartridgeHuC3) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return append(joinBanks(c.ramBanks), c.rtc.exportBlock(c.clock.Now().Unix())...)
+}
+
+func (c *cartridgeHuC3) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	remaining, err := fillBanks(c.ramBanks, data)
+	if err != nil {
+		return err
+	}
+
+	// Files without the RTC block leave the clock as it is
+	if len(remaining) == 0 {
+		return nil
+	}
+
+	return c.rtc.importBlock(remaining, c.clock.Now().Unix())
+}
+
+type cartridgeHuC3State struct {
+	Mode            uint8
+	InfraredLED     bool
+	ROMBankNumber   uint8
+	RAMBankNumber   uint8
+	RAM             [][]uint8
+	RTCMinutes      uint16
+	RTCDays         uint16
+	RTCCycleCounter uint
+	RTCMemory       [256]uint8
+	RTCAddress      uint8
+	RTCCommand      uint8
+	RTCResponse     uint8
+}
+
+func (c *cartridgeHuC3) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeHuC3State{
+		Mode:            c.mode,
+		InfraredLED:     c.infraredLED,
+		ROMBankNumber:   c.romBankNumber,
+		RAMBankNumber:   c.ramBankNumber,
+		RAM:             saveBanks(c.ramBanks),
+		RTCMinutes:      c.rtc.minutes,
+		RTCDays:         c.rtc.days,
+		RTCCycleCounter: c.rtc.cycleCounter,
+		RTCMemory:       c.rtc.memory,
+		RTCAddress:      c.rtc.address,
+		RTCCommand:      c.rtc.command,
+		RTCResponse:     c.rtc.response,
+	})
+}
+
+func (c *cartridgeHuC3) LoadState(dec *gob.Decoder) error {
+	var state cartridgeHuC3State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.mode = state.Mode
+	c.infraredLED = state.InfraredLED
+	c.romBankNumber = state.ROMBankNumber
+	c.ramBankNumber = state.RAMBankNumber
+	c.rtc.minutes = state.RTCMinutes
+	c.rtc.days = state.RTCDays
+	c.rtc.cycleCounter = state.RTCCycleCounter
+	c.rtc.memory = state.RTCMemory
+	c.rtc.address = state.RTCAddress
+	c.rtc.command = state.RTCCommand
+	c.rtc.response = state.RTCResponse
+	return nil
+}
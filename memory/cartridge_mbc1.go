@@ -1,19 +1,24 @@
 package memory
 
-import "fmt"
+import (
+	"encoding/gob"
+	"fmt"
+)
 
 type cartridgeMBC1 struct {
-	romBanks map[uint8]*Memory
-	ramBanks map[uint8]*Memory
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
 
 	ramEnable         uint8
 	romBankNumber     uint8
 	ramBankNumber     uint8
 	bankingModeSelect uint8
 	ramStart          uint16
+
+	battery bool
 }
 
-func createCartridgeMBC1(romSize uint32, ramSize uint32, data *[]byte) Cartridge {
+func createCartridgeMBC1(romSize uint32, ramSize uint32, data *[]byte, battery bool) Cartridge {
 
 	if romSize >= M_1MiB {
 		panic("Not implemented version of MBC1")
@@ -21,19 +26,23 @@ func createCartridgeMBC1(romSize uint32, ramSize uint32, data *[]byte) Cartridge
 
 	ram := make([]byte, ramSize)
 	return &cartridgeMBC1{
-		romBanks:          splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM"),
-		ramBanks:          splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM"),
+		romBanks:          splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBanks:          splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
 		ramEnable:         0x00,
 		romBankNumber:     0x01,
 		ramBankNumber:     0x00,
 		bankingModeSelect: 0x00,
 		ramStart:          0xA000,
+		battery:           battery,
 	}
 }
 
 func (c *cartridgeMBC1) Reset() {
-	for k, _ := range c.ramBanks {
-		c.ramBanks[k].Reset()
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k, _ := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
 	}
 	// Don't reset the memory because that is the rom game
 
@@ -132,10 +141,10 @@ func (c *cartridgeMBC1) DumpROMCode() (data []uint8, startAddress uint16) {
 }
 
 func (c *cartridgeMBC1) DumpRAMCode() (data []uint8, startAddress uint16) {
-	return c.ramBanks[c.ramBank()].DumpCode()
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
 }
 
-func (c *cartridgeMBC1) DumpROMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+func (c *cartridgeMBC1) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
 	if int(bank) > len(c.romBanks)-1 {
 		panic("Invalid bank number for cartridge")
 	}
@@ -146,10 +155,10 @@ func (c *cartridgeMBC1) DumpRAMBankCode(bank uint8) (data []uint8, startAddress
 	if int(bank) > len(c.ramBanks)-1 {
 		panic("Invalid bank number for cartridge")
 	}
-	return c.ramBanks[bank].DumpCode()
+	return c.ramBanks[uint16(bank)].DumpCode()
 }
 
-func (c *cartridgeMBC1) CurrentROMBank() uint8 {
+func (c *cartridgeMBC1) CurrentROMBank() uint16 {
 	return c.romBank()
 }
 
@@ -159,7 +168,7 @@ func (c *cartridgeMBC1) CurrentRAMBank() uint8 {
 func (c *cartridgeMBC1) memoryBank(address uint16) *Memory {
 	if address >= c.ramStart && address <= 0xBFFF {
 		bankNumber := c.ramBank()
-		bank, exists := c.ramBanks[bankNumber]
+		bank, exists := c.ramBanks[uint16(bankNumber)]
 		if !exists {
 			panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
 		}
@@ -180,11 +189,16 @@ func (c *cartridgeMBC1) memoryBank(address uint16) *Memory {
 }
 
 func (c *cartridgeMBC1) isRamEnabled() bool {
+	// Cartridges without RAM behave as if it is always disabled
+	if len(c.ramBanks) == 0 {
+		return false
+	}
+
 	// Lower 4 bits must be A
 	return (0b00001111 & c.ramEnable) == 0x0A
 }
 
-func (c *cartridgeMBC1) romBank() uint8 {
+func (c *cartridgeMBC1) romBank() uint16 {
 	bank := (0b00011111 & c.romBankNumber)
 
 	// TODO - if bank number is higher need to ignore some bits and allow
@@ -193,7 +207,7 @@ func (c *cartridgeMBC1) romBank() uint8 {
 		bank = 1
 	}
 
-	return bank
+	return uint16(bank)
 }
 
 func (c *cartridgeMBC1) ramBank() uint8 {
@@ -201,3 +215,55 @@ func (c *cartridgeMBC1) ramBank() uint8 {
 	bank := (0b00000011 & c.ramBankNumber)
 	return bank
 }
+
+func (c *cartridgeMBC1) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return joinBanks(c.ramBanks)
+}
+
+func (c *cartridgeMBC1) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	_, err := fillBanks(c.ramBanks, data)
+	return err
+}
+
+type cartridgeMBC1State struct {
+	RAMEnable         uint8
+	ROMBankNumber     uint8
+	RAMBankNumber     uint8
+	BankingModeSelect uint8
+	RAM               [][]uint8
+}
+
+func (c *cartridgeMBC1) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeMBC1State{
+		RAMEnable:         c.ramEnable,
+		ROMBankNumber:     c.romBankNumber,
+		RAMBankNumber:     c.ramBankNumber,
+		BankingModeSelect: c.bankingModeSelect,
+		RAM:               saveBanks(c.ramBanks),
+	})
+}
+
+func (c *cartridgeMBC1) LoadState(dec *gob.Decoder) error {
+	var state cartridgeMBC1State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.ramEnable = state.RAMEnable
+	c.romBankNumber = state.ROMBankNumber
+	c.ramBankNumber = state.RAMBankNumber
+	c.bankingModeSelect = state.BankingModeSelect
+	return nil
+}
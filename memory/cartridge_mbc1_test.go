@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestMBC1(ramSize uint32) *cartridgeMBC1 {
+	rom := make([]byte, 0x10000)
+	return createCartridgeMBC1(uint32(len(rom)), ramSize, &rom, false).(*cartridgeMBC1)
+}
+
+func TestMBC1WithoutRAM(t *testing.T) {
+	c := createTestMBC1(0)
+
+	// Enabling RAM that isn't there leaves it reading as open bus
+	c.WriteByte(0x0000, 0x0A)
+	assert.NotPanics(t, func() { c.WriteByte(0xA000, 0x12) })
+	assert.Equal(t, uint8(0xFF), c.ReadByte(0xA000))
+	assert.Equal(t, uint16(0xFFFF), c.ReadShort(0xBFFE))
+	assert.True(t, c.ReadBit(0xA000, 0))
+}
+
+func TestMBC1RAMEnable(t *testing.T) {
+	c := createTestMBC1(0x2000)
+
+	c.WriteByte(0xA000, 0x12)
+	assert.Equal(t, uint8(0xFF), c.ReadByte(0xA000))
+
+	// Only the lower 4 bits are checked
+	c.WriteByte(0x0000, 0x1A)
+	c.WriteByte(0xA000, 0x12)
+	assert.Equal(t, uint8(0x12), c.ReadByte(0xA000))
+
+	c.WriteByte(0x0000, 0x00)
+	assert.Equal(t, uint8(0xFF), c.ReadByte(0xA000))
+}
@@ -0,0 +1,266 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// MBC1 multicarts hold several games in one 1MiB ROM. The upper bank bits
+// select the game so each game is 0x40000 bytes and has its own header.
+const mbc1MulticartGameSize = 0x40000
+
+// The Nintendo logo in the header is checked by the boot ROM
+const nintendoLogoStart = 0x0104
+const nintendoLogoEnd = 0x0133
+
+// There is nothing in the header to tell multicarts from other MBC1 carts so
+// look for the logo at the start of each game as well as the first one.
+func isMBC1Multicart(data *[]byte) bool {
+	if len(*data) != M_1MiB {
+		return false
+	}
+
+	logo := (*data)[nintendoLogoStart : nintendoLogoEnd+1]
+	games := 0
+	for x := mbc1MulticartGameSize; x < len(*data); x += mbc1MulticartGameSize {
+		if bytes.Equal(logo, (*data)[x+nintendoLogoStart:x+nintendoLogoEnd+1]) {
+			games++
+		}
+	}
+
+	return games > 0
+}
+
+type cartridgeMBC1M struct {
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
+
+	ramEnable         uint8
+	romBankNumber     uint8
+	upperBankNumber   uint8
+	bankingModeSelect uint8
+	ramStart          uint16
+
+	battery bool
+}
+
+func createCartridgeMBC1M(romSize uint32, ramSize uint32, data *[]byte, battery bool) Cartridge {
+	ram := make([]byte, ramSize)
+	return &cartridgeMBC1M{
+		romBanks:          splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBanks:          splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
+		ramEnable:         0x00,
+		romBankNumber:     0x01,
+		upperBankNumber:   0x00,
+		bankingModeSelect: 0x00,
+		ramStart:          0xA000,
+		battery:           battery,
+	}
+}
+
+func (c *cartridgeMBC1M) Reset() {
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
+	}
+	// Don't reset the memory because that is the rom game
+
+	c.ramEnable = 0x00
+	c.romBankNumber = 0x01
+	c.upperBankNumber = 0x00
+	c.bankingModeSelect = 0x00
+}
+
+func (c *cartridgeMBC1M) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *cartridgeMBC1M) ReadByte(address uint16) byte {
+	if address < 0x4000 {
+		return readBankByte(c.romBanks[c.lowROMBank()], address)
+	}
+
+	if address <= 0x7FFF {
+		return readBankByte(c.romBanks[c.romBank()], address)
+	}
+
+	if !c.isRamEnabled() || len(c.ramBanks) == 0 {
+		return 0xFF
+	}
+
+	return c.memoryBank(address).ReadByte(address)
+}
+
+func (c *cartridgeMBC1M) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *cartridgeMBC1M) WriteBit(address uint16, bit uint8, value bool) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
+}
+
+func (c *cartridgeMBC1M) WriteByte(address uint16, value byte) {
+	if address <= 0x7FFF {
+		if address <= 0x1FFF {
+			c.ramEnable = value
+		} else if address <= 0x3FFF {
+			c.romBankNumber = value & 0b00011111
+		} else if address <= 0x5FFF {
+			c.upperBankNumber = value & 0b00000011
+		} else {
+			c.bankingModeSelect = value & 0b00000001
+		}
+
+		return
+	}
+
+	// If writing to RAM but RAM is disabled do nothing
+	if !c.isRamEnabled() || len(c.ramBanks) == 0 {
+		return
+	}
+
+	c.memoryBank(address).WriteByte(address, value)
+}
+
+func (c *cartridgeMBC1M) WriteShort(address uint16, value uint16) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
+
+func (c *cartridgeMBC1M) DumpROMCode() (data []uint8, startAddress uint16) {
+	return dumpROMArea(c.romBanks[c.lowROMBank()], c.romBanks[c.romBank()])
+}
+
+func (c *cartridgeMBC1M) DumpRAMCode() (data []uint8, startAddress uint16) {
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
+}
+
+func (c *cartridgeMBC1M) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.romBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.romBanks[bank].DumpCode()
+}
+
+func (c *cartridgeMBC1M) DumpRAMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.ramBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.ramBanks[uint16(bank)].DumpCode()
+}
+
+func (c *cartridgeMBC1M) CurrentROMBank() uint16 {
+	return c.romBank()
+}
+
+func (c *cartridgeMBC1M) CurrentRAMBank() uint8 {
+	return c.ramBank()
+}
+
+func (c *cartridgeMBC1M) memoryBank(address uint16) *Memory {
+	bankNumber := c.ramBank()
+	bank, exists := c.ramBanks[uint16(bankNumber)]
+	if !exists {
+		panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
+	}
+	return bank
+}
+
+func (c *cartridgeMBC1M) isRamEnabled() bool {
+	// Lower 4 bits must be A
+	return (0b00001111 & c.ramEnable) == 0x0A
+}
+
+// The upper bank bits are wired to bits 4 and 5 of the ROM bank instead of 5
+// and 6 so bit 4 of the lower register is ignored. The zero check still uses
+// all 5 bits.
+func (c *cartridgeMBC1M) romBank() uint16 {
+	bank := c.romBankNumber
+	if bank == 0 {
+		bank = 1
+	}
+
+	return wrapBank(c.romBanks, uint16(c.upperBankNumber)<<4|uint16(bank&0b00001111))
+}
+
+// In mode 1 the upper bits also select the first bank of the game at 0x0000
+func (c *cartridgeMBC1M) lowROMBank() uint16 {
+	if c.bankingModeSelect == 0 {
+		return 0
+	}
+
+	return wrapBank(c.romBanks, uint16(c.upperBankNumber)<<4)
+}
+
+func (c *cartridgeMBC1M) ramBank() uint8 {
+	if c.bankingModeSelect == 0 || len(c.ramBanks) == 0 {
+		return 0
+	}
+
+	return c.upperBankNumber % uint8(len(c.ramBanks))
+}
+
+func (c *cartridgeMBC1M) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return joinBanks(c.ramBanks)
+}
+
+func (c *cartridgeMBC1M) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	_, err := fillBanks(c.ramBanks, data)
+	return err
+}
+
+type cartridgeMBC1MState struct {
+	RAMEnable         uint8
+	ROMBankNumber     uint8
+	UpperBankNumber   uint8
+	BankingModeSelect uint8
+	RAM               [][]uint8
+}
+
+func (c *cartridgeMBC1M) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeMBC1MState{
+		RAMEnable:         c.ramEnable,
+		ROMBankNumber:     c.romBankNumber,
+		UpperBankNumber:   c.upperBankNumber,
+		BankingModeSelect: c.bankingModeSelect,
+		RAM:               saveBanks(c.ramBanks),
+	})
+}
+
+func (c *cartridgeMBC1M) LoadState(dec *gob.Decoder) error {
+	var state cartridgeMBC1MState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.ramEnable = state.RAMEnable
+	c.romBankNumber = state.ROMBankNumber
+	c.upperBankNumber = state.UpperBankNumber
+	c.bankingModeSelect = state.BankingModeSelect
+	return nil
+}
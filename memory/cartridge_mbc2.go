@@ -1,9 +1,13 @@
 package memory
 
-import "fmt"
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+)
 
 type cartridgeMBC2 struct {
-	romBanks    map[uint8]*Memory
+	romBanks    map[uint16]*Memory
 	ramBank     *Memory
 	romBankMask uint8
 
@@ -11,23 +15,29 @@ type cartridgeMBC2 struct {
 	romBankNumber     uint8
 	bankingModeSelect uint8
 	ramStart          uint16
+
+	battery bool
 }
 
-func createCartridgeMBC2(romSize uint32, ramSize uint32, data *[]byte) Cartridge {
+func createCartridgeMBC2(romSize uint32, ramSize uint32, data *[]byte, battery bool) Cartridge {
 	ram := make([]byte, 512)
 	return &cartridgeMBC2{
-		romBanks:          splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM"),
-		ramBank:           CreateReadOnlyMemory("RAM", &ram, 0xA000),
+		romBanks:          splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBank:           CreateMemory("RAM", &ram, 0xA000),
 		romBankMask:       0b00000111,
 		ramEnable:         0x00,
 		romBankNumber:     0x01,
 		bankingModeSelect: 0x00,
 		ramStart:          0xA000,
+		battery:           battery,
 	}
 }
 
 func (c *cartridgeMBC2) Reset() {
-	c.ramBank.Reset()
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		c.ramBank.Reset()
+	}
 	// Don't reset the memory because that is the rom game
 
 	c.ramEnable = 0x00
@@ -131,7 +141,7 @@ func (c *cartridgeMBC2) DumpRAMCode() (data []uint8, startAddress uint16) {
 	return c.ramBank.DumpCode()
 }
 
-func (c *cartridgeMBC2) DumpROMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+func (c *cartridgeMBC2) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
 	if int(bank) > len(c.romBanks)-1 {
 		panic("Invalid bank number for cartridge")
 	}
@@ -142,7 +152,7 @@ func (c *cartridgeMBC2) DumpRAMBankCode(bank uint8) (data []uint8, startAddress
 	return c.ramBank.DumpCode()
 }
 
-func (c *cartridgeMBC2) CurrentROMBank() uint8 {
+func (c *cartridgeMBC2) CurrentROMBank() uint16 {
 	return c.romBank()
 }
 
@@ -179,14 +189,14 @@ func (c *cartridgeMBC2) isRamEnabled() bool {
 	return (0b00001111 & c.ramEnable) == 0x0A
 }
 
-func (c *cartridgeMBC2) romBank() uint8 {
+func (c *cartridgeMBC2) romBank() uint16 {
 	bank := (c.romBankMask & c.romBankNumber)
 
 	if bank == 0 {
 		bank = 1
 	}
 
-	return bank
+	return uint16(bank)
 }
 
 func (c *cartridgeMBC2) coerceAddressForShadow(address uint16) uint16 {
@@ -203,3 +213,57 @@ func (c *cartridgeMBC2) coerceAddressForShadow(address uint16) uint16 {
 // 	bank := (0b00000011 & c.ramBankNumber)
 // 	return bank
 // }
+
+// Saved as 512 bytes with one 4bit value per byte
+func (c *cartridgeMBC2) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return c.ramBank.saveState()
+}
+
+func (c *cartridgeMBC2) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	if len(data) < c.ramBank.size() {
+		return errors.New(fmt.Sprintf("Battery RAM is %d bytes but expected %d", len(data), c.ramBank.size()))
+	}
+
+	copy(c.ramBank.buffer, data)
+	return nil
+}
+
+type cartridgeMBC2State struct {
+	RAMEnable         uint8
+	ROMBankNumber     uint8
+	BankingModeSelect uint8
+	RAM               []uint8
+}
+
+func (c *cartridgeMBC2) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeMBC2State{
+		RAMEnable:         c.ramEnable,
+		ROMBankNumber:     c.romBankNumber,
+		BankingModeSelect: c.bankingModeSelect,
+		RAM:               c.ramBank.saveState(),
+	})
+}
+
+func (c *cartridgeMBC2) LoadState(dec *gob.Decoder) error {
+	var state cartridgeMBC2State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := c.ramBank.loadState(state.RAM); err != nil {
+		return err
+	}
+
+	c.ramEnable = state.RAMEnable
+	c.romBankNumber = state.ROMBankNumber
+	c.bankingModeSelect = state.BankingModeSelect
+	return nil
+}
@@ -1,50 +1,68 @@
 package memory
 
-import "fmt"
+import (
+	"encoding/gob"
+	"fmt"
+)
 
 type cartridgeMBC3 struct {
-	romBanks map[uint8]*Memory
-	ramBanks map[uint8]*Memory
-
-	ramEnable         uint8
-	romBankNumber     uint8
-	ramBankNumber     uint8
-	bankingModeSelect uint8
-	ramStart          uint16
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
+
+	ramEnable     uint8
+	romBankNumber uint8
+	ramBankNumber uint8
+	ramStart      uint16
+
+	battery  bool
+	hasTimer bool
+	rtc      rtc
+	clock    ClockSource
 }
 
-func createCartridgeMBC3(romSize uint32, ramSize uint32, data *[]byte) Cartridge {
+func createCartridgeMBC3(romSize uint32, ramSize uint32, data *[]byte, battery bool, hasTimer bool) Cartridge {
 
 	ram := make([]byte, ramSize)
 	return &cartridgeMBC3{
-		romBanks:          splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM"),
-		ramBanks:          splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM"),
-		ramEnable:         0x00,
-		romBankNumber:     0x01,
-		ramBankNumber:     0x00,
-		bankingModeSelect: 0x00,
-		ramStart:          0xA000,
+		romBanks:      splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBanks:      splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
+		ramEnable:     0x00,
+		romBankNumber: 0x01,
+		ramBankNumber: 0x00,
+		ramStart:      0xA000,
+		battery:       battery,
+		hasTimer:      hasTimer,
+		clock:         systemClock{},
 	}
 }
 
 func (c *cartridgeMBC3) Reset() {
-	for k, _ := range c.ramBanks {
-		c.ramBanks[k].Reset()
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k, _ := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
 	}
 	// Don't reset the memory because that is the rom game
+	// The clock keeps running from the battery
 
 	c.ramEnable = 0x00
 	c.romBankNumber = 0x01
 	c.ramBankNumber = 0x00
-	c.bankingModeSelect = 0x00
 }
 
-func (c *cartridgeMBC3) ReadBit(address uint16, bit uint8) bool {
-	if address >= c.ramStart && !c.isRamEnabled() {
-		return true
+func (c *cartridgeMBC3) SetClockSource(clock ClockSource) {
+	c.clock = clock
+}
+
+func (c *cartridgeMBC3) UpdateForCycles(cycles uint) {
+	if c.hasTimer {
+		c.rtc.update(cycles)
 	}
+}
 
-	return c.memoryBank(address).ReadBit(address, bit)
+func (c *cartridgeMBC3) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
 }
 
 func (c *cartridgeMBC3) ReadByte(address uint16) byte {
@@ -53,7 +71,14 @@ func (c *cartridgeMBC3) ReadByte(address uint16) byte {
 			return 0xFF
 		}
 
-		if c.isRTCEnabled() {
+		if c.isRTCSelected() {
+			if !c.hasTimer {
+				return 0xFF
+			}
+			return c.rtc.read(c.ramBankNumber - rtcRegisterSelect)
+		}
+
+		if len(c.ramBanks) == 0 {
 			return 0xFF
 		}
 	}
@@ -62,11 +87,9 @@ func (c *cartridgeMBC3) ReadByte(address uint16) byte {
 }
 
 func (c *cartridgeMBC3) ReadShort(address uint16) uint16 {
-	if address >= c.ramStart && !c.isRamEnabled() {
-		return 0xFFFF
-	}
-
-	return c.memoryBank(address).ReadShort(address)
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
 }
 
 func (c *cartridgeMBC3) WriteBit(address uint16, bit uint8, value bool) {
@@ -74,11 +97,7 @@ func (c *cartridgeMBC3) WriteBit(address uint16, bit uint8, value bool) {
 		panic("This shouldn't happen")
 	}
 
-	if address >= c.ramStart && !c.isRamEnabled() {
-		return
-	}
-
-	c.memoryBank(address).WriteBit(address, bit, value)
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
 }
 
 func (c *cartridgeMBC3) WriteByte(address uint16, value byte) {
@@ -90,14 +109,16 @@ func (c *cartridgeMBC3) WriteByte(address uint16, value byte) {
 		if address >= 0x0000 && address <= 0x1FFF {
 			c.ramEnable = value
 		} else if address >= 0x2000 && address <= 0x3FFF {
-			// ignore bits > 0x1F
-			value = 0b00011111 & value
+			// ignore bits > 0x7F
+			value = 0b01111111 & value
 
 			c.romBankNumber = value
 		} else if address >= 0x4000 && address <= 0x5FFF {
 			c.ramBankNumber = value
 		} else if address >= 0x6000 && address <= 0x7FFF {
-			c.bankingModeSelect = value
+			if c.hasTimer {
+				c.rtc.writeLatch(value)
+			}
 		}
 
 		return
@@ -108,19 +129,27 @@ func (c *cartridgeMBC3) WriteByte(address uint16, value byte) {
 		return
 	}
 
-	c.memoryBank(address).WriteByte(address, value)
-}
+	if c.isRTCSelected() {
+		if c.hasTimer {
+			c.rtc.write(c.ramBankNumber-rtcRegisterSelect, value)
+		}
+		return
+	}
 
-func (c *cartridgeMBC3) WriteShort(address uint16, value uint16) {
-	if address >= c.ramStart && !c.isRamEnabled() {
+	if len(c.ramBanks) == 0 {
 		return
 	}
 
+	c.memoryBank(address).WriteByte(address, value)
+}
+
+func (c *cartridgeMBC3) WriteShort(address uint16, value uint16) {
 	if address <= 0x7FFF {
 		panic("This shouldn't happen")
 	}
 
-	c.memoryBank(address).WriteShort(address, value)
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
 }
 
 func (c *cartridgeMBC3) DumpROMCode() (data []uint8, startAddress uint16) {
@@ -134,10 +163,10 @@ func (c *cartridgeMBC3) DumpROMCode() (data []uint8, startAddress uint16) {
 }
 
 func (c *cartridgeMBC3) DumpRAMCode() (data []uint8, startAddress uint16) {
-	return c.ramBanks[c.ramBank()].DumpCode()
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
 }
 
-func (c *cartridgeMBC3) DumpROMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+func (c *cartridgeMBC3) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
 	if int(bank) > len(c.romBanks)-1 {
 		panic("Invalid bank number for cartridge")
 	}
@@ -148,10 +177,10 @@ func (c *cartridgeMBC3) DumpRAMBankCode(bank uint8) (data []uint8, startAddress
 	if int(bank) > len(c.ramBanks)-1 {
 		panic("Invalid bank number for cartridge")
 	}
-	return c.ramBanks[bank].DumpCode()
+	return c.ramBanks[uint16(bank)].DumpCode()
 }
 
-func (c *cartridgeMBC3) CurrentROMBank() uint8 {
+func (c *cartridgeMBC3) CurrentROMBank() uint16 {
 	return c.romBank()
 }
 
@@ -162,7 +191,7 @@ func (c *cartridgeMBC3) CurrentRAMBank() uint8 {
 func (c *cartridgeMBC3) memoryBank(address uint16) *Memory {
 	if address >= c.ramStart && address <= 0xBFFF {
 		bankNumber := c.ramBank()
-		bank, exists := c.ramBanks[bankNumber]
+		bank, exists := c.ramBanks[uint16(bankNumber)]
 		if !exists {
 			panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
 		}
@@ -187,23 +216,106 @@ func (c *cartridgeMBC3) isRamEnabled() bool {
 	return (0b00001111 & c.ramEnable) == 0x0A
 }
 
-func (c *cartridgeMBC3) romBank() uint8 {
+func (c *cartridgeMBC3) romBank() uint16 {
 	bank := (0b01111111 & c.romBankNumber)
 
 	if bank == 0 {
 		bank = 1
 	}
 
-	return bank
+	return uint16(bank)
 }
 
 func (c *cartridgeMBC3) ramBank() uint8 {
+	if len(c.ramBanks) == 0 {
+		return 0
+	}
+
 	// TODO - why???
 	return c.ramBankNumber % uint8(len(c.ramBanks))
 	// bank := (0b00000011 & c.ramBankNumber)
 	// return bank
 }
 
-func (c *cartridgeMBC3) isRTCEnabled() bool {
-	return c.ramBankNumber >= 0x08 && c.ramBankNumber <= 0x0C
+// Selecting RAM banks 0x08-0x0C maps an RTC register to 0xA000-0xBFFF
+const rtcRegisterSelect = 0x08
+
+func (c *cartridgeMBC3) isRTCSelected() bool {
+	return c.ramBankNumber >= rtcRegisterSelect && c.ramBankNumber <= rtcRegisterSelect+rtcDayHigh
+}
+
+// Timer cartridges have the RTC block after the RAM
+func (c *cartridgeMBC3) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	data := joinBanks(c.ramBanks)
+	if c.hasTimer {
+		data = append(data, c.rtc.exportBlock(c.clock.Now().Unix())...)
+	}
+
+	return data
+}
+
+func (c *cartridgeMBC3) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	remaining, err := fillBanks(c.ramBanks, data)
+	if err != nil {
+		return err
+	}
+
+	// Files without the RTC block leave the clock as it is
+	if !c.hasTimer || len(remaining) == 0 {
+		return nil
+	}
+
+	return c.rtc.importBlock(remaining, c.clock.Now().Unix())
+}
+
+type cartridgeMBC3State struct {
+	RAMEnable       uint8
+	ROMBankNumber   uint8
+	RAMBankNumber   uint8
+	RAM             [][]uint8
+	RTCCurrent      [rtcRegisterCount]uint8
+	RTCLatched      [rtcRegisterCount]uint8
+	RTCCycleCounter uint
+	RTCLatchValue   uint8
+}
+
+func (c *cartridgeMBC3) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeMBC3State{
+		RAMEnable:       c.ramEnable,
+		ROMBankNumber:   c.romBankNumber,
+		RAMBankNumber:   c.ramBankNumber,
+		RAM:             saveBanks(c.ramBanks),
+		RTCCurrent:      c.rtc.current,
+		RTCLatched:      c.rtc.latched,
+		RTCCycleCounter: c.rtc.cycleCounter,
+		RTCLatchValue:   c.rtc.latchValue,
+	})
+}
+
+func (c *cartridgeMBC3) LoadState(dec *gob.Decoder) error {
+	var state cartridgeMBC3State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.ramEnable = state.RAMEnable
+	c.romBankNumber = state.ROMBankNumber
+	c.ramBankNumber = state.RAMBankNumber
+	c.rtc.current = state.RTCCurrent
+	c.rtc.latched = state.RTCLatched
+	c.rtc.cycleCounter = state.RTCCycleCounter
+	c.rtc.latchValue = state.RTCLatchValue
+	return nil
 }
@@ -0,0 +1,275 @@
+package memory
+
+import (
+	"encoding/gob"
+	"fmt"
+)
+
+// Rumble cartridges use bit 3 of the RAM bank register for the motor
+const mbc5RumbleBit = 0x08
+
+// Implemented by cartridges with a rumble motor. The callback is called when
+// the motor turns on or off, including when a save state changes it.
+type RumbleCartridge interface {
+	SetRumbleCallback(callback func(on bool))
+}
+
+type cartridgeMBC5 struct {
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
+
+	// Bank 0 mapped into the switchable area
+	romBank0High *Memory
+
+	ramEnable     uint8
+	romBankNumber uint16
+	ramBankNumber uint8
+	ramStart      uint16
+
+	battery        bool
+	hasRumble      bool
+	rumbleOn       bool
+	rumbleCallback func(on bool)
+}
+
+func createCartridgeMBC5(romSize uint32, ramSize uint32, data *[]byte, battery bool, hasRumble bool) Cartridge {
+	ram := make([]byte, ramSize)
+	bank0 := (*data)[:M_16Kb]
+	return &cartridgeMBC5{
+		romBanks:      splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		romBank0High:  CreateReadOnlyMemory("cartridge ROM bank 0 high", &bank0, 0x4000),
+		ramBanks:      splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
+		ramEnable:     0x00,
+		romBankNumber: 0x01,
+		ramBankNumber: 0x00,
+		ramStart:      0xA000,
+		battery:       battery,
+		hasRumble:     hasRumble,
+	}
+}
+
+func (c *cartridgeMBC5) Reset() {
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
+	}
+	// Don't reset the memory because that is the rom game
+
+	c.ramEnable = 0x00
+	c.romBankNumber = 0x01
+	c.ramBankNumber = 0x00
+	c.setRumble(false)
+}
+
+func (c *cartridgeMBC5) SetRumbleCallback(callback func(on bool)) {
+	c.rumbleCallback = callback
+}
+
+func (c *cartridgeMBC5) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *cartridgeMBC5) ReadByte(address uint16) byte {
+	if address >= c.ramStart && (!c.isRamEnabled() || len(c.ramBanks) == 0) {
+		return 0xFF
+	}
+
+	return c.memoryBank(address).ReadByte(address)
+}
+
+func (c *cartridgeMBC5) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *cartridgeMBC5) WriteBit(address uint16, bit uint8, value bool) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
+}
+
+func (c *cartridgeMBC5) WriteByte(address uint16, value byte) {
+	if address <= 0x7FFF {
+		if address <= 0x1FFF {
+			c.ramEnable = value
+		} else if address <= 0x2FFF {
+			// Lower 8 bits of the ROM bank
+			c.romBankNumber = (c.romBankNumber & 0x100) | uint16(value)
+		} else if address <= 0x3FFF {
+			// 9th bit of the ROM bank
+			c.romBankNumber = (c.romBankNumber & 0xFF) | (uint16(value&0x01) << 8)
+		} else if address <= 0x5FFF {
+			if c.hasRumble {
+				c.setRumble(value&mbc5RumbleBit == mbc5RumbleBit)
+				value = value &^ mbc5RumbleBit
+			}
+			c.ramBankNumber = value & 0x0F
+		}
+
+		return
+	}
+
+	// If writing to RAM but RAM is disabled do nothing
+	if !c.isRamEnabled() || len(c.ramBanks) == 0 {
+		return
+	}
+
+	c.memoryBank(address).WriteByte(address, value)
+}
+
+func (c *cartridgeMBC5) WriteShort(address uint16, value uint16) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
+
+func (c *cartridgeMBC5) DumpROMCode() (data []uint8, startAddress uint16) {
+
+	// Combine the first and current banks
+	code, startAddress := c.romBanks[0].DumpCode()
+	currentCode, _ := c.romBanks[c.romBank()].DumpCode()
+	code = append(code, currentCode...)
+
+	return code, startAddress
+}
+
+func (c *cartridgeMBC5) DumpRAMCode() (data []uint8, startAddress uint16) {
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
+}
+
+func (c *cartridgeMBC5) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.romBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.romBanks[bank].DumpCode()
+}
+
+func (c *cartridgeMBC5) DumpRAMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.ramBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.ramBanks[uint16(bank)].DumpCode()
+}
+
+func (c *cartridgeMBC5) CurrentROMBank() uint16 {
+	return c.romBank()
+}
+
+func (c *cartridgeMBC5) CurrentRAMBank() uint8 {
+	return c.ramBank()
+}
+
+func (c *cartridgeMBC5) memoryBank(address uint16) *Memory {
+	if address >= c.ramStart && address <= 0xBFFF {
+		bankNumber := c.ramBank()
+		bank, exists := c.ramBanks[uint16(bankNumber)]
+		if !exists {
+			panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
+		}
+		return bank
+	}
+
+	// If the address is in the first bank that isn't switchable
+	if address < 0x4000 {
+		return c.romBanks[0]
+	}
+
+	bankNumber := c.romBank()
+	if bankNumber == 0 {
+		return c.romBank0High
+	}
+	bank, exists := c.romBanks[bankNumber]
+	if !exists {
+		panic(fmt.Sprintf("Cartridge ROM bank %d doesn't exist", bankNumber))
+	}
+	return bank
+}
+
+func (c *cartridgeMBC5) isRamEnabled() bool {
+	// Unlike MBC1 the whole value must be 0x0A
+	return c.ramEnable == 0x0A
+}
+
+// Unlike MBC1 bank 0 can be selected. Bank numbers bigger than the ROM wrap.
+func (c *cartridgeMBC5) romBank() uint16 {
+	return c.romBankNumber % uint16(len(c.romBanks))
+}
+
+func (c *cartridgeMBC5) ramBank() uint8 {
+	if len(c.ramBanks) == 0 {
+		return 0
+	}
+
+	return c.ramBankNumber % uint8(len(c.ramBanks))
+}
+
+func (c *cartridgeMBC5) setRumble(on bool) {
+	if on == c.rumbleOn {
+		return
+	}
+
+	c.rumbleOn = on
+	if c.rumbleCallback != nil {
+		c.rumbleCallback(on)
+	}
+}
+
+func (c *cartridgeMBC5) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return joinBanks(c.ramBanks)
+}
+
+func (c *cartridgeMBC5) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	_, err := fillBanks(c.ramBanks, data)
+	return err
+}
+
+type cartridgeMBC5State struct {
+	RAMEnable     uint8
+	ROMBankNumber uint16
+	RAMBankNumber uint8
+	Rumble        bool
+	RAM           [][]uint8
+}
+
+func (c *cartridgeMBC5) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeMBC5State{
+		RAMEnable:     c.ramEnable,
+		ROMBankNumber: c.romBankNumber,
+		RAMBankNumber: c.ramBankNumber,
+		Rumble:        c.rumbleOn,
+		RAM:           saveBanks(c.ramBanks),
+	})
+}
+
+func (c *cartridgeMBC5) LoadState(dec *gob.Decoder) error {
+	var state cartridgeMBC5State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.ramEnable = state.RAMEnable
+	c.romBankNumber = state.ROMBankNumber
+	c.ramBankNumber = state.RAMBankNumber
+	c.setRumble(state.Rumble)
+	return nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestMBC5(hasRumble bool) *cartridgeMBC5 {
+	rom := make([]byte, 0x8000)
+	return createCartridgeMBC5(uint32(len(rom)), 0x2000, &rom, false, hasRumble).(*cartridgeMBC5)
+}
+
+func TestMBC5RAMEnableMustBeExactly0x0A(t *testing.T) {
+	c := createTestMBC5(false)
+
+	c.WriteByte(0x0000, 0x0A)
+	c.WriteByte(0xA000, 0x12)
+	assert.Equal(t, uint8(0x12), c.ReadByte(0xA000))
+
+	// MBC1 would enable RAM for this
+	c.WriteByte(0x0000, 0x1A)
+	assert.Equal(t, uint8(0xFF), c.ReadByte(0xA000))
+	c.WriteByte(0xA000, 0x34)
+
+	c.WriteByte(0x0000, 0x0A)
+	assert.Equal(t, uint8(0x12), c.ReadByte(0xA000))
+}
+
+func TestMBC5RumbleCallbackOnChanges(t *testing.T) {
+	c := createTestMBC5(true)
+
+	var calls []bool
+	c.SetRumbleCallback(func(on bool) { calls = append(calls, on) })
+
+	var off bytes.Buffer
+	require.NoError(t, c.SaveState(gob.NewEncoder(&off)))
+
+	c.WriteByte(0x4000, mbc5RumbleBit)
+	c.WriteByte(0x4000, mbc5RumbleBit|0x01)
+	assert.Equal(t, []bool{true}, calls)
+
+	var on bytes.Buffer
+	require.NoError(t, c.SaveState(gob.NewEncoder(&on)))
+
+	// Loading a state with the motor in the same state doesn't call it
+	require.NoError(t, c.LoadState(gob.NewDecoder(bytes.NewReader(on.Bytes()))))
+	assert.Equal(t, []bool{true}, calls)
+
+	require.NoError(t, c.LoadState(gob.NewDecoder(&off)))
+	assert.Equal(t, []bool{true, false}, calls)
+
+	require.NoError(t, c.LoadState(gob.NewDecoder(&on)))
+	assert.Equal(t, []bool{true, false, true}, calls)
+
+	c.WriteByte(0x4000, 0x00)
+	assert.Equal(t, []bool{true, false, true, false}, calls)
+}
@@ -0,0 +1,319 @@
+package memory
+
+import (
+	"encoding/gob"
+	"fmt"
+)
+
+// MMM01 multicarts start with the last 32KiB of the ROM mapped, which holds
+// the menu. The menu writes the outer bank bits for the chosen game and then
+// sets the map bit which locks them and makes the cartridge act like an MBC1
+// containing only that game.
+const mmm01MapEnable = 0b01000000
+
+type cartridgeMMM01 struct {
+	romBanks map[uint16]*Memory
+	ramBanks map[uint16]*Memory
+
+	mapped      bool
+	ramEnable   uint8
+	romBankLow  uint8
+	romBankMid  uint8
+	romBankHigh uint8
+	romBankMask uint8
+	ramBankLow  uint8
+	ramBankHigh uint8
+	ramBankMask uint8
+
+	bankingModeSelect  uint8
+	bankingModeDisable bool
+	ramStart           uint16
+
+	battery bool
+}
+
+func createCartridgeMMM01(romSize uint32, ramSize uint32, data *[]byte, battery bool) Cartridge {
+	ram := make([]byte, ramSize)
+	c := &cartridgeMMM01{
+		romBanks: splitDataIntoBanks(0x0000, 0x4000, M_16Kb, data, "ROM", true),
+		ramBanks: splitDataIntoBanks(0xA000, 0xA000, M_8Kb, &ram, "RAM", false),
+		ramStart: 0xA000,
+		battery:  battery,
+	}
+	c.Reset()
+	return c
+}
+
+func (c *cartridgeMMM01) Reset() {
+	// Battery backed RAM keeps its contents
+	if !c.battery {
+		for k := range c.ramBanks {
+			c.ramBanks[k].Reset()
+		}
+	}
+	// Don't reset the memory because that is the rom game
+
+	c.mapped = false
+	c.ramEnable = 0x00
+	c.romBankLow = 0x00
+	c.romBankMid = 0x00
+	c.romBankHigh = 0x00
+	c.romBankMask = 0x00
+	c.ramBankLow = 0x00
+	c.ramBankHigh = 0x00
+	c.ramBankMask = 0x00
+	c.bankingModeSelect = 0x00
+	c.bankingModeDisable = false
+}
+
+func (c *cartridgeMMM01) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *cartridgeMMM01) ReadByte(address uint16) byte {
+	if address < 0x4000 {
+		return readBankByte(c.romBanks[c.lowROMBank()], address)
+	}
+
+	if address <= 0x7FFF {
+		return readBankByte(c.romBanks[c.romBank()], address)
+	}
+
+	if !c.isRamEnabled() || len(c.ramBanks) == 0 {
+		return 0xFF
+	}
+
+	return c.memoryBank(address).ReadByte(address)
+}
+
+func (c *cartridgeMMM01) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *cartridgeMMM01) WriteBit(address uint16, bit uint8, value bool) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
+}
+
+// The outer bank and mask bits can only be written before the map bit is set
+func (c *cartridgeMMM01) WriteByte(address uint16, value byte) {
+	if address <= 0x7FFF {
+		if address <= 0x1FFF {
+			c.ramEnable = value & 0x0F
+			if !c.mapped {
+				c.ramBankMask = (value >> 4) & 0b11
+				c.mapped = value&mmm01MapEnable == mmm01MapEnable
+			}
+		} else if address <= 0x3FFF {
+			// Masked bits keep the value from before mapping
+			mask := c.romBankLowMask()
+			c.romBankLow = (c.romBankLow & mask) | (value & 0b00011111 &^ mask)
+			if !c.mapped {
+				c.romBankMid = (value >> 5) & 0b11
+			}
+		} else if address <= 0x5FFF {
+			mask := c.ramBankMask
+			if !c.mapped {
+				mask = 0
+			}
+			c.ramBankLow = (c.ramBankLow & mask) | (value & 0b11 &^ mask)
+			if !c.mapped {
+				c.ramBankHigh = (value >> 2) & 0b11
+				c.romBankHigh = (value >> 4) & 0b11
+				c.bankingModeDisable = value&0b01000000 == 0b01000000
+			}
+		} else {
+			if !c.bankingModeDisable {
+				c.bankingModeSelect = value & 0b00000001
+			}
+			if !c.mapped {
+				c.romBankMask = (value >> 2) & 0b1111
+			}
+			// Bit 6 swaps the ROM and RAM bank lines for some carts, none of
+			// the released games need it
+		}
+
+		return
+	}
+
+	// If writing to RAM but RAM is disabled do nothing
+	if !c.isRamEnabled() || len(c.ramBanks) == 0 {
+		return
+	}
+
+	c.memoryBank(address).WriteByte(address, value)
+}
+
+func (c *cartridgeMMM01) WriteShort(address uint16, value uint16) {
+	if address <= 0x7FFF {
+		panic("This shouldn't happen")
+	}
+
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
+
+func (c *cartridgeMMM01) DumpROMCode() (data []uint8, startAddress uint16) {
+	return dumpROMArea(c.romBanks[c.lowROMBank()], c.romBanks[c.romBank()])
+}
+
+func (c *cartridgeMMM01) DumpRAMCode() (data []uint8, startAddress uint16) {
+	return c.ramBanks[uint16(c.ramBank())].DumpCode()
+}
+
+func (c *cartridgeMMM01) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.romBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.romBanks[bank].DumpCode()
+}
+
+func (c *cartridgeMMM01) DumpRAMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+	if int(bank) > len(c.ramBanks)-1 {
+		panic("Invalid bank number for cartridge")
+	}
+	return c.ramBanks[uint16(bank)].DumpCode()
+}
+
+func (c *cartridgeMMM01) CurrentROMBank() uint16 {
+	return c.romBank()
+}
+
+func (c *cartridgeMMM01) CurrentRAMBank() uint8 {
+	return c.ramBank()
+}
+
+func (c *cartridgeMMM01) memoryBank(address uint16) *Memory {
+	bankNumber := c.ramBank()
+	bank, exists := c.ramBanks[uint16(bankNumber)]
+	if !exists {
+		panic(fmt.Sprintf("Cartridge RAM bank %d doesn't exist", bankNumber))
+	}
+	return bank
+}
+
+func (c *cartridgeMMM01) isRamEnabled() bool {
+	return c.ramEnable == 0x0A
+}
+
+// The mask register covers bits 1-4 of the lower ROM bank
+func (c *cartridgeMMM01) romBankLowMask() uint8 {
+	if !c.mapped {
+		return 0
+	}
+	return c.romBankMask << 1
+}
+
+func (c *cartridgeMMM01) outerROMBank() uint16 {
+	return uint16(c.romBankHigh)<<7 | uint16(c.romBankMid)<<5
+}
+
+// Before mapping all the bank lines are high so the last 32KiB is mapped
+func (c *cartridgeMMM01) lowROMBank() uint16 {
+	if !c.mapped {
+		return wrapBank(c.romBanks, 0x1FE)
+	}
+
+	return wrapBank(c.romBanks, c.outerROMBank()|uint16(c.romBankLow&c.romBankLowMask()))
+}
+
+func (c *cartridgeMMM01) romBank() uint16 {
+	if !c.mapped {
+		return wrapBank(c.romBanks, 0x1FF)
+	}
+
+	// Like MBC1 the bank can't be 0 within the game
+	bank := c.romBankLow
+	if bank&^c.romBankLowMask() == 0 {
+		bank |= 0x01
+	}
+
+	return wrapBank(c.romBanks, c.outerROMBank()|uint16(bank))
+}
+
+func (c *cartridgeMMM01) ramBank() uint8 {
+	bank := c.ramBankHigh << 2
+	if c.bankingModeSelect == 1 {
+		bank |= c.ramBankLow
+	}
+
+	return uint8(wrapBank(c.ramBanks, uint16(bank)))
+}
+
+func (c *cartridgeMMM01) ExportBatteryRAM() []uint8 {
+	if !c.battery {
+		return nil
+	}
+
+	return joinBanks(c.ramBanks)
+}
+
+func (c *cartridgeMMM01) ImportBatteryRAM(data []uint8) error {
+	if !c.battery {
+		return nil
+	}
+
+	_, err := fillBanks(c.ramBanks, data)
+	return err
+}
+
+type cartridgeMMM01State struct {
+	Mapped             bool
+	RAMEnable          uint8
+	ROMBankLow         uint8
+	ROMBankMid         uint8
+	ROMBankHigh        uint8
+	ROMBankMask        uint8
+	RAMBankLow         uint8
+	RAMBankHigh        uint8
+	RAMBankMask        uint8
+	BankingModeSelect  uint8
+	BankingModeDisable bool
+	RAM                [][]uint8
+}
+
+func (c *cartridgeMMM01) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeMMM01State{
+		Mapped:             c.mapped,
+		RAMEnable:          c.ramEnable,
+		ROMBankLow:         c.romBankLow,
+		ROMBankMid:         c.romBankMid,
+		ROMBankHigh:        c.romBankHigh,
+		ROMBankMask:        c.romBankMask,
+		RAMBankLow:         c.ramBankLow,
+		RAMBankHigh:        c.ramBankHigh,
+		RAMBankMask:        c.ramBankMask,
+		BankingModeSelect:  c.bankingModeSelect,
+		BankingModeDisable: c.bankingModeDisable,
+		RAM:                saveBanks(c.ramBanks),
+	})
+}
+
+func (c *cartridgeMMM01) LoadState(dec *gob.Decoder) error {
+	var state cartridgeMMM01State
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if err := loadBanks(c.ramBanks, state.RAM); err != nil {
+		return err
+	}
+
+	c.mapped = state.Mapped
+	c.ramEnable = state.RAMEnable
+	c.romBankLow = state.ROMBankLow
+	c.romBankMid = state.ROMBankMid
+	c.romBankHigh = state.ROMBankHigh
+	c.romBankMask = state.ROMBankMask
+	c.ramBankLow = state.RAMBankLow
+	c.ramBankHigh = state.RAMBankHigh
+	c.ramBankMask = state.RAMBankMask
+	c.bankingModeSelect = state.BankingModeSelect
+	c.bankingModeDisable = state.BankingModeDisable
+	return nil
+}
@@ -1,6 +1,9 @@
 package memory
 
-import "fmt"
+import (
+	"encoding/gob"
+	"fmt"
+)
 
 type CartridgeNoMBC struct {
 	rom *Memory
@@ -54,7 +57,7 @@ func (c *CartridgeNoMBC) DumpRAMCode() (data []uint8, startAddress uint16) {
 	return c.ram.DumpCode()
 }
 
-func (c *CartridgeNoMBC) DumpROMBankCode(bank uint8) (data []uint8, startAddress uint16) {
+func (c *CartridgeNoMBC) DumpROMBankCode(bank uint16) (data []uint8, startAddress uint16) {
 	panic("Cartridge type does not have memory banks")
 }
 
@@ -62,7 +65,7 @@ func (c *CartridgeNoMBC) DumpRAMBankCode(bank uint8) (data []uint8, startAddress
 	panic("Cartridge type does not have memory banks")
 }
 
-func (c *CartridgeNoMBC) CurrentROMBank() uint8 {
+func (c *CartridgeNoMBC) CurrentROMBank() uint16 {
 	return 0
 }
 
@@ -80,3 +83,31 @@ func (c *CartridgeNoMBC) memoryBank(address uint16) *Memory {
 
 	return c.rom
 }
+
+// Only ROM only cartridges are supported which have no battery
+func (c *CartridgeNoMBC) ExportBatteryRAM() []uint8 {
+	return nil
+}
+
+func (c *CartridgeNoMBC) ImportBatteryRAM(data []uint8) error {
+	return nil
+}
+
+type cartridgeNoMBCState struct {
+	RAM []uint8
+}
+
+func (c *CartridgeNoMBC) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(cartridgeNoMBCState{
+		RAM: c.ram.saveState(),
+	})
+}
+
+func (c *CartridgeNoMBC) LoadState(dec *gob.Decoder) error {
+	var state cartridgeNoMBCState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	return c.ram.loadState(state.RAM)
+}
@@ -0,0 +1,371 @@
+package memory
+
+// Game Boy Color registers
+const (
+	KEY0  = 0xFF4C
+	KEY1  = 0xFF4D
+	VBK   = 0xFF4F
+	HDMA1 = 0xFF51
+	HDMA2 = 0xFF52
+	HDMA3 = 0xFF53
+	HDMA4 = 0xFF54
+	HDMA5 = 0xFF55
+	BCPS  = 0xFF68
+	BCPD  = 0xFF69
+	OCPS  = 0xFF6A
+	OCPD  = 0xFF6B
+	SVBK  = 0xFF70
+)
+
+// Written to KEY0 by the boot ROM when the game doesn't support colour
+const key0Compatibility = 0x04
+
+const paletteRAMSize = 64
+const paletteAutoIncrement = 0x80
+const paletteIndexMask = 0x3F
+
+const hdmaBlockSize = 0x10
+const hdmaHBlankMode = 0x80
+const hdmaFinished = 0x7F
+
+// M-cycles the CPU is stopped for each block copied. The copy takes the same
+// time at both speeds so twice as many M-cycles in double speed.
+const hdmaBlockMCycles = 8
+
+// Used when running without the boot ROM which would normally set up the
+// palettes for monochrome games
+var compatibilityPalette = [4]uint16{0x7FFF, 0x56B5, 0x294A, 0x0000}
+
+// BCPS/BCPD and OCPS/OCPD select and access 8 palettes of 4 colours each
+type colorPalette struct {
+	spec uint8
+	data [paletteRAMSize]uint8
+}
+
+func (p *colorPalette) readSpec() uint8 {
+	return p.spec | 0x40
+}
+
+func (p *colorPalette) writeSpec(value uint8) {
+	p.spec = value & (paletteAutoIncrement | paletteIndexMask)
+}
+
+func (p *colorPalette) readData() uint8 {
+	return p.data[p.spec&paletteIndexMask]
+}
+
+func (p *colorPalette) writeData(value uint8) {
+	p.data[p.spec&paletteIndexMask] = value
+
+	if p.spec&paletteAutoIncrement == paletteAutoIncrement {
+		p.spec = paletteAutoIncrement | ((p.spec + 1) & paletteIndexMask)
+	}
+}
+
+// Colours are stored little endian as 15 bit BGR
+func (p *colorPalette) color(palette uint8, index uint8) uint16 {
+	offset := (int(palette&0x07)*4 + int(index&0x03)) * 2
+	return (uint16(p.data[offset+1])<<8 | uint16(p.data[offset])) & 0x7FFF
+}
+
+func (p *colorPalette) setColor(palette uint8, index uint8, value uint16) {
+	offset := (int(palette&0x07)*4 + int(index&0x03)) * 2
+	p.data[offset] = uint8(value)
+	p.data[offset+1] = uint8(value >> 8)
+}
+
+// The extra hardware in the Game Boy Color. Only exists on the bus when
+// running as a Game Boy Color.
+type colorHardware struct {
+	bus *Bus
+
+	compatibility       bool
+	doubleSpeed         bool
+	speedSwitchPrepared bool
+
+	// Banks 2-7 for 0xD000-0xDFFF, bank 1 is the normal RAM
+	wramBank uint8
+	wram     [8]*Memory
+
+	bgPalette  colorPalette
+	objPalette colorPalette
+
+	hdmaSource      uint16
+	hdmaDestination uint16
+	hdmaRemaining   uint8
+	hdmaActive      bool
+	stallMCycles    uint
+}
+
+func createColorHardware(bus *Bus) *colorHardware {
+	c := &colorHardware{bus: bus}
+	for x := 2; x < len(c.wram); x++ {
+		data := make([]byte, 0x1000)
+		c.wram[x] = CreateMemory("ram bank", &data, 0xD000)
+	}
+	c.Reset()
+	return c
+}
+
+func (c *colorHardware) Reset() {
+	c.compatibility = false
+	c.doubleSpeed = false
+	c.speedSwitchPrepared = false
+	c.wramBank = 1
+	for x := 2; x < len(c.wram); x++ {
+		c.wram[x].Reset()
+	}
+	c.bgPalette = colorPalette{}
+	c.objPalette = colorPalette{}
+	c.hdmaSource = 0x0000
+	c.hdmaDestination = 0x8000
+	c.hdmaRemaining = hdmaFinished
+	c.hdmaActive = false
+	c.stallMCycles = 0
+}
+
+func isColorRegister(address uint16) bool {
+	switch address {
+	case KEY0, KEY1, VBK, HDMA1, HDMA2, HDMA3, HDMA4, HDMA5, BCPS, BCPD, OCPS, OCPD, SVBK:
+		return true
+	default:
+		return false
+	}
+}
+
+// The banked RAM at 0xD000-0xDFFF or nil when bank 1 is selected
+func (c *colorHardware) ramBank(address uint16) *Memory {
+	if address < 0xD000 || address > 0xDFFF || c.wramBank < 2 {
+		return nil
+	}
+
+	return c.wram[c.wramBank]
+}
+
+func (c *colorHardware) ReadBit(address uint16, bit uint8) bool {
+	return GetBit(c.ReadByte(address), int(bit))
+}
+
+func (c *colorHardware) ReadByte(address uint16) byte {
+	switch address {
+	case KEY1:
+		value := uint8(0x7E)
+		if c.doubleSpeed {
+			value |= 0x80
+		}
+		if c.speedSwitchPrepared {
+			value |= 0x01
+		}
+		return value
+	case VBK:
+		return 0xFE | c.bus.video.bank
+	case HDMA5:
+		if c.hdmaActive {
+			return c.hdmaRemaining
+		}
+		return 0x80 | c.hdmaRemaining
+	case BCPS:
+		return c.bgPalette.readSpec()
+	case BCPD:
+		return c.bgPalette.readData()
+	case OCPS:
+		return c.objPalette.readSpec()
+	case OCPD:
+		return c.objPalette.readData()
+	case SVBK:
+		return 0xF8 | c.wramBank
+	default:
+		// KEY0 and the HDMA source and destination can't be read
+		return 0xFF
+	}
+}
+
+func (c *colorHardware) ReadShort(address uint16) uint16 {
+	lsb := c.ReadByte(address)
+	msb := c.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (c *colorHardware) WriteBit(address uint16, bit uint8, value bool) {
+	c.WriteByte(address, SetBit(c.ReadByte(address), bit, value))
+}
+
+func (c *colorHardware) WriteByte(address uint16, value byte) {
+	switch address {
+	case KEY0:
+		// Only the boot ROM can pick the mode
+		if c.bus.isBIOSMapped() {
+			c.compatibility = value&key0Compatibility == key0Compatibility
+		}
+	case KEY1:
+		c.speedSwitchPrepared = value&0x01 == 0x01
+	case VBK:
+		c.bus.video.bank = value & 0x01
+	case HDMA1:
+		c.hdmaSource = uint16(value)<<8 | c.hdmaSource&0x00FF
+	case HDMA2:
+		c.hdmaSource = c.hdmaSource&0xFF00 | uint16(value&0xF0)
+	case HDMA3:
+		c.hdmaDestination = 0x8000 | uint16(value&0x1F)<<8 | c.hdmaDestination&0x00FF
+	case HDMA4:
+		c.hdmaDestination = c.hdmaDestination&0xFF00 | uint16(value&0xF0)
+	case HDMA5:
+		c.writeHDMA5(value)
+	case BCPS:
+		c.bgPalette.writeSpec(value)
+	case BCPD:
+		c.bgPalette.writeData(value)
+	case OCPS:
+		c.objPalette.writeSpec(value)
+	case OCPD:
+		c.objPalette.writeData(value)
+	case SVBK:
+		c.wramBank = value & 0x07
+		if c.wramBank == 0 {
+			c.wramBank = 1
+		}
+	}
+}
+
+func (c *colorHardware) WriteShort(address uint16, value uint16) {
+	c.WriteByte(address, uint8(value))
+	c.WriteByte(address+1, uint8(value>>8))
+}
+
+// Starts a general purpose transfer which copies everything straight away or
+// an H-blank transfer that copies a block each H-blank. Writing with bit 7
+// clear during an H-blank transfer stops it.
+func (c *colorHardware) writeHDMA5(value uint8) {
+	if c.hdmaActive && value&hdmaHBlankMode == 0 {
+		c.hdmaActive = false
+		return
+	}
+
+	c.hdmaRemaining = value & 0x7F
+
+	if value&hdmaHBlankMode == hdmaHBlankMode {
+		c.hdmaActive = true
+		return
+	}
+
+	for {
+		if c.copyHDMABlock() {
+			break
+		}
+	}
+}
+
+// Returns true when the last block has been copied
+func (c *colorHardware) copyHDMABlock() bool {
+	for x := uint16(0); x < hdmaBlockSize; x++ {
+		value := c.bus.ReadByte(c.hdmaSource + x)
+		c.bus.video.WriteByte(0x8000|((c.hdmaDestination+x)&0x1FFF), value)
+	}
+
+	c.hdmaSource += hdmaBlockSize
+	c.hdmaDestination = 0x8000 | ((c.hdmaDestination + hdmaBlockSize) & 0x1FF0)
+
+	if c.doubleSpeed {
+		c.stallMCycles += hdmaBlockMCycles * 2
+	} else {
+		c.stallMCycles += hdmaBlockMCycles
+	}
+
+	c.hdmaRemaining--
+	if c.hdmaRemaining == 0xFF {
+		c.hdmaRemaining = hdmaFinished
+		c.hdmaActive = false
+		return true
+	}
+
+	return false
+}
+
+func (c *colorHardware) hblank() {
+	if c.hdmaActive {
+		c.copyHDMABlock()
+	}
+}
+
+func (c *colorHardware) setCompatibility(enabled bool) {
+	c.compatibility = enabled
+	if !enabled {
+		return
+	}
+
+	for index, value := range compatibilityPalette {
+		c.bgPalette.setColor(0, uint8(index), value)
+		c.objPalette.setColor(0, uint8(index), value)
+		c.objPalette.setColor(1, uint8(index), value)
+	}
+}
+
+type colorState struct {
+	Compatibility       bool
+	DoubleSpeed         bool
+	SpeedSwitchPrepared bool
+	WRAMBank            uint8
+	WRAM                [][]uint8
+	BGPaletteSpec       uint8
+	BGPalette           [paletteRAMSize]uint8
+	OBJPaletteSpec      uint8
+	OBJPalette          [paletteRAMSize]uint8
+	HDMASource          uint16
+	HDMADestination     uint16
+	HDMARemaining       uint8
+	HDMAActive          bool
+	StallMCycles        uint
+}
+
+func (c *colorHardware) saveState() colorState {
+	wram := make([][]uint8, 0)
+	for x := 2; x < len(c.wram); x++ {
+		wram = append(wram, c.wram[x].saveState())
+	}
+
+	return colorState{
+		Compatibility:       c.compatibility,
+		DoubleSpeed:         c.doubleSpeed,
+		SpeedSwitchPrepared: c.speedSwitchPrepared,
+		WRAMBank:            c.wramBank,
+		WRAM:                wram,
+		BGPaletteSpec:       c.bgPalette.spec,
+		BGPalette:           c.bgPalette.data,
+		OBJPaletteSpec:      c.objPalette.spec,
+		OBJPalette:          c.objPalette.data,
+		HDMASource:          c.hdmaSource,
+		HDMADestination:     c.hdmaDestination,
+		HDMARemaining:       c.hdmaRemaining,
+		HDMAActive:          c.hdmaActive,
+		StallMCycles:        c.stallMCycles,
+	}
+}
+
+func (c *colorHardware) loadState(state colorState) error {
+	for x := 2; x < len(c.wram); x++ {
+		if x-2 >= len(state.WRAM) {
+			break
+		}
+		if err := c.wram[x].loadState(state.WRAM[x-2]); err != nil {
+			return err
+		}
+	}
+
+	c.compatibility = state.Compatibility
+	c.doubleSpeed = state.DoubleSpeed
+	c.speedSwitchPrepared = state.SpeedSwitchPrepared
+	c.wramBank = state.WRAMBank
+	if c.wramBank == 0 {
+		c.wramBank = 1
+	}
+	c.bgPalette.spec = state.BGPaletteSpec
+	c.bgPalette.data = state.BGPalette
+	c.objPalette.spec = state.OBJPaletteSpec
+	c.objPalette.data = state.OBJPalette
+	c.hdmaSource = state.HDMASource
+	c.hdmaDestination = state.HDMADestination
+	c.hdmaRemaining = state.HDMARemaining
+	c.hdmaActive = state.HDMAActive
+	c.stallMCycles = state.StallMCycles
+	return nil
+}
@@ -0,0 +1,111 @@
+package memory
+
+// OAM DMA copies 160 bytes into OAM, one each M-cycle, while the CPU keeps
+// running. The CPU can only use the buses the transfer isn't using so games
+// run the wait loop from HRAM.
+const dmaRegister = 0xFF46
+const dmaLength = 0xA0
+
+// M-cycles from writing the register to the transfer taking over the bus
+const dmaStartDelay = 2
+
+type oamDMA struct {
+	active bool
+	source uint16
+	index  uint16
+
+	// Writing the register again restarts the transfer after the delay and
+	// the old one carries on until then
+	starting bool
+	delay    uint8
+	next     uint16
+}
+
+// The CPU and DMA compete for whichever of these the source is on
+type dmaBus int
+
+const (
+	externalBus dmaBus = iota
+	videoBus
+	internalBus
+)
+
+func busFor(address uint16) dmaBus {
+	if address >= videoRamStart && address <= videoRamEnd {
+		return videoBus
+	}
+
+	if address >= oamStart {
+		return internalBus
+	}
+
+	return externalBus
+}
+
+func (b *Bus) startDMA(value uint8) {
+	b.dma.starting = true
+	b.dma.delay = dmaStartDelay
+	b.dma.next = uint16(value) << 8
+}
+
+func (b *Bus) DMAActive() bool {
+	return b.dma.active
+}
+
+func (b *Bus) UpdateDMA(mCycles uint) {
+	for x := uint(0); x < mCycles; x++ {
+		b.stepDMA()
+	}
+}
+
+func (b *Bus) stepDMA() {
+	if b.dma.active {
+		b.WriteByte(oamStart+b.dma.index, b.dmaValue())
+		b.dma.index++
+		if b.dma.index == dmaLength {
+			b.dma.active = false
+		}
+	}
+
+	if b.dma.starting {
+		b.dma.delay--
+		if b.dma.delay == 0 {
+			b.dma.starting = false
+			b.dma.active = true
+			b.dma.source = b.dma.next
+			b.dma.index = 0
+		}
+	}
+}
+
+// Sources from 0xE000 up read the echo of work RAM
+func (b *Bus) dmaSourceAddress() uint16 {
+	address := b.dma.source + b.dma.index
+	if address >= 0xE000 {
+		address -= 0x2000
+	}
+	return address
+}
+
+// The byte being copied this M-cycle
+func (b *Bus) dmaValue() uint8 {
+	return b.ReadByte(b.dmaSourceAddress())
+}
+
+// The CPU sees the byte being copied when it reads the bus the transfer is
+// using and can't use OAM at all
+func (b *Bus) dmaConflict(address uint16) (conflict bool, value uint8) {
+	if !b.dma.active {
+		return false, 0
+	}
+
+	if address >= oamStart && address <= unusableEnd {
+		return true, blockedValue
+	}
+
+	if busFor(address) == busFor(b.dmaSourceAddress()) {
+		return true, b.dmaValue()
+	}
+
+	return false, 0
+}
@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -106,3 +107,18 @@ func (m *Memory) DumpCode() (data []uint8, startAddress uint16) {
 	copy(code, m.buffer)
 	return code, m.addressOffset
 }
+
+func (m *Memory) saveState() []uint8 {
+	data := make([]uint8, len(m.buffer))
+	copy(data, m.buffer)
+	return data
+}
+
+func (m *Memory) loadState(data []uint8) error {
+	if len(data) != len(m.buffer) {
+		return errors.New(fmt.Sprintf("Saved %s is %d bytes but expected %d", m.name, len(data), len(m.buffer)))
+	}
+
+	copy(m.buffer, data)
+	return nil
+}
@@ -2,8 +2,13 @@ package memory
 
 import "testing"
 
+func createTestMemory() *Memory {
+	data := make([]byte, 0x10)
+	return CreateMemory("test", &data, 0)
+}
+
 func TestReadWriteByte(t *testing.T) {
-	mem := CreateMemory()
+	mem := createTestMemory()
 	mem.WriteByte(0, 0xAB)
 	value := mem.ReadByte(0)
 	if value != 0xAB {
@@ -12,8 +17,8 @@ func TestReadWriteByte(t *testing.T) {
 }
 
 func TestReadWriteShort(t *testing.T) {
-	mem := CreateMemory()
-	mem.Write(0, []byte{0xCD, 0xEF})
+	mem := createTestMemory()
+	mem.WriteShort(0, 0xCDEF)
 	value := mem.ReadShort(0)
 	if value != 0xCDEF {
 		t.Errorf("Expected 0xCDEF but got 0x%X", value)
@@ -21,25 +26,26 @@ func TestReadWriteShort(t *testing.T) {
 }
 
 func TestWriteShort(t *testing.T) {
-	mem := CreateMemory()
+	mem := createTestMemory()
 	mem.WriteShort(0, 0x1234)
 
 	lsb := mem.ReadByte(0)
 	msb := mem.ReadByte(1)
 
-	if lsb != 0x12 {
-		t.Errorf("Expected 0x12 for LSB but got 0x%02X", lsb)
+	// Little endian - lsb stored first
+	if lsb != 0x34 {
+		t.Errorf("Expected 0x34 for LSB but got 0x%02X", lsb)
 	}
 
 	if msb != 0x12 {
-		t.Errorf("Expected 0x34 for MSB but got 0x%02X", msb)
+		t.Errorf("Expected 0x12 for MSB but got 0x%02X", msb)
 	}
 }
 
 func TestReadShort(t *testing.T) {
-	mem := CreateMemory()
-	mem.WriteByte(0, 0x12)
-	mem.WriteByte(1, 0x34)
+	mem := createTestMemory()
+	mem.WriteByte(0, 0x34)
+	mem.WriteByte(1, 0x12)
 
 	result := mem.ReadShort(0)
 
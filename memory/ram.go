@@ -7,6 +7,14 @@ type inputOutput interface {
 
 type interupt interface {
 	TriggerTimerOverflow()
+	TriggerJoypad()
+}
+
+// The Super Game Boy watches the joypad register for command packets and
+// picks which player's joypad is read
+type joypadListener interface {
+	JoypadWrite(value uint8)
+	JoypadPlayer() uint8
 }
 
 const DividerRegister = 0xFF04
@@ -14,8 +22,9 @@ const DividerRegister = 0xFF04
 type ram struct {
 	mem *Memory
 
-	io       inputOutput
-	interupt interupt
+	io             inputOutput
+	interupt       interupt
+	joypadListener joypadListener
 }
 
 func CreateRam() *ram {
@@ -34,102 +43,114 @@ func (r *ram) SetIO(io inputOutput, interupt interupt) {
 func (r *ram) Reset() {
 	r.mem.Reset()
 	// For controller
-	r.mem.WriteByte(0xFF00, 0x3F)
+	r.mem.WriteByte(joypadRegister, 0x3F)
 
 	//  TODO - remove LCDC - to match for comaprisons
 	//m.buffer[0xFF40] = 0x91
 }
 
 func (r *ram) ReadBit(address uint16, bit uint8) bool {
-	return r.mem.ReadBit(address, bit)
+	return GetBit(r.ReadByte(address), int(bit))
 }
 
 func (r *ram) ReadByte(address uint16) byte {
-	// Uncomment for comparison runs
-	//	if address == 0xFF00 {
-	//		reg := r.mem.ReadByte(address)
-	//		P14 := (reg >> 4) & 0x01
-	//		P15 := (reg >> 5) & 0x01
-
-	//		if P14 == 0 {
-	//			return reg & ^r.io.ReadDirectional()
-	//		}
+	if address == joypadRegister {
+		return r.readJoypad()
+	}
 
-	//		if P15 == 0 {
-	//			return reg & ^r.io.ReadStandard()
-	//		}
+	if address >= unusableStart && address <= unusableEnd {
+		return 0x00
+	}
 
-	//		return reg | 0x0F
-	//	}
+	if isIORegister(address) {
+		return r.mem.ReadByte(address) | ioReadMasks[address-ioStart]
+	}
 
 	return r.mem.ReadByte(address)
 }
 
 func (r *ram) ReadShort(address uint16) uint16 {
-	return r.mem.ReadShort(address)
+	lsb := r.ReadByte(address)
+	msb := r.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
 }
 
 func (r *ram) WriteBit(address uint16, bit uint8, value bool) {
-	r.mem.WriteBit(address, bit, value)
+	r.WriteByte(address, SetBit(r.mem.ReadByte(address), bit, value))
 }
 
 func (r *ram) WriteByte(address uint16, value byte) {
-	// If the CPU tries to write to the address (regardless of value) we
-	// set the value to zero
-	if address == 0xFF44 {
-		//m.buffer[address] = 0
-		r.mem.WriteByte(address, value)
+	if address >= unusableStart && address <= unusableEnd {
 		return
 	}
 
-	// Controller
-	if address == 0xFF00 {
-		P14 := (value >> 4) & 0x01
-		P15 := (value >> 5) & 0x01
+	// Controller, only the select lines are stored
+	if address == joypadRegister {
+		r.writeJoypad(value)
+		return
+	}
 
-		current := 0xFF & (value | 0b11001111)
+	if isIORegister(address) {
+		current := r.mem.ReadByte(address)
+		mask := ioWriteMasks[address-ioStart]
+		value = value&mask | current&^mask
 
-		if P14 == 0 {
-			current &= r.io.ReadDirectional()
+		// The boot ROM can't be mapped back in once it is turned off
+		if address == bootROMRegister {
+			value |= current
 		}
+	}
 
-		if P15 == 0 {
-			current &= r.io.ReadStandard()
-		}
+	r.mem.WriteByte(address, value)
+}
 
-		// Uncomment for comparison runs
-		//current := (r.mem.ReadByte(address) & 0xCF) | (value & 0x30)
+// Selecting a row with a button held down pulls its line low which requests
+// the joypad interrupt the same as pressing it
+func (r *ram) writeJoypad(value uint8) {
+	if r.joypadListener != nil {
+		r.joypadListener.JoypadWrite(value)
+	}
 
-		r.mem.WriteByte(address, current)
-		return
+	before := r.readJoypad()
+	current := r.mem.ReadByte(joypadRegister)
+	mask := ioWriteMasks[joypadRegister-ioStart]
+	r.mem.WriteByte(joypadRegister, value&mask|current&^mask)
+
+	if r.interupt != nil && before&^r.readJoypad()&0x0F != 0 {
+		r.interupt.TriggerJoypad()
 	}
+}
 
-	// LCD status register
-	if address == 0xFF41 {
-		reg := r.mem.ReadByte(address)
-		// Keep the existing values for the first 3 bits
-		value = SetBit(value, 0, reg&0b0000001 == 0b00000001)
-		value = SetBit(value, 1, reg&0b0000010 == 0b00000010)
-		value = SetBit(value, 2, reg&0b0000100 == 0b00000100)
-		r.mem.WriteByte(address, value)
-		return
+// The buttons are read when P1 is read so presses show up straight away
+func (r *ram) readJoypad() uint8 {
+	reg := r.mem.ReadByte(joypadRegister)
+	P14 := (reg >> 4) & 0x01
+	P15 := (reg >> 5) & 0x01
+
+	player := uint8(0)
+	if r.joypadListener != nil {
+		player = r.joypadListener.JoypadPlayer()
 	}
 
-	r.mem.WriteByte(address, value)
+	lines := uint8(0x0F)
 
-	// Echo RAM - might not need this
-	//
-	// 0xE000 - 0xFDFF: Echo RAM
-	//This section of memory directly mirrors the working RAM section - meaning if you write into the first address of working RAM (0xC000), the same value will appear in the first spot of echo RAM (0xE000). Nintendo actively discouraged developers from using this area of memory and as such we can just pretend it doesn't exist.
-	if address >= 0xC000 && address <= 0xDDFF {
-		if address+0x2000 <= 0xFDFF {
-			r.mem.WriteByte(address+0x2000, value)
+	// Only the first player's joypad is connected
+	if player == 0 && r.io != nil {
+		if P14 == 0 {
+			lines &= r.io.ReadDirectional()
+		}
+
+		if P15 == 0 {
+			lines &= r.io.ReadStandard()
 		}
 	}
-}
 
-func (r *ram) WriteDividerRegister(value uint8) {
-	r.WriteByte(DividerRegister, value)
+	// With neither line selected the low bits give the player
+	if P14 == 1 && P15 == 1 {
+		lines = 0x0F - player
+	}
+
+	return ioReadMasks[joypadRegister-ioStart] | reg&0x30 | lines
 }
 
 func (r *ram) DisplaySetScanline(value uint8) {
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testJoypad struct {
+	directional uint8
+	standard    uint8
+}
+
+func (j *testJoypad) ReadDirectional() uint8 {
+	return j.directional
+}
+
+func (j *testJoypad) ReadStandard() uint8 {
+	return j.standard
+}
+
+type testInterrupts struct {
+	joypad int
+}
+
+func (i *testInterrupts) TriggerTimerOverflow() {
+}
+
+func (i *testInterrupts) TriggerJoypad() {
+	i.joypad++
+}
+
+func createTestRam(joypad *testJoypad) (*ram, *testInterrupts) {
+	interrupts := &testInterrupts{}
+	r := CreateRam()
+	r.Reset()
+	r.SetIO(joypad, interrupts)
+	return r, interrupts
+}
+
+func TestJoypadSelectWithButtonHeldRequestsInterrupt(t *testing.T) {
+	// A held down
+	r, interrupts := createTestRam(&testJoypad{directional: 0x0F, standard: 0x0E})
+
+	// Selecting the directions doesn't pull any lines low
+	r.WriteByte(joypadRegister, 0x20)
+	assert.Equal(t, 0, interrupts.joypad)
+	assert.Equal(t, uint8(0xEF), r.ReadByte(joypadRegister))
+
+	r.WriteByte(joypadRegister, 0x10)
+	assert.Equal(t, 1, interrupts.joypad)
+	assert.Equal(t, uint8(0xDE), r.ReadByte(joypadRegister))
+
+	// Already low
+	r.WriteByte(joypadRegister, 0x00)
+	assert.Equal(t, 1, interrupts.joypad)
+
+	r.WriteByte(joypadRegister, 0x30)
+	r.WriteByte(joypadRegister, 0x10)
+	assert.Equal(t, 2, interrupts.joypad)
+}
+
+func TestJoypadSelectWithoutButtonsHeld(t *testing.T) {
+	r, interrupts := createTestRam(&testJoypad{directional: 0x0F, standard: 0x0F})
+
+	r.WriteByte(joypadRegister, 0x00)
+	r.WriteByte(joypadRegister, 0x30)
+	r.WriteByte(joypadRegister, 0x10)
+	assert.Equal(t, 0, interrupts.joypad)
+}
@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The RTC block at the end of .sav files for MBC3 timer cartridges. Each
+// register is stored as 4 bytes, first the current values then the latched
+// values, followed by the unix time it was saved at. Older files use a 32bit
+// time so are 4 bytes shorter.
+const rtcBlockSize = 48
+const rtcBlockSizeShortTime = 44
+const rtcRegisterCount = 5
+
+// The clock crystal on the cartridge ticks once a second
+const rtcCyclesPerSecond = 4194304
+
+const (
+	rtcSeconds = iota
+	rtcMinutes
+	rtcHours
+	rtcDayLow
+	rtcDayHigh
+)
+
+// Day high register bits
+const rtcDayMSB = 0x01
+const rtcHalt = 0x40
+const rtcDayCarry = 0x80
+
+// Bits that exist in each register, the rest read as 0
+var rtcRegisterMasks = [rtcRegisterCount]uint8{0x3F, 0x3F, 0x1F, 0xFF, 0xC1}
+
+// Wall clock time used to catch the RTC up for the time the emulator wasn't
+// running. While running the RTC is driven by emulated cycles.
+type ClockSource interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (s systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Implemented by cartridges with a real time clock
+type RealTimeClock interface {
+	ClockedCartridge
+	SetClockSource(clock ClockSource)
+}
+
+// Seconds, minutes, hours, day low and day high
+type rtc struct {
+	current [rtcRegisterCount]uint8
+	latched [rtcRegisterCount]uint8
+
+	cycleCounter uint
+	latchValue   uint8
+}
+
+func (r *rtc) read(register uint8) uint8 {
+	return r.latched[register]
+}
+
+func (r *rtc) write(register uint8, value uint8) {
+	r.current[register] = value & rtcRegisterMasks[register]
+
+	// Writing the seconds resets the part of the second already counted
+	if register == rtcSeconds {
+		r.cycleCounter = 0
+	}
+}
+
+// Writing 0x00 then 0x01 copies the current time into the latched registers
+// that are read by the game
+func (r *rtc) writeLatch(value uint8) {
+	if r.latchValue == 0x00 && value == 0x01 {
+		r.latched = r.current
+	}
+	r.latchValue = value
+}
+
+func (r *rtc) isHalted() bool {
+	return r.current[rtcDayHigh]&rtcHalt == rtcHalt
+}
+
+func (r *rtc) update(cycles uint) {
+	if r.isHalted() {
+		return
+	}
+
+	r.cycleCounter += cycles
+	for r.cycleCounter >= rtcCyclesPerSecond {
+		r.cycleCounter -= rtcCyclesPerSecond
+		r.tick()
+	}
+}
+
+func (r *rtc) tick() {
+	// Registers can be set to values out of range. They keep counting until
+	// they overflow their bits without carrying into the next register.
+	r.current[rtcSeconds] = (r.current[rtcSeconds] + 1) & rtcRegisterMasks[rtcSeconds]
+	if r.current[rtcSeconds] != 60 {
+		return
+	}
+	r.current[rtcSeconds] = 0
+
+	r.current[rtcMinutes] = (r.current[rtcMinutes] + 1) & rtcRegisterMasks[rtcMinutes]
+	if r.current[rtcMinutes] != 60 {
+		return
+	}
+	r.current[rtcMinutes] = 0
+
+	r.current[rtcHours] = (r.current[rtcHours] + 1) & rtcRegisterMasks[rtcHours]
+	if r.current[rtcHours] != 24 {
+		return
+	}
+	r.current[rtcHours] = 0
+
+	r.setDays(r.days() + 1)
+}
+
+func (r *rtc) days() uint16 {
+	return uint16(r.current[rtcDayHigh]&rtcDayMSB)<<8 | uint16(r.current[rtcDayLow])
+}
+
+// The day counter is 9 bits and sets the carry bit when it overflows. The
+// carry stays set until the game clears it.
+func (r *rtc) setDays(days uint16) {
+	if days > 0x1FF {
+		r.current[rtcDayHigh] |= rtcDayCarry
+		days = days % 0x200
+	}
+
+	r.current[rtcDayLow] = uint8(days)
+	r.current[rtcDayHigh] = (r.current[rtcDayHigh] &^ rtcDayMSB) | uint8(days>>8)
+}
+
+func (r *rtc) advance(seconds int64) {
+	if seconds <= 0 || r.isHalted() {
+		return
+	}
+
+	// Tick out of range values until they wrap so the rest can be worked out
+	// in one go
+	for seconds > 0 && (r.current[rtcSeconds] >= 60 || r.current[rtcMinutes] >= 60 || r.current[rtcHours] >= 24) {
+		r.tick()
+		seconds--
+	}
+
+	total := int64(r.current[rtcSeconds]) +
+		int64(r.current[rtcMinutes])*60 +
+		int64(r.current[rtcHours])*60*60 +
+		seconds
+
+	r.current[rtcSeconds] = uint8(total % 60)
+	r.current[rtcMinutes] = uint8((total / 60) % 60)
+	r.current[rtcHours] = uint8((total / (60 * 60)) % 24)
+
+	days := int64(r.days()) + total/(60*60*24)
+	if days > 0x1FF {
+		r.current[rtcDayHigh] |= rtcDayCarry
+		days = days % 0x200
+	}
+	r.setDays(uint16(days))
+}
+
+func (r *rtc) exportBlock(now int64) []uint8 {
+	data := make([]uint8, rtcBlockSize)
+
+	for x := 0; x < rtcRegisterCount; x++ {
+		binary.LittleEndian.PutUint32(data[x*4:], uint32(r.current[x]))
+		binary.LittleEndian.PutUint32(data[(rtcRegisterCount+x)*4:], uint32(r.latched[x]))
+	}
+	binary.LittleEndian.PutUint64(data[rtcRegisterCount*8:], uint64(now))
+
+	return data
+}
+
+// Loads the registers and adds on the time since the block was saved
+func (r *rtc) importBlock(data []uint8, now int64) error {
+	if len(data) != rtcBlockSize && len(data) != rtcBlockSizeShortTime {
+		return errors.New(fmt.Sprintf("RTC block is %d bytes but expected %d or %d", len(data), rtcBlockSize, rtcBlockSizeShortTime))
+	}
+
+	for x := 0; x < rtcRegisterCount; x++ {
+		r.current[x] = uint8(binary.LittleEndian.Uint32(data[x*4:])) & rtcRegisterMasks[x]
+		r.latched[x] = uint8(binary.LittleEndian.Uint32(data[(rtcRegisterCount+x)*4:])) & rtcRegisterMasks[x]
+	}
+
+	var timestamp int64
+	if len(data) == rtcBlockSize {
+		timestamp = int64(binary.LittleEndian.Uint64(data[rtcRegisterCount*8:]))
+	} else {
+		timestamp = int64(binary.LittleEndian.Uint32(data[rtcRegisterCount*8:]))
+	}
+
+	r.advance(now - timestamp)
+	r.cycleCounter = 0
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func createTestRTCCartridge() (*cartridgeMBC3, *fakeClock) {
+	rom := make([]byte, 0x8000)
+	cartridge := createCartridgeMBC3(uint32(len(rom)), 0x2000, &rom, true, true).(*cartridgeMBC3)
+
+	clock := &fakeClock{now: time.Unix(1000000, 0)}
+	cartridge.SetClockSource(clock)
+
+	// Enable RAM and the RTC
+	cartridge.WriteByte(0x0000, 0x0A)
+	return cartridge, clock
+}
+
+func writeRTC(c *cartridgeMBC3, register uint8, value uint8) {
+	c.WriteByte(0x4000, rtcRegisterSelect+register)
+	c.WriteByte(0xA000, value)
+}
+
+func readRTC(c *cartridgeMBC3, register uint8) uint8 {
+	c.WriteByte(0x4000, rtcRegisterSelect+register)
+	return c.ReadByte(0xA000)
+}
+
+func latchRTC(c *cartridgeMBC3) {
+	c.WriteByte(0x6000, 0x00)
+	c.WriteByte(0x6000, 0x01)
+}
+
+func TestRTCReadsLatchedTime(t *testing.T) {
+	c, _ := createTestRTCCartridge()
+	writeRTC(c, rtcSeconds, 10)
+	latchRTC(c)
+
+	c.UpdateForCycles(rtcCyclesPerSecond * 5)
+	assert.Equal(t, uint8(10), readRTC(c, rtcSeconds))
+
+	// Needs 0x00 before 0x01 to latch again
+	c.WriteByte(0x6000, 0x01)
+	assert.Equal(t, uint8(10), readRTC(c, rtcSeconds))
+
+	latchRTC(c)
+	assert.Equal(t, uint8(15), readRTC(c, rtcSeconds))
+}
+
+func TestRTCDayCounterOverflowSetsCarry(t *testing.T) {
+	c, _ := createTestRTCCartridge()
+	writeRTC(c, rtcSeconds, 59)
+	writeRTC(c, rtcMinutes, 59)
+	writeRTC(c, rtcHours, 23)
+	writeRTC(c, rtcDayLow, 0xFF)
+	writeRTC(c, rtcDayHigh, rtcDayMSB)
+
+	c.UpdateForCycles(rtcCyclesPerSecond)
+	latchRTC(c)
+
+	assert.Equal(t, uint8(0), readRTC(c, rtcSeconds))
+	assert.Equal(t, uint8(0), readRTC(c, rtcMinutes))
+	assert.Equal(t, uint8(0), readRTC(c, rtcHours))
+	assert.Equal(t, uint8(0), readRTC(c, rtcDayLow))
+	assert.Equal(t, uint8(rtcDayCarry), readRTC(c, rtcDayHigh))
+
+	// The carry stays set as the days count up again
+	c.UpdateForCycles(rtcCyclesPerSecond * 60 * 60 * 24)
+	latchRTC(c)
+	assert.Equal(t, uint8(1), readRTC(c, rtcDayLow))
+	assert.Equal(t, uint8(rtcDayCarry), readRTC(c, rtcDayHigh))
+}
+
+func TestRTCDayCounterOverflowWhileNotRunning(t *testing.T) {
+	c, clock := createTestRTCCartridge()
+	writeRTC(c, rtcDayLow, 0xFF)
+	writeRTC(c, rtcDayHigh, rtcDayMSB)
+	save := c.ExportBatteryRAM()
+
+	clock.now = clock.now.Add(2 * 24 * time.Hour)
+	require.NoError(t, c.ImportBatteryRAM(save))
+	latchRTC(c)
+
+	assert.Equal(t, uint8(1), readRTC(c, rtcDayLow))
+	assert.Equal(t, uint8(rtcDayCarry), readRTC(c, rtcDayHigh))
+}
+
+func TestRTCHaltStopsClock(t *testing.T) {
+	c, clock := createTestRTCCartridge()
+	writeRTC(c, rtcSeconds, 30)
+	writeRTC(c, rtcDayHigh, rtcHalt)
+
+	c.UpdateForCycles(rtcCyclesPerSecond * 10)
+	latchRTC(c)
+	assert.Equal(t, uint8(30), readRTC(c, rtcSeconds))
+
+	// Doesn't catch up for the time the emulator wasn't running either
+	save := c.ExportBatteryRAM()
+	clock.now = clock.now.Add(time.Hour)
+	require.NoError(t, c.ImportBatteryRAM(save))
+	latchRTC(c)
+	assert.Equal(t, uint8(30), readRTC(c, rtcSeconds))
+	assert.Equal(t, uint8(0), readRTC(c, rtcMinutes))
+
+	// Clearing it starts the clock again
+	writeRTC(c, rtcDayHigh, 0x00)
+	c.UpdateForCycles(rtcCyclesPerSecond * 10)
+	latchRTC(c)
+	assert.Equal(t, uint8(40), readRTC(c, rtcSeconds))
+}
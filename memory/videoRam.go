@@ -2,39 +2,60 @@ package memory
 
 type videoRam struct {
 	mem *Memory
+
+	// Game Boy Color only, selected with VBK
+	bank1 *Memory
+	bank  uint8
 }
 
 func CreateVideoRam() *videoRam {
 	data := make([]byte, 0x2000)
+	data1 := make([]byte, 0x2000)
 	return &videoRam{
-		mem: CreateMemory("video ram", &data, 0x8000),
+		mem:   CreateMemory("video ram", &data, 0x8000),
+		bank1: CreateMemory("video ram bank 1", &data1, 0x8000),
+		bank:  0,
 	}
 }
 
 func (v *videoRam) Reset() {
 	v.mem.Reset()
+	v.bank1.Reset()
+	v.bank = 0
+}
+
+func (v *videoRam) selected() *Memory {
+	return v.memoryForBank(v.bank)
+}
+
+func (v *videoRam) memoryForBank(bank uint8) *Memory {
+	if bank == 1 {
+		return v.bank1
+	}
+
+	return v.mem
 }
 
 func (v *videoRam) ReadBit(address uint16, bit uint8) bool {
-	return v.mem.ReadBit(address, bit)
+	return v.selected().ReadBit(address, bit)
 }
 
 func (v *videoRam) ReadByte(address uint16) byte {
-	return v.mem.ReadByte(address)
+	return v.selected().ReadByte(address)
 }
 
 func (v *videoRam) ReadShort(address uint16) uint16 {
-	return v.mem.ReadShort(address)
+	return v.selected().ReadShort(address)
 }
 
 func (v *videoRam) WriteBit(address uint16, bit uint8, value bool) {
-	v.mem.WriteBit(address, bit, value)
+	v.selected().WriteBit(address, bit, value)
 }
 
 func (v *videoRam) WriteByte(address uint16, value byte) {
-	v.mem.WriteByte(address, value)
+	v.selected().WriteByte(address, value)
 }
 
 func (v *videoRam) WriteShort(address uint16, value uint16) {
-	v.mem.WriteShort(address, value)
+	v.selected().WriteShort(address, value)
 }
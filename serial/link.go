@@ -0,0 +1,35 @@
+package serial
+
+// The other end of the link cable
+type LinkPeer interface {
+	// Sends a byte to the other end, which should be waiting for a transfer
+	// on its external clock, and returns the byte it sent back
+	Transfer(value uint8) uint8
+	Close() error
+}
+
+// A peer that takes a while to reply, e.g. over a network. The transfer is
+// started straight away and the reply is only waited for once all 8 bits have
+// been clocked out so the emulator isn't held up while the other end answers.
+type AsyncLinkPeer interface {
+	LinkPeer
+	StartTransfer(value uint8) <-chan uint8
+}
+
+type inProcessPeer struct {
+	target *Serial
+}
+
+// Links two serial ports in the same process, e.g. for two System instances
+func ConnectInProcess(first *Serial, second *Serial) {
+	first.Connect(&inProcessPeer{target: second})
+	second.Connect(&inProcessPeer{target: first})
+}
+
+func (p *inProcessPeer) Transfer(value uint8) uint8 {
+	return p.target.Receive(value)
+}
+
+func (p *inProcessPeer) Close() error {
+	return nil
+}
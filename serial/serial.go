@@ -0,0 +1,287 @@
+package serial
+
+import (
+	"encoding/gob"
+	"sync"
+
+	"github.com/f1gopher/gbpixellib/interupt"
+)
+
+const SerialData = 0xFF01
+const SerialControl = 0xFF02
+
+// The internal clock runs at 8192Hz
+const cyclesPerBit = 512
+
+// Bits 1-6 of the control register are unused and always read as 1
+const controlReadMask = 0x7E
+
+type interruptInterface interface {
+	Request(i interupt.Interupt)
+}
+
+type Serial struct {
+	interupt interruptInterface
+	peer     LinkPeer
+
+	// Transfers can be started by the peer from another goroutine
+	lock sync.Mutex
+
+	data    uint8
+	control uint8
+
+	transferring  bool
+	incoming      uint8
+	bitsRemaining uint8
+	bitCounter    uint
+
+	// The reply from an asynchronous peer, which replaces the data when the
+	// transfer finishes
+	reply <-chan uint8
+}
+
+func CreateSerial(interupt interruptInterface) *Serial {
+	return &Serial{
+		interupt: interupt,
+		peer:     nil,
+	}
+}
+
+func (s *Serial) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Leave the peer connected, the cable is still plugged in
+	s.data = 0x00
+	s.control = 0x00
+	s.transferring = false
+	s.incoming = 0xFF
+	s.bitsRemaining = 0
+	s.bitCounter = 0
+	s.reply = nil
+}
+
+// Plugs the link cable into a peer. Passing nil unplugs the cable.
+func (s *Serial) Connect(peer LinkPeer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.peer = peer
+}
+
+// The link cable is plugged into a peer
+func (s *Serial) IsConnected() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.peer != nil
+}
+
+func (s *Serial) Disconnect() {
+	s.lock.Lock()
+	peer := s.peer
+	s.peer = nil
+	s.lock.Unlock()
+
+	if peer != nil {
+		peer.Close()
+	}
+}
+
+// Called by the peer when it starts a transfer using its internal clock. If
+// this end is waiting for a transfer on the external clock the value is
+// shifted in and the current data is returned, otherwise the peer sees 0xFF.
+func (s *Serial) Receive(value uint8) uint8 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.transferring || s.control&0x81 != 0x80 {
+		return 0xFF
+	}
+
+	s.startTransfer(value)
+
+	return s.data
+}
+
+func (s *Serial) UpdateForCycles(cycles uint) {
+	s.lock.Lock()
+
+	if !s.transferring {
+		s.lock.Unlock()
+		return
+	}
+
+	completed := false
+	s.bitCounter += cycles
+
+	for s.bitCounter >= cyclesPerBit && s.bitsRemaining > 0 {
+		s.bitCounter -= cyclesPerBit
+
+		s.data = (s.data << 1) | (s.incoming >> 7)
+		s.incoming = s.incoming << 1
+		s.bitsRemaining--
+
+		if s.bitsRemaining == 0 {
+			s.transferring = false
+			s.control = s.control &^ 0x80
+			completed = true
+		}
+	}
+
+	reply := s.reply
+	if completed {
+		s.reply = nil
+	}
+
+	s.lock.Unlock()
+
+	// Until the reply arrives the bits shifted in are 1s as if nothing was
+	// connected. The peer times out so this can't wait forever.
+	if completed && reply != nil {
+		value := <-reply
+		s.lock.Lock()
+		s.data = value
+		s.lock.Unlock()
+	}
+
+	if completed {
+		s.interupt.Request(interupt.Serial)
+	}
+}
+
+func (s *Serial) startTransfer(incoming uint8) {
+	s.transferring = true
+	s.incoming = incoming
+	s.bitsRemaining = 8
+	s.bitCounter = 0
+}
+
+func (s *Serial) ReadBit(address uint16, bit uint8) bool {
+	return (s.ReadByte(address)>>bit)&0x01 == 0x01
+}
+
+func (s *Serial) ReadByte(address uint16) byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if address == SerialControl {
+		return s.control | controlReadMask
+	}
+
+	return s.data
+}
+
+func (s *Serial) ReadShort(address uint16) uint16 {
+	lsb := s.ReadByte(address)
+	msb := s.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
+
+func (s *Serial) WriteBit(address uint16, bit uint8, value bool) {
+	current := s.ReadByte(address)
+	if value {
+		current = current | 0x01<<bit
+	} else {
+		current = current &^ (0x01 << bit)
+	}
+	s.WriteByte(address, current)
+}
+
+func (s *Serial) WriteShort(address uint16, value uint16) {
+	s.WriteByte(address, uint8(value))
+	s.WriteByte(address+1, uint8(value>>8))
+}
+
+func (s *Serial) WriteByte(address uint16, value byte) {
+	s.lock.Lock()
+
+	if address == SerialData {
+		s.data = value
+		s.lock.Unlock()
+		return
+	}
+
+	s.control = value & 0x81
+
+	// Clearing the start bit stops any transfer in progress
+	if s.control&0x80 == 0 {
+		s.transferring = false
+		s.reply = nil
+		s.lock.Unlock()
+		return
+	}
+
+	// With the external clock we wait for the peer to start the transfer
+	if s.control&0x01 == 0 || s.transferring {
+		s.lock.Unlock()
+		return
+	}
+
+	peer := s.peer
+	data := s.data
+
+	if async, ok := peer.(AsyncLinkPeer); ok {
+		s.startTransfer(0xFF)
+		s.reply = async.StartTransfer(data)
+		s.lock.Unlock()
+		return
+	}
+
+	s.lock.Unlock()
+
+	// Don't hold the lock while talking to the peer in case it is starting a
+	// transfer with us at the same time. With nothing connected the input
+	// line is pulled high.
+	var incoming uint8 = 0xFF
+	if peer != nil {
+		incoming = peer.Transfer(data)
+	}
+
+	s.lock.Lock()
+	s.startTransfer(incoming)
+	s.lock.Unlock()
+}
+
+type serialState struct {
+	Data          uint8
+	Control       uint8
+	Transferring  bool
+	Incoming      uint8
+	BitsRemaining uint8
+	BitCounter    uint
+}
+
+func (s *Serial) SaveState(enc *gob.Encoder) error {
+	s.lock.Lock()
+	state := serialState{
+		Data:          s.data,
+		Control:       s.control,
+		Transferring:  s.transferring,
+		Incoming:      s.incoming,
+		BitsRemaining: s.bitsRemaining,
+		BitCounter:    s.bitCounter,
+	}
+	s.lock.Unlock()
+
+	return enc.Encode(state)
+}
+
+func (s *Serial) LoadState(dec *gob.Decoder) error {
+	var state serialState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = state.Data
+	s.control = state.Control
+	s.transferring = state.Transferring
+	s.incoming = state.Incoming
+	s.bitsRemaining = state.BitsRemaining
+	s.bitCounter = state.BitCounter
+	s.reply = nil
+	return nil
+}
@@ -0,0 +1,110 @@
+package serial
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/interupt"
+	"github.com/stretchr/testify/assert"
+)
+
+type interruptRecorder struct {
+	requested []interupt.Interupt
+}
+
+func (i *interruptRecorder) Request(value interupt.Interupt) {
+	i.requested = append(i.requested, value)
+}
+
+func TestInternalClockWithNothingConnected(t *testing.T) {
+	interrupts := &interruptRecorder{}
+	s := CreateSerial(interrupts)
+	s.Reset()
+
+	s.WriteByte(SerialData, 0x42)
+	s.WriteByte(SerialControl, 0x81)
+	assert.Equal(t, uint8(0xFF), s.ReadByte(SerialControl))
+
+	s.UpdateForCycles(cyclesPerBit*8 - 1)
+	assert.Empty(t, interrupts.requested)
+
+	s.UpdateForCycles(1)
+	assert.Equal(t, []interupt.Interupt{interupt.Serial}, interrupts.requested)
+	assert.Equal(t, uint8(0xFF), s.ReadByte(SerialData))
+	assert.Equal(t, uint8(0x7F), s.ReadByte(SerialControl))
+}
+
+func TestInProcessTransfer(t *testing.T) {
+	masterInterrupts := &interruptRecorder{}
+	slaveInterrupts := &interruptRecorder{}
+	master := CreateSerial(masterInterrupts)
+	slave := CreateSerial(slaveInterrupts)
+	master.Reset()
+	slave.Reset()
+	ConnectInProcess(master, slave)
+
+	slave.WriteByte(SerialData, 0x12)
+	slave.WriteByte(SerialControl, 0x80)
+	master.WriteByte(SerialData, 0x34)
+	master.WriteByte(SerialControl, 0x81)
+
+	// Bits are shifted in one at a time
+	master.UpdateForCycles(cyclesPerBit * 4)
+	slave.UpdateForCycles(cyclesPerBit * 4)
+	assert.Equal(t, uint8(0x41), master.ReadByte(SerialData))
+	assert.Equal(t, uint8(0x23), slave.ReadByte(SerialData))
+
+	master.UpdateForCycles(cyclesPerBit * 4)
+	slave.UpdateForCycles(cyclesPerBit * 4)
+	assert.Equal(t, uint8(0x12), master.ReadByte(SerialData))
+	assert.Equal(t, uint8(0x34), slave.ReadByte(SerialData))
+	assert.Equal(t, []interupt.Interupt{interupt.Serial}, masterInterrupts.requested)
+	assert.Equal(t, []interupt.Interupt{interupt.Serial}, slaveInterrupts.requested)
+}
+
+func TestExternalClockIgnoredWhenNotWaiting(t *testing.T) {
+	s := CreateSerial(&interruptRecorder{})
+	s.Reset()
+
+	s.WriteByte(SerialData, 0x55)
+	assert.Equal(t, uint8(0xFF), s.Receive(0xAA))
+	assert.Equal(t, uint8(0x55), s.ReadByte(SerialData))
+}
+
+type slowPeer struct {
+	sent    []uint8
+	replies chan uint8
+}
+
+func (p *slowPeer) Transfer(value uint8) uint8 {
+	panic("Asynchronous peers shouldn't be waited on")
+}
+
+func (p *slowPeer) StartTransfer(value uint8) <-chan uint8 {
+	p.sent = append(p.sent, value)
+	return p.replies
+}
+
+func (p *slowPeer) Close() error {
+	return nil
+}
+
+func TestAsyncPeerReplyUsedWhenTransferFinishes(t *testing.T) {
+	interrupts := &interruptRecorder{}
+	peer := &slowPeer{replies: make(chan uint8, 1)}
+	s := CreateSerial(interrupts)
+	s.Reset()
+	s.Connect(peer)
+
+	s.WriteByte(SerialData, 0x34)
+	s.WriteByte(SerialControl, 0x81)
+	assert.Equal(t, []uint8{0x34}, peer.sent)
+
+	// Keeps running while the reply is on its way
+	s.UpdateForCycles(cyclesPerBit * 4)
+	assert.Equal(t, uint8(0x4F), s.ReadByte(SerialData))
+
+	peer.replies <- 0x12
+	s.UpdateForCycles(cyclesPerBit * 4)
+	assert.Equal(t, uint8(0x12), s.ReadByte(SerialData))
+	assert.Equal(t, []interupt.Interupt{interupt.Serial}, interrupts.requested)
+}
@@ -0,0 +1,136 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// If the other end doesn't answer in time treat it as if nothing is connected
+const DefaultTransferTimeout = time.Second
+
+const (
+	messageTransfer uint8 = iota
+	messageReply
+)
+
+// A link cable over a TCP socket on localhost. Each message is two bytes, the
+// message type followed by the value. Transfers are asynchronous so emulation
+// only stalls if the reply hasn't arrived by the time the transfer finishes,
+// and then for no longer than the timeout.
+type TCPPeer struct {
+	conn     net.Conn
+	local    *Serial
+	replies  chan uint8
+	sendLock sync.Mutex
+	timeout  time.Duration
+}
+
+// Waits for the other end to connect on the given port
+func ListenTCP(port int, local *Serial) (*TCPPeer, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, errors.Join(errors.New("Failed to listen for link cable"), err)
+	}
+	defer listener.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, errors.Join(errors.New("Failed to accept link cable connection"), err)
+	}
+
+	return createTCPPeer(conn, local), nil
+}
+
+func DialTCP(port int, local *Serial) (*TCPPeer, error) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, errors.Join(errors.New("Failed to connect link cable"), err)
+	}
+
+	return createTCPPeer(conn, local), nil
+}
+
+func createTCPPeer(conn net.Conn, local *Serial) *TCPPeer {
+	t := &TCPPeer{
+		conn:    conn,
+		local:   local,
+		replies: make(chan uint8, 1),
+		timeout: DefaultTransferTimeout,
+	}
+
+	go t.run()
+
+	return t
+}
+
+// How long to wait for the other end to reply before treating it as
+// disconnected
+func (t *TCPPeer) SetTimeout(timeout time.Duration) {
+	t.timeout = timeout
+}
+
+func (t *TCPPeer) StartTransfer(value uint8) <-chan uint8 {
+	reply := make(chan uint8, 1)
+	go func() {
+		reply <- t.Transfer(value)
+	}()
+
+	return reply
+}
+
+func (t *TCPPeer) Transfer(value uint8) uint8 {
+	// Throw away any reply that arrived after a previous transfer timed out
+	select {
+	case <-t.replies:
+	default:
+	}
+
+	if err := t.send(messageTransfer, value); err != nil {
+		return 0xFF
+	}
+
+	select {
+	case reply := <-t.replies:
+		return reply
+	case <-time.After(t.timeout):
+		return 0xFF
+	}
+}
+
+func (t *TCPPeer) Close() error {
+	return t.conn.Close()
+}
+
+func (t *TCPPeer) send(messageType uint8, value uint8) error {
+	t.sendLock.Lock()
+	defer t.sendLock.Unlock()
+
+	_, err := t.conn.Write([]byte{messageType, value})
+	return err
+}
+
+func (t *TCPPeer) run() {
+	message := make([]byte, 2)
+
+	for {
+		if _, err := io.ReadFull(t.conn, message); err != nil {
+			return
+		}
+
+		switch message[0] {
+		case messageTransfer:
+			if err := t.send(messageReply, t.local.Receive(message[1])); err != nil {
+				return
+			}
+		case messageReply:
+			select {
+			case t.replies <- message[1]:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package sgb
+
+// ATTR_BLK control bits for each block
+const (
+	blockInside  = 0x01
+	blockLine    = 0x02
+	blockOutside = 0x04
+)
+
+func (s *SuperGameBoy) setAttribute(x int, y int, palette uint8) {
+	if x < 0 || x >= attributeWidth || y < 0 || y >= attributeHeight {
+		return
+	}
+
+	s.attributes[y*attributeWidth+x] = palette & 0x03
+}
+
+// Each block is 6 bytes: control, palettes, then the top left and bottom right
+// corners. The line is the edge of the block.
+func (s *SuperGameBoy) attributeBlocks(data []uint8) {
+	count := int(data[1])
+
+	for block := 0; block < count; block++ {
+		offset := 2 + block*6
+		if offset+6 > len(data) {
+			return
+		}
+
+		control := data[offset]
+		palettes := data[offset+1]
+		x1 := int(data[offset+2] & 0x1F)
+		y1 := int(data[offset+3] & 0x1F)
+		x2 := int(data[offset+4] & 0x1F)
+		y2 := int(data[offset+5] & 0x1F)
+
+		inside := palettes & 0x03
+		line := (palettes >> 2) & 0x03
+		outside := (palettes >> 4) & 0x03
+
+		// If only the inside or outside is changed the line is changed too
+		if control&blockLine == 0 {
+			if control&(blockInside|blockOutside) == blockInside {
+				control |= blockLine
+				line = inside
+			} else if control&(blockInside|blockOutside) == blockOutside {
+				control |= blockLine
+				line = outside
+			}
+		}
+
+		for y := 0; y < attributeHeight; y++ {
+			for x := 0; x < attributeWidth; x++ {
+				if x > x1 && x < x2 && y > y1 && y < y2 {
+					if control&blockInside != 0 {
+						s.setAttribute(x, y, inside)
+					}
+				} else if x >= x1 && x <= x2 && y >= y1 && y <= y2 {
+					if control&blockLine != 0 {
+						s.setAttribute(x, y, line)
+					}
+				} else if control&blockOutside != 0 {
+					s.setAttribute(x, y, outside)
+				}
+			}
+		}
+	}
+}
+
+// Each byte sets a whole row or column
+//
+// Bit 7 Horizontal line (row) when set, vertical line (column) when clear
+// Bit 5-6 Palette
+// Bit 0-4 Line number
+func (s *SuperGameBoy) attributeLines(data []uint8) {
+	count := int(data[1])
+
+	for index := 0; index < count && 2+index < len(data); index++ {
+		value := data[2+index]
+		line := int(value & 0x1F)
+		palette := (value >> 5) & 0x03
+
+		if value&0x80 == 0x80 {
+			for x := 0; x < attributeWidth; x++ {
+				s.setAttribute(x, line, palette)
+			}
+		} else {
+			for y := 0; y < attributeHeight; y++ {
+				s.setAttribute(line, y, palette)
+			}
+		}
+	}
+}
+
+// Splits the screen in two along a row or column
+//
+// Bit 6 Split by a row when set, by a column when clear
+// Bit 4-5 Palette for the dividing line
+// Bit 2-3 Palette above or left of the line
+// Bit 0-1 Palette below or right of the line
+func (s *SuperGameBoy) attributeDivide(data []uint8) {
+	settings := data[1]
+	position := int(data[2] & 0x1F)
+
+	after := settings & 0x03
+	before := (settings >> 2) & 0x03
+	line := (settings >> 4) & 0x03
+	byRow := settings&0x40 == 0x40
+
+	for y := 0; y < attributeHeight; y++ {
+		for x := 0; x < attributeWidth; x++ {
+			coordinate := x
+			if byRow {
+				coordinate = y
+			}
+
+			palette := line
+			if coordinate < position {
+				palette = before
+			} else if coordinate > position {
+				palette = after
+			}
+			s.setAttribute(x, y, palette)
+		}
+	}
+}
+
+// Sets the palette for each 8x8 area starting at a position and moving
+// across or down. Each byte has 4 palettes with the first in the top bits.
+func (s *SuperGameBoy) attributeCharacters(data []uint8) {
+	x := int(data[1] & 0x1F)
+	y := int(data[2] & 0x1F)
+	count := int(data[3]) | int(data[4])<<8
+	topToBottom := data[5]&0x01 == 0x01
+
+	for index := 0; index < count; index++ {
+		offset := 6 + index/4
+		if offset >= len(data) || x >= attributeWidth || y >= attributeHeight {
+			return
+		}
+
+		palette := (data[offset] >> (6 - (index%4)*2)) & 0x03
+		s.setAttribute(x, y, palette)
+
+		if topToBottom {
+			y++
+			if y == attributeHeight {
+				y = 0
+				x++
+			}
+		} else {
+			x++
+			if x == attributeWidth {
+				x = 0
+				y++
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package sgb
+
+// Each VRAM transfer is 4KiB
+const transferSize = 0x1000
+
+// The border uses 256 SNES tiles with 4 bits per pixel
+const borderTiles = 256
+const borderTileSize = 32
+
+// The map is 32x32 tiles but only 28 rows are on screen
+const borderMapWidth = 32
+const borderMapEntries = borderMapWidth * borderMapWidth
+
+// After the map PCT_TRN sends palettes 4-7 used by the border
+const borderPaletteOffset = 0x800
+const borderPalettes = 4
+const borderPaletteColors = 16
+
+// Tile map entries
+//
+// Bit 15 Y flip
+// Bit 14 X flip
+// Bit 10-12 Palette 4-7
+// Bit 0-9 Tile, only 0-255 are used
+const borderYFlip = 0x8000
+const borderXFlip = 0x4000
+
+type border struct {
+	tiles    [borderTiles * borderTileSize]uint8
+	tileMap  [borderMapEntries]uint16
+	palettes [borderPalettes][borderPaletteColors]uint16
+}
+
+func (b *border) reset() {
+	b.tiles = [borderTiles * borderTileSize]uint8{}
+	b.tileMap = [borderMapEntries]uint16{}
+	b.palettes = [borderPalettes][borderPaletteColors]uint16{}
+}
+
+// CHR_TRN sends half the tiles each time
+func (b *border) setTiles(upperHalf bool, data []uint8) {
+	offset := 0
+	if upperHalf {
+		offset = transferSize
+	}
+
+	copy(b.tiles[offset:offset+transferSize], data)
+}
+
+func (b *border) setMap(data []uint8) {
+	for x := 0; x < borderMapEntries; x++ {
+		b.tileMap[x] = uint16(data[x*2+1])<<8 | uint16(data[x*2])
+	}
+
+	for palette := 0; palette < borderPalettes; palette++ {
+		for index := 0; index < borderPaletteColors; index++ {
+			offset := borderPaletteOffset + (palette*borderPaletteColors+index)*2
+			b.palettes[palette][index] = readColor(data, offset)
+		}
+	}
+}
+
+// Colour 0 is transparent so returns false
+func (b *border) pixel(x int, y int) (color uint16, visible bool) {
+	entry := b.tileMap[(y/8)*borderMapWidth+x/8]
+
+	tileX := x % 8
+	tileY := y % 8
+	if entry&borderXFlip == borderXFlip {
+		tileX = 7 - tileX
+	}
+	if entry&borderYFlip == borderYFlip {
+		tileY = 7 - tileY
+	}
+
+	// Bit planes 0 and 1 are in the first 16 bytes and 2 and 3 in the rest
+	tile := b.tiles[int(entry&0xFF)*borderTileSize:]
+	bit := uint(7 - tileX)
+	index := (tile[tileY*2]>>bit)&0x01 |
+		((tile[tileY*2+1]>>bit)&0x01)<<1 |
+		((tile[16+tileY*2]>>bit)&0x01)<<2 |
+		((tile[16+tileY*2+1]>>bit)&0x01)<<3
+
+	if index == 0 {
+		return 0, false
+	}
+
+	palette := (entry >> 10) & 0x03
+	return b.palettes[palette][index], true
+}
@@ -0,0 +1,347 @@
+package sgb
+
+import (
+	"github.com/f1gopher/gbpixellib/display"
+)
+
+// The Super Game Boy draws the Game Boy screen in the middle of a larger
+// picture with a border around it
+const FrameWidth = 256
+const FrameHeight = 224
+
+const gameWidth = 160
+const gameHeight = 144
+const gameX = (FrameWidth - gameWidth) / 2
+const gameY = 40
+
+// Palettes are picked for each 8x8 area of the Game Boy screen
+const attributeWidth = gameWidth / 8
+const attributeHeight = gameHeight / 8
+
+// Packets are 16 bytes sent one bit at a time with the lowest bit first
+const packetSize = 16
+const packetBits = packetSize * 8
+
+// Joypad register bits used to send packets. Pulling P14 low sends a 0 and
+// pulling P15 low sends a 1. Pulling both low starts a packet.
+const joypadLines = 0x30
+const joypadReset = 0x00
+const joypadP14Low = 0x20
+const joypadP15Low = 0x10
+const joypadHigh = 0x30
+
+const (
+	commandPAL01   = 0x00
+	commandPAL23   = 0x01
+	commandPAL03   = 0x02
+	commandPAL12   = 0x03
+	commandATTRBLK = 0x04
+	commandATTRLIN = 0x05
+	commandATTRDIV = 0x06
+	commandATTRCHR = 0x07
+	commandMLTREQ  = 0x11
+	commandCHRTRN  = 0x13
+	commandPCTTRN  = 0x14
+	commandMASKEN  = 0x17
+)
+
+// MASK_EN values
+const (
+	maskCancel = 0x00
+	maskFreeze = 0x01
+	maskBlack  = 0x02
+	maskColor0 = 0x03
+)
+
+// Until the game sets the palettes the screen uses shades of grey
+var defaultPalette = [4]uint16{0x7FFF, 0x56B5, 0x294A, 0x0000}
+
+type SuperGameBoy struct {
+	// Only games that say they support the Super Game Boy can send commands
+	enabled bool
+
+	receiving   bool
+	readyForBit bool
+	bitCount    int
+	packet      [packetSize]uint8
+	command     []uint8
+	lines       uint8
+
+	players uint8
+	player  uint8
+
+	palettes   [4][4]uint16
+	attributes [attributeWidth * attributeHeight]uint8
+	mask       uint8
+
+	// The last complete frame from the Game Boy and the one shown while the
+	// screen is frozen
+	frame  []display.ScreenColor
+	frozen []display.ScreenColor
+
+	// VRAM transfers take the data from the next frame
+	pendingTransfer uint8
+	transferFlags   uint8
+
+	border border
+}
+
+func CreateSuperGameBoy() *SuperGameBoy {
+	s := &SuperGameBoy{
+		frame:  make([]display.ScreenColor, gameWidth*gameHeight),
+		frozen: make([]display.ScreenColor, gameWidth*gameHeight),
+	}
+	s.Reset()
+	return s
+}
+
+func (s *SuperGameBoy) Reset() {
+	s.receiving = false
+	s.readyForBit = false
+	s.bitCount = 0
+	s.packet = [packetSize]uint8{}
+	s.command = nil
+	s.lines = joypadHigh
+	s.players = 1
+	s.player = 0
+	for x := range s.palettes {
+		s.palettes[x] = defaultPalette
+	}
+	s.attributes = [attributeWidth * attributeHeight]uint8{}
+	s.mask = maskCancel
+	for x := range s.frame {
+		s.frame[x] = display.White
+		s.frozen[x] = display.White
+	}
+	s.pendingTransfer = 0
+	s.transferFlags = 0
+	s.border.reset()
+}
+
+// The game header decides if the Super Game Boy listens to commands
+func (s *SuperGameBoy) SetEnabled(enabled bool) {
+	s.enabled = enabled
+}
+
+// Called for each write to the joypad register
+func (s *SuperGameBoy) JoypadWrite(value uint8) {
+	lines := value & joypadLines
+
+	switch lines {
+	case joypadReset:
+		s.receiving = true
+		s.readyForBit = false
+		s.bitCount = 0
+		s.packet = [packetSize]uint8{}
+	case joypadP14Low, joypadP15Low:
+		if s.receiving && s.readyForBit {
+			s.readyForBit = false
+			s.receiveBit(lines == joypadP15Low)
+		}
+	case joypadHigh:
+		s.readyForBit = true
+
+		// The next joypad is selected when P15 goes high
+		if !s.receiving && s.lines == joypadP15Low {
+			s.player = (s.player + 1) % s.players
+		}
+	}
+
+	s.lines = lines
+}
+
+// The joypad being read when running with multiple players. The first player
+// is 0.
+func (s *SuperGameBoy) JoypadPlayer() uint8 {
+	return s.player
+}
+
+func (s *SuperGameBoy) receiveBit(bit bool) {
+	// Each packet ends with a 0 stop bit
+	if s.bitCount == packetBits {
+		s.receiving = false
+		if !bit {
+			s.packetReceived()
+		}
+		return
+	}
+
+	if bit {
+		s.packet[s.bitCount/8] |= 1 << (s.bitCount % 8)
+	}
+	s.bitCount++
+}
+
+// The first byte of a command has the command in the top 5 bits and the
+// number of packets in the bottom 3. The following packets are all data.
+func (s *SuperGameBoy) packetReceived() {
+	if !s.enabled {
+		return
+	}
+
+	s.command = append(s.command, s.packet[:]...)
+
+	length := int(s.command[0] & 0x07)
+	if length == 0 {
+		length = 1
+	}
+
+	if len(s.command) < length*packetSize {
+		return
+	}
+
+	command := s.command
+	s.command = nil
+	s.execute(command)
+}
+
+func (s *SuperGameBoy) execute(data []uint8) {
+	switch data[0] >> 3 {
+	case commandPAL01:
+		s.setPalettes(0, 1, data)
+	case commandPAL23:
+		s.setPalettes(2, 3, data)
+	case commandPAL03:
+		s.setPalettes(0, 3, data)
+	case commandPAL12:
+		s.setPalettes(1, 2, data)
+	case commandATTRBLK:
+		s.attributeBlocks(data)
+	case commandATTRLIN:
+		s.attributeLines(data)
+	case commandATTRDIV:
+		s.attributeDivide(data)
+	case commandATTRCHR:
+		s.attributeCharacters(data)
+	case commandMLTREQ:
+		s.multiplayerRequest(data[1])
+	case commandCHRTRN, commandPCTTRN:
+		s.pendingTransfer = data[0] >> 3
+		s.transferFlags = data[1]
+	case commandMASKEN:
+		s.setMask(data[1] & 0x03)
+	}
+
+	// Other commands are for the SNES and are ignored
+}
+
+// Colour 0 is shared by all palettes
+func (s *SuperGameBoy) setPalettes(first int, second int, data []uint8) {
+	color0 := readColor(data, 1)
+	for x := range s.palettes {
+		s.palettes[x][0] = color0
+	}
+
+	for x := 1; x < 4; x++ {
+		s.palettes[first][x] = readColor(data, 1+x*2)
+		s.palettes[second][x] = readColor(data, 7+x*2)
+	}
+}
+
+func readColor(data []uint8, offset int) uint16 {
+	return (uint16(data[offset+1])<<8 | uint16(data[offset])) & 0x7FFF
+}
+
+// Two players can be selected with 1 and four with 3
+func (s *SuperGameBoy) multiplayerRequest(value uint8) {
+	switch value & 0x03 {
+	case 0x01:
+		s.players = 2
+	case 0x03:
+		s.players = 4
+	default:
+		s.players = 1
+	}
+	s.player = 0
+}
+
+func (s *SuperGameBoy) setMask(mask uint8) {
+	if mask == maskFreeze && s.mask != maskFreeze {
+		copy(s.frozen, s.frame)
+	}
+	s.mask = mask
+}
+
+// Called by the display at the start of each VBlank with the finished frame
+func (s *SuperGameBoy) FrameComplete(buffer []display.ScreenColor) {
+	copy(s.frame, buffer)
+
+	switch s.pendingTransfer {
+	case commandCHRTRN:
+		s.border.setTiles(s.transferFlags&0x01 == 0x01, s.transferData())
+	case commandPCTTRN:
+		s.border.setMap(s.transferData())
+	}
+	s.pendingTransfer = 0
+}
+
+// VRAM transfers send 4KiB by showing 256 tiles in order on the screen. Each
+// shade is turned back into the 2 bits that were used to draw it.
+func (s *SuperGameBoy) transferData() []uint8 {
+	data := make([]uint8, transferSize)
+
+	for tile := 0; tile < transferSize/16; tile++ {
+		tileX := (tile % attributeWidth) * 8
+		tileY := (tile / attributeWidth) * 8
+
+		for line := 0; line < 8; line++ {
+			var low uint8
+			var high uint8
+			for x := 0; x < 8; x++ {
+				shade := shadeIndex(s.frame[(tileY+line)*gameWidth+tileX+x])
+				low |= (shade & 0x01) << (7 - x)
+				high |= (shade >> 1) << (7 - x)
+			}
+			data[tile*16+line*2] = low
+			data[tile*16+line*2+1] = high
+		}
+	}
+
+	return data
+}
+
+func shadeIndex(color display.ScreenColor) uint8 {
+	switch color {
+	case display.LightGray:
+		return 1
+	case display.DarkGray:
+		return 2
+	case display.Black:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Draws the whole picture including the border
+func (s *SuperGameBoy) Render(callback func(x int, y int, color display.ScreenColor)) {
+	for y := 0; y < FrameHeight; y++ {
+		for x := 0; x < FrameWidth; x++ {
+			if x >= gameX && x < gameX+gameWidth && y >= gameY && y < gameY+gameHeight {
+				callback(x, y, display.RGB555(s.gamePixel(x-gameX, y-gameY)))
+				continue
+			}
+
+			color, visible := s.border.pixel(x, y)
+			if !visible {
+				color = s.palettes[0][0]
+			}
+			callback(x, y, display.RGB555(color))
+		}
+	}
+}
+
+func (s *SuperGameBoy) gamePixel(x int, y int) uint16 {
+	frame := s.frame
+	switch s.mask {
+	case maskFreeze:
+		frame = s.frozen
+	case maskBlack:
+		return 0x0000
+	case maskColor0:
+		return s.palettes[0][0]
+	}
+
+	palette := s.attributes[(y/8)*attributeWidth+x/8]
+	return s.palettes[palette][shadeIndex(frame[y*gameWidth+x])]
+}
@@ -0,0 +1,166 @@
+package sgb
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/display"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendPacket(s *SuperGameBoy, packet [packetSize]uint8) {
+	s.JoypadWrite(joypadReset)
+	s.JoypadWrite(joypadHigh)
+
+	for bit := 0; bit < packetBits; bit++ {
+		if packet[bit/8]&(1<<(bit%8)) != 0 {
+			s.JoypadWrite(joypadP15Low)
+		} else {
+			s.JoypadWrite(joypadP14Low)
+		}
+		s.JoypadWrite(joypadHigh)
+	}
+
+	// Stop bit
+	s.JoypadWrite(joypadP14Low)
+	s.JoypadWrite(joypadHigh)
+}
+
+func createEnabled() *SuperGameBoy {
+	s := CreateSuperGameBoy()
+	s.SetEnabled(true)
+	return s
+}
+
+func TestPaletteCommand(t *testing.T) {
+	s := createEnabled()
+
+	sendPacket(s, [packetSize]uint8{
+		commandPAL01<<3 | 1,
+		0x1F, 0x00,
+		0x01, 0x00, 0x02, 0x00, 0x03, 0x00,
+		0x04, 0x00, 0x05, 0x00, 0x06, 0x80,
+	})
+
+	assert.Equal(t, [4]uint16{0x001F, 0x0001, 0x0002, 0x0003}, s.palettes[0])
+	assert.Equal(t, [4]uint16{0x001F, 0x0004, 0x0005, 0x0006}, s.palettes[1])
+	assert.Equal(t, uint16(0x001F), s.palettes[2][0])
+	assert.Equal(t, defaultPalette[1], s.palettes[2][1])
+}
+
+func TestCommandsIgnoredWhenNotSupported(t *testing.T) {
+	s := CreateSuperGameBoy()
+
+	sendPacket(s, [packetSize]uint8{commandPAL01<<3 | 1, 0x1F, 0x00})
+
+	assert.Equal(t, defaultPalette, s.palettes[0])
+}
+
+func TestAttributeBlock(t *testing.T) {
+	s := createEnabled()
+
+	// Inside palette 1, line palette 2 and outside palette 3
+	sendPacket(s, [packetSize]uint8{
+		commandATTRBLK<<3 | 1,
+		1,
+		blockInside | blockLine | blockOutside, 0x39, 2, 2, 5, 5,
+	})
+
+	assert.Equal(t, uint8(1), s.attributes[3*attributeWidth+3])
+	assert.Equal(t, uint8(2), s.attributes[2*attributeWidth+4])
+	assert.Equal(t, uint8(2), s.attributes[5*attributeWidth+5])
+	assert.Equal(t, uint8(3), s.attributes[0])
+	assert.Equal(t, uint8(3), s.attributes[6*attributeWidth+6])
+}
+
+func TestAttributeBlockLineUsesInsideWhenOnlyInsideSet(t *testing.T) {
+	s := createEnabled()
+
+	sendPacket(s, [packetSize]uint8{
+		commandATTRBLK<<3 | 1,
+		1,
+		blockInside, 0x01, 2, 2, 5, 5,
+	})
+
+	assert.Equal(t, uint8(1), s.attributes[2*attributeWidth+2])
+	assert.Equal(t, uint8(1), s.attributes[4*attributeWidth+4])
+	assert.Equal(t, uint8(0), s.attributes[0])
+}
+
+func TestAttributeDivide(t *testing.T) {
+	s := createEnabled()
+
+	// Split by row 4, palette 1 above, 2 on the line and 3 below
+	sendPacket(s, [packetSize]uint8{
+		commandATTRDIV<<3 | 1,
+		0x40 | 2<<4 | 1<<2 | 3,
+		4,
+	})
+
+	assert.Equal(t, uint8(1), s.attributes[3*attributeWidth])
+	assert.Equal(t, uint8(2), s.attributes[4*attributeWidth+10])
+	assert.Equal(t, uint8(3), s.attributes[17*attributeWidth+19])
+}
+
+func TestMultiplayer(t *testing.T) {
+	s := createEnabled()
+
+	sendPacket(s, [packetSize]uint8{commandMLTREQ<<3 | 1, 0x01})
+	assert.Equal(t, uint8(0), s.JoypadPlayer())
+
+	s.JoypadWrite(joypadP15Low)
+	s.JoypadWrite(joypadHigh)
+	assert.Equal(t, uint8(1), s.JoypadPlayer())
+
+	s.JoypadWrite(joypadP15Low)
+	s.JoypadWrite(joypadHigh)
+	assert.Equal(t, uint8(0), s.JoypadPlayer())
+}
+
+func TestBorderTransfer(t *testing.T) {
+	s := createEnabled()
+
+	// Tile 0 is solid colour 1
+	sendPacket(s, [packetSize]uint8{commandCHRTRN<<3 | 1, 0x00})
+	frame := make([]display.ScreenColor, gameWidth*gameHeight)
+	for x := range frame {
+		frame[x] = display.White
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			frame[y*gameWidth+x] = display.LightGray
+		}
+	}
+	s.FrameComplete(frame)
+
+	// The first map entry uses tile 0 and palette 4 which has colour 1 as 0x00FF
+	sendPacket(s, [packetSize]uint8{commandPCTTRN<<3 | 1})
+	for x := range frame {
+		frame[x] = display.White
+	}
+	// The first map entry is 0x1000 so the high byte is shade 2 at pixel 3
+	frame[3] = display.DarkGray
+	// First palette colour 1 is at 0x802, tile 128 line 1 low byte
+	tile := borderPaletteOffset / 16
+	for x := 0; x < 8; x++ {
+		frame[(tile/attributeWidth*8+1)*gameWidth+tile%attributeWidth*8+x] = display.LightGray
+	}
+	s.FrameComplete(frame)
+
+	color, visible := s.border.pixel(0, 0)
+	assert.True(t, visible)
+	assert.Equal(t, uint16(0x00FF), color)
+}
+
+func TestMaskBlack(t *testing.T) {
+	s := createEnabled()
+
+	sendPacket(s, [packetSize]uint8{commandMASKEN<<3 | 1, maskBlack})
+
+	var center display.ScreenColor
+	s.Render(func(x int, y int, color display.ScreenColor) {
+		if x == gameX && y == gameY {
+			center = color
+		}
+	})
+	assert.Equal(t, display.RGB555(0x0000), center)
+}
@@ -0,0 +1,90 @@
+package sgb
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/f1gopher/gbpixellib/display"
+)
+
+type sgbState struct {
+	Enabled         bool
+	Receiving       bool
+	ReadyForBit     bool
+	BitCount        int
+	Packet          [packetSize]uint8
+	Command         []uint8
+	Lines           uint8
+	Players         uint8
+	Player          uint8
+	Palettes        [4][4]uint16
+	Attributes      [attributeWidth * attributeHeight]uint8
+	Mask            uint8
+	Frame           []display.ScreenColor
+	Frozen          []display.ScreenColor
+	PendingTransfer uint8
+	TransferFlags   uint8
+	BorderTiles     [borderTiles * borderTileSize]uint8
+	BorderMap       [borderMapEntries]uint16
+	BorderPalettes  [borderPalettes][borderPaletteColors]uint16
+}
+
+func (s *SuperGameBoy) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(sgbState{
+		Enabled:         s.enabled,
+		Receiving:       s.receiving,
+		ReadyForBit:     s.readyForBit,
+		BitCount:        s.bitCount,
+		Packet:          s.packet,
+		Command:         s.command,
+		Lines:           s.lines,
+		Players:         s.players,
+		Player:          s.player,
+		Palettes:        s.palettes,
+		Attributes:      s.attributes,
+		Mask:            s.mask,
+		Frame:           s.frame,
+		Frozen:          s.frozen,
+		PendingTransfer: s.pendingTransfer,
+		TransferFlags:   s.transferFlags,
+		BorderTiles:     s.border.tiles,
+		BorderMap:       s.border.tileMap,
+		BorderPalettes:  s.border.palettes,
+	})
+}
+
+func (s *SuperGameBoy) LoadState(dec *gob.Decoder) error {
+	var state sgbState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	if len(state.Frame) != len(s.frame) || len(state.Frozen) != len(s.frozen) {
+		return errors.New(fmt.Sprintf("Saved Super Game Boy frame has %d pixels but expected %d", len(state.Frame), len(s.frame)))
+	}
+
+	s.enabled = state.Enabled
+	s.receiving = state.Receiving
+	s.readyForBit = state.ReadyForBit
+	s.bitCount = state.BitCount
+	s.packet = state.Packet
+	s.command = state.Command
+	s.lines = state.Lines
+	s.players = state.Players
+	if s.players == 0 {
+		s.players = 1
+	}
+	s.player = state.Player
+	s.palettes = state.Palettes
+	s.attributes = state.Attributes
+	s.mask = state.Mask
+	copy(s.frame, state.Frame)
+	copy(s.frozen, state.Frozen)
+	s.pendingTransfer = state.PendingTransfer
+	s.transferFlags = state.TransferFlags
+	s.border.tiles = state.BorderTiles
+	s.border.tileMap = state.BorderMap
+	s.border.palettes = state.BorderPalettes
+	return nil
+}
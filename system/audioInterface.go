@@ -0,0 +1,8 @@
+package system
+
+// Samples are signed 16bit PCM interleaved as left then right
+type Audio interface {
+	SetSampleRate(rate int)
+	SampleRate() int
+	ReadSamples(buffer []int16) int
+}
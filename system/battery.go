@@ -0,0 +1,83 @@
+package system
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// How often battery RAM is checked for changes and written to disk
+const batteryFlushFrames = framesPerSecond
+
+// The .sav file lives next to the ROM with the same name
+func batteryPathForROM(rom string) string {
+	return strings.TrimSuffix(rom, filepath.Ext(rom)) + ".sav"
+}
+
+func (s *System) loadBatteryRAM() {
+	s.batteryPath = batteryPathForROM(s.rom)
+	s.batteryRAM = s.cartridge.ExportBatteryRAM()
+	s.framesSinceBatteryFlush = 0
+
+	// Test ROMs are run straight from the test suite so don't leave saves there
+	if s.batteryRAM == nil || s.isTestROM {
+		return
+	}
+
+	data, err := os.ReadFile(s.batteryPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.log.Debug(fmt.Sprintf("Failed to read battery RAM from %s: %s", s.batteryPath, err.Error()))
+		}
+		return
+	}
+
+	if err := s.cartridge.ImportBatteryRAM(data); err != nil {
+		s.log.Debug(fmt.Sprintf("Failed to load battery RAM from %s: %s", s.batteryPath, err.Error()))
+		return
+	}
+
+	s.batteryRAM = s.cartridge.ExportBatteryRAM()
+}
+
+// Writes the cartridge battery RAM to the .sav file next to the ROM if it has
+// changed. This happens automatically every second and when the game is reset
+// or changed but should be called before exiting. Test ROMs are never saved.
+func (s *System) SaveBatteryRAM() error {
+	// A movie being played back shouldn't replace the player's save
+	if s.cartridge == nil || s.isTestROM || s.MoviePlaying() {
+		return nil
+	}
+
+	data := s.cartridge.ExportBatteryRAM()
+	if data == nil || bytes.Equal(data, s.batteryRAM) {
+		return nil
+	}
+
+	if err := os.WriteFile(s.batteryPath, data, 0644); err != nil {
+		return errors.Join(errors.New(fmt.Sprintf("Failed to save battery RAM to %s", s.batteryPath)), err)
+	}
+
+	s.batteryRAM = data
+	return nil
+}
+
+func (s *System) flushBatteryRAMPeriodically() {
+	// Frames run again for rewinding aren't new progress to save
+	if s.replaying {
+		return
+	}
+
+	s.framesSinceBatteryFlush++
+	if s.framesSinceBatteryFlush < batteryFlushFrames {
+		return
+	}
+	s.framesSinceBatteryFlush = 0
+
+	if err := s.SaveBatteryRAM(); err != nil {
+		s.log.Debug(err.Error())
+	}
+}
@@ -0,0 +1,25 @@
+package system
+
+import (
+	"os"
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/display"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestROMBatteryRAMNotSaved(t *testing.T) {
+	// Has battery backed RAM
+	rom := "../rom/test/mem_timing-2/mem_timing.gb"
+	save := batteryPathForROM(rom)
+	require.NoFileExists(t, save)
+	t.Cleanup(func() { os.Remove(save) })
+
+	s := CreateSystem(testBIOS, rom, DMG, display.PixelFIFO, false)
+	s.LoadTestROM(rom)
+	require.NoError(t, runFrames(s, 60))
+	require.NoError(t, s.SaveBatteryRAM())
+
+	assert.NoFileExists(t, save)
+}
@@ -31,9 +31,34 @@ type CartridgeHeader struct {
 	// Not part of the official header info
 	NumRAMBanks uint8
 	NumROMBanks uint8
+
+	oldLicenseeCode uint8
+}
+
+// The Super Game Boy only accepts commands from games that set the SGB flag
+// and use the new licensee code
+func (c *CartridgeHeader) SupportsSGB() bool {
+	return c.SBG == 0x03 && c.oldLicenseeCode == 0x33
+}
+
+// MMM01 multicarts boot into a menu in the last 32KiB of the ROM so the header
+// at the start belongs to one of the games and not the cartridge
+func mmm01MenuHeader(rom *[]byte) *[]byte {
+	if len(*rom) < 0x10000 {
+		return rom
+	}
+
+	menu := (*rom)[len(*rom)-0x8000:]
+	switch menu[0x0147] {
+	case 0x0B, 0x0C, 0x0D:
+		return &menu
+	default:
+		return rom
+	}
 }
 
 func readHeader(rom *[]byte) *CartridgeHeader {
+	rom = mmm01MenuHeader(rom)
 
 	var title string
 	for x := 0x0134; x <= 0x0143; x++ {
@@ -100,6 +125,7 @@ func readHeader(rom *[]byte) *CartridgeHeader {
 		GlobalChecksum:      globalChecksum,
 		NumRAMBanks:         uint8(ramSizeBytes / 8),
 		NumROMBanks:         uint8(romSizeBytes) / 8,
+		oldLicenseeCode:     oldLicenseeCode,
 	}
 }
 
@@ -246,7 +272,7 @@ func newLicenseeCodeName(code uint16) string {
 	case 0xA4:
 		return "Konami (Yu-Gi-Oh!)"
 	default:
-		return fmt.Sprintf("Unknown code: 0x02X", code)
+		return fmt.Sprintf("Unknown code: 0x%02X", code)
 	}
 }
 
@@ -309,7 +335,7 @@ func cartridgeTypeName(code uint8) string {
 	case 0xFF:
 		return "HuC1+RAM+BATTERY"
 	default:
-		return fmt.Sprintf("Unknown code: 0xX", code)
+		return fmt.Sprintf("Unknown code: 0x%02X", code)
 	}
 }
 
@@ -336,7 +362,7 @@ func romSizeInfo(code uint8) (string, uint32) {
 	case 0x52, 0x53, 0x54:
 		panic(fmt.Sprintf("Unsupported rom size: 0x%X", code))
 	default:
-		return fmt.Sprintf("Unknown code: 0xX", code), 0
+		return fmt.Sprintf("Unknown code: 0x%02X", code), 0
 	}
 }
 
@@ -355,7 +381,7 @@ func ramSizeInfo(code uint8) (string, uint32) {
 	case 0x05:
 		return "64 KiB - 8 banks of 8 KiB each", 64 * memory.M_1Kb
 	default:
-		return fmt.Sprintf("Unknown code: 0xX", code), 0
+		return fmt.Sprintf("Unknown code: 0x%02X", code), 0
 	}
 }
 
@@ -366,7 +392,7 @@ func destinationCodeName(code uint8) string {
 	case 0x01:
 		return "Overseas only"
 	default:
-		return fmt.Sprintf("Unknown code 0xX", code)
+		return fmt.Sprintf("Unknown code 0x%02X", code)
 	}
 }
 
@@ -668,6 +694,6 @@ func oldLicenseeCodeName(code uint8) string {
 	case 0xFF:
 		return "LJN"
 	default:
-		return fmt.Sprintf("Unknown code 0xX", code)
+		return fmt.Sprintf("Unknown code 0x%02X", code)
 	}
 }
@@ -28,4 +28,22 @@ type Debug interface {
 	AddMemoryRecorder(address uint16)
 	DeleteMemoryRecorder(address uint16)
 	MemoryRecordValues(address uint16) []debugger.MemoryRecordEntry
+
+	StepBack() error
+	ReverseContinue() (breakpoint bool, err error)
+}
+
+// Running backwards needs the whole system to load snapshots and run forward
+// again so isn't part of the debugger
+type systemDebug struct {
+	debugger.Debugger
+	system *System
+}
+
+func (d *systemDebug) StepBack() error {
+	return d.system.stepBack()
+}
+
+func (d *systemDebug) ReverseContinue() (breakpoint bool, err error) {
+	return d.system.reverseContinue()
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/f1gopher/gbpixellib/cpu"
 	"github.com/f1gopher/gbpixellib/display"
 	"github.com/f1gopher/gbpixellib/memory"
+	"github.com/f1gopher/gbpixellib/timer"
 )
 
 const executionHistorySize = 200
@@ -53,6 +54,9 @@ type CPUState struct {
 
 	SPMem uint16
 
+	// Waiting for a button press after STOP
+	Stopped bool
+
 	Cycle uint
 }
 
@@ -70,6 +74,15 @@ type InterruptState struct {
 	JoypadEnabled   bool
 }
 
+type TimerState struct {
+	// DIV is the top 8 bits of the system counter
+	SystemCounter uint16
+	DIV           uint8
+	TIMA          uint8
+	TMA           uint8
+	TAC           uint8
+}
+
 type LCDControlState struct {
 	LCDEnabled        bool
 	WindowTileMapArea uint16
@@ -121,6 +134,7 @@ type Dump interface {
 	GetInterruptState() *InterruptState
 	GetGPUState() *LCDControlState
 	GetCartridgeState() *CartridgeState
+	GetTimerState() *TimerState
 	GetDebugState() *DebugState
 
 	DumpTileset() image.Image
@@ -129,11 +143,11 @@ type Dump interface {
 	DumpSecondTileMap() *[1024]byte
 	DumpWindowTileMap() *[1024]byte
 	DumpBackgroundTileMap() *[1024]byte
-	DumpCode(area memory.Area, bank uint8) (instructions []string, previousPCIndex int, currentPCIndex int)
+	DumpCode(area memory.Area, bank uint16) (instructions []string, previousPCIndex int, currentPCIndex int)
 	DumpCallstack() []string
 	GetExecutionHistory() []ExecutionInfo
 	GetInterruptHistory() []InterruptInfo
-	DumpMemory(area memory.Area, bank uint8) (data []uint8, startAddress uint16)
+	DumpMemory(area memory.Area, bank uint16) (data []uint8, startAddress uint16)
 	DumpMemoryValue(address uint16) uint8
 }
 
@@ -142,6 +156,7 @@ type dumpInterface struct {
 	cpu              *cpu.Cpu
 	memory           *memory.Bus
 	screen           *display.Screen
+	timer            *timer.Timer
 	cartridge        memory.Cartridge
 	executionHistory []ExecutionInfo
 	interruptHistory []InterruptInfo
@@ -195,22 +210,23 @@ func (d *dumpInterface) getCPUStateOnly() *CPUState {
 	spMem = spMem | uint16(lsb)
 
 	return &CPUState{
-		A:     d.regs.Get8(cpu.A),
-		F:     d.regs.Get8(cpu.F),
-		B:     d.regs.Get8(cpu.B),
-		C:     d.regs.Get8(cpu.C),
-		D:     d.regs.Get8(cpu.D),
-		E:     d.regs.Get8(cpu.E),
-		H:     d.regs.Get8(cpu.H),
-		L:     d.regs.Get8(cpu.L),
-		SP:    d.regs.Get16(cpu.SP),
-		PC:    d.regs.Get16(cpu.PC),
-		ZFlag: d.regs.GetFlag(cpu.ZFlag),
-		NFlag: d.regs.GetFlag(cpu.NFlag),
-		HFlag: d.regs.GetFlag(cpu.HFlag),
-		CFlag: d.regs.GetFlag(cpu.CFlag),
-		SPMem: spMem,
-		Cycle: d.mCycle,
+		A:       d.regs.Get8(cpu.A),
+		F:       d.regs.Get8(cpu.F),
+		B:       d.regs.Get8(cpu.B),
+		C:       d.regs.Get8(cpu.C),
+		D:       d.regs.Get8(cpu.D),
+		E:       d.regs.Get8(cpu.E),
+		H:       d.regs.Get8(cpu.H),
+		L:       d.regs.Get8(cpu.L),
+		SP:      d.regs.Get16(cpu.SP),
+		PC:      d.regs.Get16(cpu.PC),
+		ZFlag:   d.regs.GetFlag(cpu.ZFlag),
+		NFlag:   d.regs.GetFlag(cpu.NFlag),
+		HFlag:   d.regs.GetFlag(cpu.HFlag),
+		CFlag:   d.regs.GetFlag(cpu.CFlag),
+		SPMem:   spMem,
+		Stopped: d.regs.GetSTOP(),
+		Cycle:   d.mCycle,
 	}
 }
 
@@ -292,6 +308,16 @@ func (d *dumpInterface) GetCartridgeState() *CartridgeState {
 	}
 }
 
+func (d *dumpInterface) GetTimerState() *TimerState {
+	return &TimerState{
+		SystemCounter: d.timer.SystemCounter(),
+		DIV:           d.memory.ReadByte(memory.DividerRegister),
+		TIMA:          d.memory.ReadByte(0xFF05),
+		TMA:           d.memory.ReadByte(0xFF06),
+		TAC:           d.memory.ReadByte(0xFF07),
+	}
+}
+
 func (d *dumpInterface) GetDebugState() *DebugState {
 	op, isCB := d.cpu.GetNextOpcode()
 	return &DebugState{
@@ -335,7 +361,7 @@ func (d *dumpInterface) DumpBackgroundTileMap() *[1024]byte {
 	return d.screen.DumpBackgroundTileMap()
 }
 
-func (d *dumpInterface) DumpCode(area memory.Area, bank uint8) (instructions []string, previousPCIndex int, currentPCIndex int) {
+func (d *dumpInterface) DumpCode(area memory.Area, bank uint16) (instructions []string, previousPCIndex int, currentPCIndex int) {
 	bios, _ := d.memory.DumpCode(area, bank)
 	current := d.cpu.GetOpcodePC()
 	previous := d.cpu.GetPrevOpcodePC()
@@ -400,7 +426,7 @@ func (d *dumpInterface) DumpCallstack() []string {
 	return result
 }
 
-func (d *dumpInterface) DumpMemory(area memory.Area, bank uint8) (data []uint8, startAddress uint16) {
+func (d *dumpInterface) DumpMemory(area memory.Area, bank uint16) (data []uint8, startAddress uint16) {
 	return d.memory.DumpCode(area, bank)
 }
 
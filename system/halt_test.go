@@ -0,0 +1,43 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHALTWakesWithoutIME(t *testing.T) {
+	s := createProgramSystem(t, []uint8{
+		0xF3,       // DI
+		0x3E, 0x04, // LD A,0x04
+		0xE0, 0xFF, // LDH (IE),A
+		0xAF,       // XOR A
+		0xE0, 0x0F, // LDH (IF),A
+		0x3E, 0x05, // LD A,0x05
+		0xE0, 0x07, // LDH (TAC),A
+		0x76,       // HALT
+		0x04,       // INC B
+		0x18, 0xFE, // JR -2
+	}, map[uint16][]uint8{
+		// Timer interrupt
+		0x0050: {0x0E, 0x99}, // LD C,0x99
+	})
+
+	// Halts before the timer overflows
+	for !s.regs.GetHALT() {
+		_, _, err := s.SingleInstruction()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, uint8(0x00), s.regs.Get8(cpu.B))
+
+	require.NoError(t, runFrames(s, 1))
+
+	// Carries on after HALT without calling the interrupt
+	assert.False(t, s.regs.GetHALT())
+	assert.False(t, s.regs.GetIME())
+	assert.Equal(t, uint8(0x01), s.regs.Get8(cpu.B))
+	assert.NotEqual(t, uint8(0x99), s.regs.Get8(cpu.C))
+	assert.Equal(t, uint16(0x010E), s.cpu.GetOpcodePC())
+}
@@ -0,0 +1,14 @@
+package system
+
+// The console being emulated
+type Hardware int
+
+const (
+	DMG Hardware = iota
+	CGB
+	SGB
+)
+
+func (h Hardware) String() string {
+	return [...]string{"Game Boy", "Game Boy Color", "Super Game Boy"}[h]
+}
@@ -0,0 +1,83 @@
+package system
+
+import (
+	"sort"
+
+	"github.com/f1gopher/gbpixellib/input"
+)
+
+type InputTiming int
+
+const (
+	// At the start of the frame, counting frames since reset
+	AtFrame InputTiming = iota
+	// Before the CPU runs the M-cycle, counting since reset
+	AtMCycle
+)
+
+func (t InputTiming) String() string {
+	return [...]string{
+		"Frame",
+		"M-cycle"}[t]
+}
+
+// Sets the buttons held down from a point onwards
+type InputEvent struct {
+	Timing  InputTiming
+	When    uint
+	Buttons input.Button
+}
+
+type inputSchedule struct {
+	frameEvents  []InputEvent
+	mCycleEvents []InputEvent
+}
+
+// Replaces any scheduled input, including the input of a movie being played
+// back. Events for points that have already passed are applied straight away.
+func (s *System) ScheduleInput(events []InputEvent) {
+	s.schedule = inputSchedule{}
+
+	for _, event := range events {
+		if event.Timing == AtFrame {
+			s.schedule.frameEvents = append(s.schedule.frameEvents, event)
+		} else {
+			s.schedule.mCycleEvents = append(s.schedule.mCycleEvents, event)
+		}
+	}
+
+	// Stable so events for the same point are applied in the order given
+	sort.SliceStable(s.schedule.frameEvents, func(a, b int) bool {
+		return s.schedule.frameEvents[a].When < s.schedule.frameEvents[b].When
+	})
+	sort.SliceStable(s.schedule.mCycleEvents, func(a, b int) bool {
+		return s.schedule.mCycleEvents[a].When < s.schedule.mCycleEvents[b].When
+	})
+}
+
+func (s *System) ClearInputSchedule() {
+	s.schedule = inputSchedule{}
+}
+
+func (s *System) hasScheduledInput() bool {
+	return len(s.schedule.frameEvents) > 0 || len(s.schedule.mCycleEvents) > 0
+}
+
+// The number of frames run since reset
+func (s *System) Frame() uint {
+	return s.frame
+}
+
+func (s *System) applyFrameInput() {
+	for len(s.schedule.frameEvents) > 0 && s.schedule.frameEvents[0].When <= s.frame {
+		s.controller.SetButtons(s.schedule.frameEvents[0].Buttons)
+		s.schedule.frameEvents = s.schedule.frameEvents[1:]
+	}
+}
+
+func (s *System) applyMCycleInput() {
+	for len(s.schedule.mCycleEvents) > 0 && s.schedule.mCycleEvents[0].When <= s.dump.mCycle {
+		s.controller.SetButtons(s.schedule.mCycleEvents[0].Buttons)
+		s.schedule.mCycleEvents = s.schedule.mCycleEvents[1:]
+	}
+}
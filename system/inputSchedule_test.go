@@ -0,0 +1,68 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scheduledMCycle = 50000
+
+func TestScheduledInputWhenSingleStepping(t *testing.T) {
+	s := createTestSystem(false)
+	s.ScheduleInput([]InputEvent{
+		{Timing: AtFrame, When: 1, Buttons: input.ButtonA},
+		{Timing: AtMCycle, When: scheduledMCycle, Buttons: input.ButtonStart | input.ButtonUp},
+		{Timing: AtFrame, When: 3, Buttons: input.NoButtons},
+	})
+
+	for s.Frame() < 1 {
+		assert.Equal(t, input.NoButtons, s.Joypad().Buttons())
+		_, _, err := s.SingleInstruction()
+		require.NoError(t, err)
+	}
+
+	// Applied once the next frame starts running
+	_, _, err := s.SingleInstruction()
+	require.NoError(t, err)
+	assert.Equal(t, input.ButtonA, s.Joypad().Buttons())
+
+	for s.dump.mCycle < scheduledMCycle {
+		assert.Equal(t, input.ButtonA, s.Joypad().Buttons())
+		_, _, err := s.SingleInstruction()
+		require.NoError(t, err)
+	}
+
+	_, _, err = s.SingleInstruction()
+	require.NoError(t, err)
+	assert.Equal(t, input.ButtonStart|input.ButtonUp, s.Joypad().Buttons())
+	assert.Equal(t, uint(2), s.Frame())
+
+	for s.Frame() < 3 {
+		_, _, err := s.SingleInstruction()
+		require.NoError(t, err)
+	}
+	_, _, err = s.SingleInstruction()
+	require.NoError(t, err)
+	assert.Equal(t, input.NoButtons, s.Joypad().Buttons())
+}
+
+func TestScheduledInputWithFrames(t *testing.T) {
+	s := createTestSystem(false)
+	s.ScheduleInput([]InputEvent{
+		{Timing: AtFrame, When: 2, Buttons: input.ButtonB},
+		{Timing: AtFrame, When: 4, Buttons: input.ButtonSelect},
+	})
+
+	require.NoError(t, runFrames(s, 2))
+	assert.Equal(t, input.NoButtons, s.Joypad().Buttons())
+
+	require.NoError(t, runFrames(s, 1))
+	assert.Equal(t, input.ButtonB, s.Joypad().Buttons())
+
+	require.NoError(t, runFrames(s, 2))
+	assert.Equal(t, input.ButtonSelect, s.Joypad().Buttons())
+	assert.Equal(t, uint(5), s.Frame())
+}
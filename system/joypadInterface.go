@@ -1,5 +1,7 @@
 package system
 
+import "github.com/f1gopher/gbpixellib/input"
+
 type Joypad interface {
 	PressStart()
 	ReleaseStart()
@@ -17,4 +19,9 @@ type Joypad interface {
 	ReleaseLeft()
 	PressRight()
 	ReleaseRight()
+
+	SetButtons(buttons input.Button)
+	Buttons() input.Button
+
+	SetOppositeDirections(allowed bool)
 }
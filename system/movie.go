@@ -0,0 +1,265 @@
+package system
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+
+	"github.com/f1gopher/gbpixellib/display"
+	"github.com/f1gopher/gbpixellib/input"
+)
+
+const movieMagic = "GBPIXELLIB-MOVIE"
+const movieVersion = 1
+
+// How often the screen is hashed to check playback matches the recording
+const movieHashFrames = framesPerSecond
+
+type movieInput struct {
+	MCycle  uint
+	Buttons input.Button
+}
+
+type movieHash struct {
+	Frame uint
+	Hash  uint64
+}
+
+type movieFile struct {
+	Magic          string
+	Version        int
+	Title          string
+	GlobalChecksum uint16
+	Hardware       Hardware
+	TestROM        bool
+	BIOS           []uint8
+
+	// Starts from power-on with this battery RAM when there is no state
+	BatteryRAM []uint8
+	State      []uint8
+
+	Inputs []movieInput
+	Hashes []movieHash
+}
+
+type movie struct {
+	recording   bool
+	file        movieFile
+	lastButtons input.Button
+	nextHash    int
+}
+
+// Starts recording every joypad change from power-on, which resets the game,
+// or from a save state of the current point. The cartridge clock isn't
+// recorded so games using it may not play back the same.
+func (s *System) RecordMovie(fromPowerOn bool) error {
+	s.movie = nil
+
+	file := movieFile{
+		Magic:    movieMagic,
+		Version:  movieVersion,
+		Hardware: s.hardware,
+		TestROM:  s.isTestROM,
+		BIOS:     s.biosData,
+		Inputs:   make([]movieInput, 0),
+		Hashes:   make([]movieHash, 0),
+	}
+
+	if fromPowerOn {
+		s.Reset()
+		file.BatteryRAM = s.cartridge.ExportBatteryRAM()
+	} else {
+		var state bytes.Buffer
+		if err := s.SaveState(&state); err != nil {
+			return errors.Join(errors.New("Failed to save the movie start state"), err)
+		}
+		file.State = state.Bytes()
+	}
+
+	file.Title = s.cartridgeHeader.Title
+	file.GlobalChecksum = s.cartridgeHeader.GlobalChecksum
+
+	s.movie = &movie{
+		recording:   true,
+		file:        file,
+		lastButtons: s.controller.Buttons(),
+	}
+	s.movie.file.Inputs = append(s.movie.file.Inputs, movieInput{
+		MCycle:  s.dump.mCycle,
+		Buttons: s.movie.lastButtons,
+	})
+
+	return nil
+}
+
+// Stops recording and writes the movie
+func (s *System) SaveMovie(w io.Writer) error {
+	if s.movie == nil || !s.movie.recording {
+		return errors.New("Not recording a movie")
+	}
+
+	file := s.movie.file
+	s.movie = nil
+
+	if err := gob.NewEncoder(w).Encode(file); err != nil {
+		return errors.Join(errors.New("Failed to save movie"), err)
+	}
+
+	return nil
+}
+
+// Resets the game and plays the movie back. SingleFrame returns an error if
+// the screen stops matching the recording. Battery RAM isn't saved while
+// playing. The movie's input is played back with the input schedule so any
+// input already scheduled must be cleared first.
+func (s *System) PlayMovie(r io.Reader) error {
+	var file movieFile
+	if err := gob.NewDecoder(r).Decode(&file); err != nil {
+		return errors.Join(errors.New("Failed to read movie"), err)
+	}
+
+	if file.Magic != movieMagic {
+		return errors.New("Not a movie")
+	}
+
+	if file.Version > movieVersion {
+		return errors.New(fmt.Sprintf("Movie version %d is newer than supported version %d", file.Version, movieVersion))
+	}
+
+	if file.GlobalChecksum != s.cartridgeHeader.GlobalChecksum {
+		return errors.New(fmt.Sprintf("Movie is for a different game: %s", file.Title))
+	}
+
+	if file.Hardware != s.hardware {
+		return errors.New(fmt.Sprintf("Movie was recorded on a %s but the system is a %s", file.Hardware, s.hardware))
+	}
+
+	// Test ROMs start without the boot ROM
+	if file.TestROM && !s.isTestROM {
+		return errors.New("Movie was recorded with a test ROM")
+	}
+	if !file.TestROM && s.isTestROM {
+		return errors.New("Movie wasn't recorded with a test ROM")
+	}
+
+	if s.hasScheduledInput() {
+		return errors.New("Can't play a movie while input is scheduled")
+	}
+
+	s.movie = nil
+
+	// Use the same boot ROM as the recording
+	s.biosOverride = file.BIOS
+	s.Reset()
+
+	if len(file.State) > 0 {
+		if err := s.LoadState(bytes.NewReader(file.State)); err != nil {
+			s.biosOverride = nil
+			return errors.Join(errors.New("Failed to load the movie start state"), err)
+		}
+	} else if file.BatteryRAM != nil {
+		if err := s.cartridge.ImportBatteryRAM(file.BatteryRAM); err != nil {
+			s.biosOverride = nil
+			return errors.Join(errors.New("Failed to load the movie battery RAM"), err)
+		}
+	}
+
+	events := make([]InputEvent, len(file.Inputs))
+	for x, recorded := range file.Inputs {
+		events[x] = InputEvent{
+			Timing:  AtMCycle,
+			When:    recorded.MCycle,
+			Buttons: recorded.Buttons,
+		}
+	}
+	s.ScheduleInput(events)
+
+	s.movie = &movie{
+		recording: false,
+		file:      file,
+	}
+
+	return nil
+}
+
+// Stops recording or playing without saving
+func (s *System) StopMovie() {
+	if s.movie != nil && !s.movie.recording {
+		s.ClearInputSchedule()
+	}
+
+	s.movie = nil
+	s.biosOverride = nil
+}
+
+func (s *System) MovieRecording() bool {
+	return s.movie != nil && s.movie.recording
+}
+
+func (s *System) MoviePlaying() bool {
+	return s.movie != nil && !s.movie.recording
+}
+
+func (s *System) recordMovieInput() {
+	if s.movie == nil || !s.movie.recording {
+		return
+	}
+
+	buttons := s.controller.Buttons()
+	if buttons == s.movie.lastButtons {
+		return
+	}
+
+	s.movie.lastButtons = buttons
+	s.movie.file.Inputs = append(s.movie.file.Inputs, movieInput{
+		MCycle:  s.dump.mCycle,
+		Buttons: buttons,
+	})
+}
+
+// Called after each frame to record or check the screen hash
+func (s *System) checkMovieFrame() error {
+	if s.movie == nil || s.frame%movieHashFrames != 0 {
+		return nil
+	}
+
+	hash := s.screenHash()
+
+	if s.movie.recording {
+		s.movie.file.Hashes = append(s.movie.file.Hashes, movieHash{Frame: s.frame, Hash: hash})
+		return nil
+	}
+
+	hashes := s.movie.file.Hashes
+	for s.movie.nextHash < len(hashes) && hashes[s.movie.nextHash].Frame < s.frame {
+		s.movie.nextHash++
+	}
+
+	if s.movie.nextHash >= len(hashes) || hashes[s.movie.nextHash].Frame != s.frame {
+		return nil
+	}
+
+	if hashes[s.movie.nextHash].Hash != hash {
+		s.StopMovie()
+		return errors.New(fmt.Sprintf("Movie playback desynced at frame %d", s.frame))
+	}
+
+	s.movie.nextHash++
+	return nil
+}
+
+func (s *System) screenHash() uint64 {
+	hash := fnv.New64a()
+	pixel := make([]uint8, 4)
+
+	s.screen.Render(func(x int, y int, color display.ScreenColor) {
+		binary.LittleEndian.PutUint32(pixel, uint32(color))
+		hash.Write(pixel)
+	})
+
+	return hash.Sum64()
+}
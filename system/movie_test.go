@@ -0,0 +1,131 @@
+package system
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Long enough for the screen to be hashed
+const movieTestFrames = movieHashFrames + 10
+
+func scheduleMovieTestInput(s *System) {
+	start := s.Frame()
+	s.ScheduleInput([]InputEvent{
+		{Timing: AtFrame, When: start + 5, Buttons: input.ButtonA},
+		{Timing: AtFrame, When: start + 40, Buttons: input.ButtonStart | input.ButtonLeft},
+		{Timing: AtFrame, When: start + 65, Buttons: input.ButtonB},
+	})
+}
+
+func recordTestMovie(t *testing.T, s *System, fromPowerOn bool) (*bytes.Buffer, systemSnapshot) {
+	require.NoError(t, s.RecordMovie(fromPowerOn))
+	scheduleMovieTestInput(s)
+	require.NoError(t, runFrames(s, movieTestFrames))
+	end := takeSystemSnapshot(s)
+
+	var movie bytes.Buffer
+	require.NoError(t, s.SaveMovie(&movie))
+	assert.False(t, s.MovieRecording())
+	return &movie, end
+}
+
+func TestMovieRoundTripFromPowerOn(t *testing.T) {
+	recorder := createTestSystem(false)
+	require.NoError(t, runFrames(recorder, 10))
+	movie, end := recordTestMovie(t, recorder, true)
+
+	player := createTestSystem(false)
+	require.NoError(t, player.PlayMovie(movie))
+	assert.True(t, player.MoviePlaying())
+	require.NoError(t, runFrames(player, movieTestFrames))
+
+	assert.Equal(t, end, takeSystemSnapshot(player))
+	assert.Equal(t, input.ButtonB, player.Joypad().Buttons())
+}
+
+func TestMovieRoundTripFromSaveState(t *testing.T) {
+	recorder := createTestSystem(false)
+	recorder.ScheduleInput([]InputEvent{{Timing: AtFrame, When: 3, Buttons: input.ButtonDown}})
+	require.NoError(t, runFrames(recorder, 30))
+	movie, end := recordTestMovie(t, recorder, false)
+
+	player := createTestSystem(false)
+	require.NoError(t, player.PlayMovie(movie))
+	assert.Equal(t, uint(30), player.Frame())
+	assert.Equal(t, input.ButtonDown, player.Joypad().Buttons())
+	require.NoError(t, runFrames(player, movieTestFrames))
+
+	assert.Equal(t, end, takeSystemSnapshot(player))
+}
+
+func editTestMovie(t *testing.T, movie *bytes.Buffer, change func(file *movieFile)) (*bytes.Buffer, movieFile) {
+	var file movieFile
+	require.NoError(t, gob.NewDecoder(bytes.NewReader(movie.Bytes())).Decode(&file))
+	change(&file)
+
+	var edited bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&edited).Encode(file))
+	return &edited, file
+}
+
+func TestMovieDesync(t *testing.T) {
+	recorder := createTestSystem(false)
+	movie, _ := recordTestMovie(t, recorder, true)
+
+	corrupted, file := editTestMovie(t, movie, func(file *movieFile) {
+		require.NotEmpty(t, file.Hashes)
+		file.Hashes[0].Hash ^= 0x01
+	})
+
+	player := createTestSystem(false)
+	require.NoError(t, player.PlayMovie(corrupted))
+
+	var err error
+	for player.Frame() < movieTestFrames && err == nil {
+		_, _, err = player.SingleFrame()
+	}
+
+	assert.EqualError(t, err, "Movie playback desynced at frame 60")
+	assert.Equal(t, file.Hashes[0].Frame, player.Frame())
+	assert.False(t, player.MoviePlaying())
+}
+
+func TestPlayMovieRejectsScheduledInput(t *testing.T) {
+	recorder := createTestSystem(false)
+	movie, _ := recordTestMovie(t, recorder, true)
+
+	player := createTestSystem(false)
+	player.ScheduleInput([]InputEvent{{Timing: AtFrame, When: 100, Buttons: input.ButtonA}})
+
+	data := movie.Bytes()
+	assert.EqualError(t, player.PlayMovie(bytes.NewReader(data)), "Can't play a movie while input is scheduled")
+	assert.False(t, player.MoviePlaying())
+
+	player.ClearInputSchedule()
+	assert.NoError(t, player.PlayMovie(bytes.NewReader(data)))
+}
+
+func TestPlayMovieRejectsDifferentHardware(t *testing.T) {
+	recorder := createTestSystem(false)
+	movie, _ := recordTestMovie(t, recorder, true)
+
+	cgb, _ := editTestMovie(t, movie, func(file *movieFile) { file.Hardware = CGB })
+	player := createTestSystem(false)
+	assert.EqualError(t, player.PlayMovie(cgb), "Movie was recorded on a Game Boy Color but the system is a Game Boy")
+	assert.False(t, player.MoviePlaying())
+}
+
+func TestPlayMovieRejectsDifferentTestROM(t *testing.T) {
+	recorder := createTestSystem(false)
+	movie, _ := recordTestMovie(t, recorder, true)
+
+	notTestROM, _ := editTestMovie(t, movie, func(file *movieFile) { file.TestROM = false })
+	player := createTestSystem(false)
+	assert.EqualError(t, player.PlayMovie(notTestROM), "Movie wasn't recorded with a test ROM")
+	assert.False(t, player.MoviePlaying())
+}
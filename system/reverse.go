@@ -0,0 +1,132 @@
+package system
+
+import (
+	"errors"
+	"slices"
+)
+
+// Goes back to the point before the last instruction ran. Needs rewinding
+// turned on with SetRewind.
+func (s *System) stepBack() error {
+	before := s.dump.mCycle
+
+	// Without an earlier instruction it ends up at the oldest snapshot
+	if _, err := s.reverseUntil(func() bool { return true }); err != nil {
+		return err
+	}
+
+	if s.dump.mCycle == before {
+		return errors.New("Can't step back any further")
+	}
+
+	return nil
+}
+
+// Runs backwards until the last instruction that hit a breakpoint, stopping
+// straight after it as if running forward. Stops at the oldest point kept for
+// rewinding if no breakpoint is hit.
+func (s *System) reverseContinue() (breakpoint bool, err error) {
+	return s.reverseUntil(s.debugger.HasHitBreakpoint)
+}
+
+// Finds the last time the CPU was between instructions before now and found
+// returned true, by running forward again from each snapshot in turn going
+// back from the newest. The joypad input after that point is kept so running
+// forward from there does the same as before.
+func (s *System) reverseUntil(found func() bool) (bool, error) {
+	if s.rewind.everyFrames == 0 {
+		return false, errors.New("Rewind is not enabled")
+	}
+
+	if s.movie != nil {
+		return false, errors.New("Can't run backwards while a movie is recording or playing")
+	}
+
+	// The other end would see the transfers happen again
+	if s.serial.IsConnected() {
+		return false, errors.New("Can't run backwards while the link cable is connected")
+	}
+
+	if len(s.rewind.snapshots) == 0 {
+		return false, errors.New("No rewind snapshots are kept")
+	}
+
+	// Running forward again drops and records these again as it goes so keep
+	// the full list
+	inputs := slices.Clone(s.rewind.inputs)
+	if s.rewind.replay != nil {
+		inputs = append(inputs, s.rewind.replay...)
+	}
+
+	s.startReplay()
+	defer s.endReplay()
+
+	end := s.dump.mCycle
+	includeEnd := false
+
+	for index := len(s.rewind.snapshots) - 1; index >= 0; index-- {
+		start := s.rewind.snapshots[index].mCycle
+		if start >= end {
+			continue
+		}
+
+		at, hit, err := s.searchFromSnapshot(index, inputs, end, includeEnd, found)
+		if err != nil {
+			return false, err
+		}
+
+		if hit {
+			return true, s.runFromSnapshot(index, inputs, at)
+		}
+
+		// The earlier snapshot runs up to this one so it checks the
+		// instruction that finished exactly where this one starts
+		end = start
+		includeEnd = true
+	}
+
+	return false, s.runFromSnapshot(0, inputs, s.rewind.snapshots[0].mCycle)
+}
+
+// Runs from a snapshot up to end and returns the M-cycle of the last time
+// found was true after an instruction. Only the final run to the point found
+// records memory values.
+func (s *System) searchFromSnapshot(index int, inputs []movieInput, end uint, includeEnd bool, found func() bool) (at uint, hit bool, err error) {
+	if err := s.restoreRewindSnapshot(index, slices.Clone(inputs)); err != nil {
+		return 0, false, err
+	}
+
+	s.debugger.SetMemoryRecordersPaused(true)
+	defer s.debugger.SetMemoryRecordersPaused(false)
+
+	for s.dump.mCycle < end {
+		if _, err := s.stepInstruction(); err != nil {
+			return 0, false, err
+		}
+
+		if s.dump.mCycle > end || (s.dump.mCycle == end && !includeEnd) {
+			break
+		}
+
+		if found() {
+			at = s.dump.mCycle
+			hit = true
+		}
+	}
+
+	return at, hit, nil
+}
+
+func (s *System) runFromSnapshot(index int, inputs []movieInput, mCycle uint) error {
+	if err := s.restoreRewindSnapshot(index, slices.Clone(inputs)); err != nil {
+		return err
+	}
+
+	for s.dump.mCycle < mCycle {
+		if _, err := s.stepInstruction(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
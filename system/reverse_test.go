@@ -0,0 +1,133 @@
+package system
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/debugger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const serialData = 0xFF01
+
+func createReverseTestSystem(t *testing.T) *System {
+	s := createTestSystem(true)
+	require.NoError(t, s.SetRewind(1, 64*1024*1024))
+	return s
+}
+
+func TestStepBack(t *testing.T) {
+	s := createReverseTestSystem(t)
+	require.NoError(t, runFrames(s, 3))
+
+	// The last instructions of a frame and the first of the next
+	states := make([]systemSnapshot, 0)
+	for len(states) < 60 {
+		if s.Frame() == 3 && len(states) == 30 {
+			states = states[1:]
+		}
+		states = append(states, takeSystemSnapshot(s))
+		_, _, err := s.SingleInstruction()
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint(3), states[29].Frame)
+	require.Equal(t, uint(4), states[30].Frame)
+
+	for x := len(states) - 1; x >= 0; x-- {
+		require.NoError(t, s.Debug().StepBack())
+		require.Equal(t, states[x], takeSystemSnapshot(s), "Stepping back to instruction %d", x)
+	}
+}
+
+func TestStepBackPastOldestSnapshot(t *testing.T) {
+	s := createReverseTestSystem(t)
+
+	assert.EqualError(t, s.Debug().StepBack(), "Can't step back any further")
+}
+
+type serialWrite struct {
+	state   systemSnapshot
+	history []debugger.MemoryRecordEntry
+}
+
+// Runs until the next write to the serial data register
+func runToSerialWrite(t *testing.T, s *System) serialWrite {
+	for {
+		breakpoint, _, err := s.SingleFrame()
+		require.NoError(t, err)
+		if breakpoint {
+			return serialWrite{
+				state:   takeSystemSnapshot(s),
+				history: slices.Clone(s.Debug().MemoryRecordValues(serialData)),
+			}
+		}
+	}
+}
+
+func TestReverseContinue(t *testing.T) {
+	s := createReverseTestSystem(t)
+	_, err := s.Debug().AddMemoryBP(serialData, debugger.GreaterThanOrEqual, 0x00, 1)
+	require.NoError(t, err)
+	s.Debug().AddMemoryRecorder(serialData)
+
+	writes := make([]serialWrite, 0)
+	for len(writes) < 3 {
+		writes = append(writes, runToSerialWrite(t, s))
+	}
+
+	// Recording values while searching and running again doesn't add to the
+	// history
+	for x := len(writes) - 2; x >= 0; x-- {
+		breakpoint, err := s.Debug().ReverseContinue()
+		require.NoError(t, err)
+		assert.True(t, breakpoint)
+		assert.Equal(t, writes[x].state, takeSystemSnapshot(s))
+		assert.Equal(t, writes[x].history, s.Debug().MemoryRecordValues(serialData))
+	}
+
+	// Nothing written before the first
+	breakpoint, err := s.Debug().ReverseContinue()
+	require.NoError(t, err)
+	assert.False(t, breakpoint)
+	assert.Equal(t, uint(0), s.Frame())
+	assert.Len(t, s.Debug().MemoryRecordValues(serialData), 1)
+}
+
+func TestReverseRefusedWhileLinked(t *testing.T) {
+	s := createReverseTestSystem(t)
+	other := createTestSystem(false)
+	require.NoError(t, runFrames(s, 2))
+
+	ConnectLink(s, other)
+	assert.EqualError(t, s.Debug().StepBack(), "Can't run backwards while the link cable is connected")
+
+	s.DisconnectLink()
+	assert.NoError(t, s.Debug().StepBack())
+}
+
+func TestRunningBackwardsDoesNotOutputAudio(t *testing.T) {
+	s := createReverseTestSystem(t)
+	other := createTestSystem(false)
+
+	buffer := make([]int16, s.apu.SampleRate()*2)
+	for _, sys := range []*System{s, other} {
+		require.NoError(t, runFrames(sys, 5))
+		sys.apu.ReadSamples(buffer)
+		require.NoError(t, runFrames(sys, 3))
+	}
+
+	// Searches all the way back to the oldest snapshot
+	require.NoError(t, s.Debug().StepBack())
+	breakpoint, err := s.Debug().ReverseContinue()
+	require.NoError(t, err)
+	require.False(t, breakpoint)
+	require.Equal(t, uint(0), s.Frame())
+
+	// Still has the samples waiting from before and none from the searches
+	count := other.apu.ReadSamples(buffer)
+	require.NotZero(t, count)
+	waiting := slices.Clone(buffer[:count])
+	count = s.apu.ReadSamples(buffer)
+	assert.Equal(t, waiting, buffer[:count])
+}
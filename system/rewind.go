@@ -0,0 +1,219 @@
+package system
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/f1gopher/gbpixellib/input"
+)
+
+type rewindSnapshot struct {
+	frame  uint
+	mCycle uint
+	data   []byte
+}
+
+// Save states taken every few frames, oldest first, dropping the oldest when
+// they no longer fit in the memory budget. The joypad changes since the
+// oldest are kept so frames between snapshots can be run again.
+type rewindBuffer struct {
+	everyFrames  uint
+	memoryBudget int
+	used         int
+	snapshots    []rewindSnapshot
+	inputs       []movieInput
+	lastButtons  input.Button
+
+	// Joypad changes still to apply when running forward from a snapshot
+	replay []movieInput
+}
+
+// Keeps a compressed snapshot every few frames so the game can be rewound.
+// The oldest are dropped to keep the total size within the memory budget in
+// bytes. Zero frames turns rewinding off.
+func (s *System) SetRewind(everyFrames uint, memoryBudget int) error {
+	s.rewind = rewindBuffer{
+		everyFrames:  everyFrames,
+		memoryBudget: memoryBudget,
+	}
+
+	return s.restartRewind()
+}
+
+// The most frames Rewind can go back
+func (s *System) RewindFrames() uint {
+	if len(s.rewind.snapshots) == 0 {
+		return 0
+	}
+
+	return s.frame - s.rewind.snapshots[0].frame
+}
+
+// Goes back to an earlier frame by loading the nearest snapshot before it and
+// running forward with the same joypad input. Scheduled input that was
+// applied after that frame isn't applied again.
+func (s *System) Rewind(frames int) error {
+	if s.rewind.everyFrames == 0 {
+		return errors.New("Rewind is not enabled")
+	}
+
+	if s.movie != nil {
+		return errors.New("Can't rewind while a movie is recording or playing")
+	}
+
+	// The other end would see the transfers happen again
+	if s.serial.IsConnected() {
+		return errors.New("Can't rewind while the link cable is connected")
+	}
+
+	if len(s.rewind.snapshots) == 0 || frames < 0 || uint(frames) > s.RewindFrames() {
+		return errors.New(fmt.Sprintf("Can't rewind %d frames, only %d are kept", frames, s.RewindFrames()))
+	}
+
+	target := s.frame - uint(frames)
+
+	index := len(s.rewind.snapshots) - 1
+	for s.rewind.snapshots[index].frame > target {
+		index--
+	}
+
+	s.startReplay()
+	defer s.endReplay()
+
+	if err := s.restoreRewindSnapshot(index, slices.Clone(s.rewind.inputs)); err != nil {
+		return err
+	}
+
+	// The player takes over from the frame so their later input is dropped
+	defer func() { s.rewind.replay = nil }()
+
+	for s.frame < target {
+		if _, _, err := s.SingleFrame(); err != nil {
+			return errors.Join(errors.New(fmt.Sprintf("Failed to rewind to frame %d", target)), err)
+		}
+	}
+
+	return nil
+}
+
+// Loads a snapshot and drops everything kept after it, which is recorded
+// again as it runs forward with the joypad changes from inputs
+func (s *System) restoreRewindSnapshot(index int, inputs []movieInput) error {
+	snapshot := s.rewind.snapshots[index]
+
+	for _, dropped := range s.rewind.snapshots[index+1:] {
+		s.rewind.used -= len(dropped.data)
+	}
+	s.rewind.snapshots = s.rewind.snapshots[:index+1]
+
+	kept := 0
+	for kept < len(inputs) && inputs[kept].MCycle < snapshot.mCycle {
+		kept++
+	}
+	s.rewind.inputs = slices.Clone(inputs[:kept])
+	s.rewind.replay = inputs[kept:]
+
+	// The values are recorded again when it runs forward
+	s.debugger.TrimMemoryRecorders(snapshot.mCycle)
+
+	return s.loadRewindSnapshot(snapshot)
+}
+
+// Throws away the snapshots and starts again from the current point
+func (s *System) restartRewind() error {
+	s.rewind.snapshots = nil
+	s.rewind.inputs = nil
+	s.rewind.replay = nil
+	s.rewind.used = 0
+
+	if s.rewind.everyFrames == 0 {
+		return nil
+	}
+
+	s.rewind.lastButtons = s.controller.Buttons()
+	return s.takeRewindSnapshot()
+}
+
+func (s *System) takeRewindSnapshot() error {
+	var data bytes.Buffer
+	compressor, err := flate.NewWriter(&data, flate.BestSpeed)
+	if err != nil {
+		return err
+	}
+
+	if err := s.SaveState(compressor); err != nil {
+		return errors.Join(errors.New("Failed to take rewind snapshot"), err)
+	}
+
+	if err := compressor.Close(); err != nil {
+		return errors.Join(errors.New("Failed to take rewind snapshot"), err)
+	}
+
+	s.rewind.snapshots = append(s.rewind.snapshots, rewindSnapshot{
+		frame:  s.frame,
+		mCycle: s.dump.mCycle,
+		data:   data.Bytes(),
+	})
+	s.rewind.used += data.Len()
+
+	// Always keep the newest even if it is bigger than the budget
+	for s.rewind.used > s.rewind.memoryBudget && len(s.rewind.snapshots) > 1 {
+		s.rewind.used -= len(s.rewind.snapshots[0].data)
+		s.rewind.snapshots = s.rewind.snapshots[1:]
+	}
+
+	oldest := s.rewind.snapshots[0].mCycle
+	for len(s.rewind.inputs) > 0 && s.rewind.inputs[0].MCycle < oldest {
+		s.rewind.inputs = s.rewind.inputs[1:]
+	}
+
+	return nil
+}
+
+func (s *System) loadRewindSnapshot(snapshot rewindSnapshot) error {
+	decompressor := flate.NewReader(bytes.NewReader(snapshot.data))
+	defer decompressor.Close()
+
+	if err := s.loadState(decompressor); err != nil {
+		return errors.Join(errors.New(fmt.Sprintf("Failed to load rewind snapshot for frame %d", snapshot.frame)), err)
+	}
+
+	s.rewind.lastButtons = s.controller.Buttons()
+	return nil
+}
+
+// Called after each frame
+func (s *System) updateRewind() error {
+	if s.rewind.everyFrames == 0 || s.frame%s.rewind.everyFrames != 0 {
+		return nil
+	}
+
+	return s.takeRewindSnapshot()
+}
+
+func (s *System) applyReplayInput() {
+	for len(s.rewind.replay) > 0 && s.rewind.replay[0].MCycle <= s.dump.mCycle {
+		s.controller.SetButtons(s.rewind.replay[0].Buttons)
+		s.rewind.replay = s.rewind.replay[1:]
+	}
+}
+
+func (s *System) recordRewindInput() {
+	if s.rewind.everyFrames == 0 {
+		return
+	}
+
+	buttons := s.controller.Buttons()
+	if buttons == s.rewind.lastButtons {
+		return
+	}
+
+	s.rewind.lastButtons = buttons
+	s.rewind.inputs = append(s.rewind.inputs, movieInput{
+		MCycle:  s.dump.mCycle,
+		Buttons: buttons,
+	})
+}
@@ -0,0 +1,118 @@
+package system
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/input"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewindReplaysTheSame(t *testing.T) {
+	s := createTestSystem(false)
+	require.NoError(t, s.SetRewind(30, 16*1024*1024))
+
+	// Input between snapshots has to be replayed when rewinding
+	later := []InputEvent{
+		{Timing: AtFrame, When: 150, Buttons: input.ButtonStart},
+		{Timing: AtFrame, When: 250, Buttons: input.NoButtons},
+	}
+	s.ScheduleInput(append([]InputEvent{
+		{Timing: AtFrame, When: 20, Buttons: input.ButtonA},
+		{Timing: AtMCycle, When: 95 * cyclesPerFrame / cyclesPerMCycle, Buttons: input.ButtonRight},
+	}, later...))
+
+	require.NoError(t, runFrames(s, 100))
+	atFrame100 := takeSystemSnapshot(s)
+	require.NoError(t, runFrames(s, 200))
+	atFrame300 := takeSystemSnapshot(s)
+
+	require.NoError(t, s.Rewind(200))
+	assert.Equal(t, atFrame100, takeSystemSnapshot(s))
+	assert.Equal(t, input.ButtonRight, s.Joypad().Buttons())
+
+	s.ScheduleInput(later)
+	require.NoError(t, runFrames(s, 200))
+	assert.Equal(t, atFrame300, takeSystemSnapshot(s))
+}
+
+func TestRewindBudgetKeepsNewestSnapshot(t *testing.T) {
+	s := createTestSystem(false)
+
+	// Smaller than any snapshot
+	require.NoError(t, s.SetRewind(1, 1))
+	require.NoError(t, runFrames(s, 5))
+
+	require.Len(t, s.rewind.snapshots, 1)
+	assert.Equal(t, uint(5), s.rewind.snapshots[0].frame)
+	assert.Equal(t, len(s.rewind.snapshots[0].data), s.rewind.used)
+	assert.Equal(t, uint(0), s.RewindFrames())
+	assert.Error(t, s.Rewind(1))
+}
+
+func TestRewindReportsRumbleWhenFinished(t *testing.T) {
+	// Copies 0xC000 to the rumble motor
+	s := createProgramSystem(t, []uint8{
+		0xFA, 0x00, 0xC0, // LD A,(0xC000)
+		0xEA, 0x00, 0x40, // LD (0x4000),A
+		0x18, 0xF8, // JR -8
+	}, map[uint16][]uint8{
+		// MBC5+RUMBLE
+		0x0147: {0x1C},
+	})
+	require.NoError(t, s.SetRewind(30, 16*1024*1024))
+
+	var calls []bool
+	s.SetRumbleCallback(func(on bool) { calls = append(calls, on) })
+
+	require.NoError(t, runFrames(s, 60))
+	s.bus.WriteByte(0xC000, 0x08)
+	require.NoError(t, runFrames(s, 10))
+	assert.Equal(t, []bool{true}, calls)
+
+	// The snapshot has the motor off which is only reported once the rewind
+	// has finished
+	require.NoError(t, s.Rewind(20))
+	assert.Equal(t, []bool{true, false}, calls)
+
+	require.NoError(t, runFrames(s, 5))
+	assert.Equal(t, []bool{true, false}, calls)
+}
+
+func TestRewindRefusedWhileLinked(t *testing.T) {
+	s := createTestSystem(false)
+	require.NoError(t, s.SetRewind(30, 16*1024*1024))
+	other := createTestSystem(false)
+	require.NoError(t, runFrames(s, 60))
+
+	ConnectLink(s, other)
+	assert.EqualError(t, s.Rewind(10), "Can't rewind while the link cable is connected")
+	assert.Equal(t, uint(60), s.Frame())
+
+	s.DisconnectLink()
+	assert.NoError(t, s.Rewind(10))
+	assert.Equal(t, uint(50), s.Frame())
+}
+
+func TestRewindDoesNotOutputAudio(t *testing.T) {
+	rewound := createTestSystem(false)
+	require.NoError(t, rewound.SetRewind(30, 16*1024*1024))
+	other := createTestSystem(false)
+
+	buffer := make([]int16, rewound.apu.SampleRate()*2)
+	for _, s := range []*System{rewound, other} {
+		require.NoError(t, runFrames(s, 60))
+		s.apu.ReadSamples(buffer)
+		require.NoError(t, runFrames(s, 5))
+	}
+
+	require.NoError(t, rewound.Rewind(20))
+
+	// Still has the samples waiting from before and none from the replay
+	count := other.apu.ReadSamples(buffer)
+	require.NotZero(t, count)
+	waiting := slices.Clone(buffer[:count])
+	count = rewound.apu.ReadSamples(buffer)
+	assert.Equal(t, waiting, buffer[:count])
+}
@@ -0,0 +1,168 @@
+package system
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const saveStateMagic = "GBPIXELLIB-STATE"
+
+// Increase whenever the state saved by any component changes. Versions older
+// than saveStateOldestVersion are rejected, so add the conversion to LoadState
+// to keep reading them.
+//
+// 2 - Game Boy Color, Super Game Boy, RTC and camera state, the pixel FIFO and
+// window line, the timer system counter and interrupt dispatch
+const saveStateVersion = 2
+const saveStateOldestVersion = 2
+
+type saveStateHeader struct {
+	Magic          string
+	Version        int
+	Hardware       Hardware
+	Title          string
+	GlobalChecksum uint16
+	MCycle         uint
+	Frame          uint
+	FrameCycles    uint
+}
+
+type stateComponent interface {
+	SaveState(enc *gob.Encoder) error
+	LoadState(dec *gob.Decoder) error
+}
+
+// Saves everything needed to carry on running from this point. States can
+// only be loaded for the same ROM.
+func (s *System) SaveState(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+
+	err := enc.Encode(saveStateHeader{
+		Magic:          saveStateMagic,
+		Version:        saveStateVersion,
+		Hardware:       s.hardware,
+		Title:          s.cartridgeHeader.Title,
+		GlobalChecksum: s.cartridgeHeader.GlobalChecksum,
+		MCycle:         s.dump.mCycle,
+		Frame:          s.frame,
+		FrameCycles:    s.frameCycles,
+	})
+	if err != nil {
+		return errors.Join(errors.New("Failed to save state header"), err)
+	}
+
+	s.displayLock.Lock()
+	defer s.displayLock.Unlock()
+
+	for _, component := range s.stateComponents() {
+		if err := component.SaveState(enc); err != nil {
+			return errors.Join(errors.New("Failed to save state"), err)
+		}
+	}
+
+	return nil
+}
+
+// Loading a state starts rewinding again from this point
+func (s *System) LoadState(r io.Reader) error {
+	if err := s.loadState(r); err != nil {
+		return err
+	}
+
+	return s.restartRewind()
+}
+
+func (s *System) loadState(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+
+	var header saveStateHeader
+	if err := dec.Decode(&header); err != nil {
+		return errors.Join(errors.New("Failed to read save state header"), err)
+	}
+
+	if header.Magic != saveStateMagic {
+		return errors.New("Not a save state")
+	}
+
+	if header.Version > saveStateVersion {
+		return errors.New(fmt.Sprintf("Save state version %d is newer than supported version %d", header.Version, saveStateVersion))
+	}
+
+	if header.Version < saveStateOldestVersion {
+		return errors.New(fmt.Sprintf("Save state version %d is older than the oldest supported version %d", header.Version, saveStateOldestVersion))
+	}
+
+	// The components saved depend on the hardware
+	if header.Hardware != s.hardware {
+		return errors.New(fmt.Sprintf("Save state is for a %s but the system is a %s", header.Hardware, s.hardware))
+	}
+
+	if header.GlobalChecksum != s.cartridgeHeader.GlobalChecksum {
+		return errors.New(fmt.Sprintf("Save state is for a different game: %s", header.Title))
+	}
+
+	// Keep the current state so a bad save state doesn't leave the system
+	// half loaded
+	var backup bytes.Buffer
+	if err := s.SaveState(&backup); err != nil {
+		return err
+	}
+
+	if err := s.loadComponents(dec); err != nil {
+		backupDec := gob.NewDecoder(&backup)
+		var backupHeader saveStateHeader
+		if restoreErr := backupDec.Decode(&backupHeader); restoreErr != nil {
+			panic(errors.Join(errors.New("Failed to read backup state header after failed load"), restoreErr))
+		}
+		if restoreErr := s.loadComponents(backupDec); restoreErr != nil {
+			panic(errors.Join(errors.New("Failed to restore state after failed load"), restoreErr))
+		}
+
+		return errors.Join(errors.New("Failed to load state"), err)
+	}
+
+	s.dump.reset()
+	s.dump.mCycle = header.MCycle
+	s.frame = header.Frame
+	s.frameCycles = header.FrameCycles
+	s.debugger.StartCycle(s.dump.mCycle, s.cpu.GetOpcodePC())
+
+	return nil
+}
+
+func (s *System) loadComponents(dec *gob.Decoder) error {
+	s.displayLock.Lock()
+	defer s.displayLock.Unlock()
+
+	for _, component := range s.stateComponents() {
+		if err := component.LoadState(dec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The order the components are written to a save state. Adding a component
+// needs saveStateVersion increasing.
+func (s *System) stateComponents() []stateComponent {
+	components := []stateComponent{
+		s.cpu,
+		s.bus,
+		s.screen,
+		s.timer,
+		s.controller,
+		s.apu,
+		s.serial,
+	}
+
+	// Only exists when running as a Super Game Boy, which the header checks
+	if s.sgb != nil {
+		components = append(components, s.sgb)
+	}
+
+	return components
+}
@@ -0,0 +1,99 @@
+package system
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	original := createTestSystem(false)
+	require.NoError(t, runFrames(original, 30))
+
+	var state bytes.Buffer
+	require.NoError(t, original.SaveState(&state))
+
+	loaded := createTestSystem(false)
+	require.NoError(t, loaded.LoadState(&state))
+	assert.Equal(t, takeSystemSnapshot(original), takeSystemSnapshot(loaded))
+
+	// Both carry on the same
+	require.NoError(t, runFrames(original, 10))
+	require.NoError(t, runFrames(loaded, 10))
+	assert.Equal(t, takeSystemSnapshot(original), takeSystemSnapshot(loaded))
+}
+
+func encodeStateHeader(t *testing.T, header saveStateHeader) *bytes.Buffer {
+	var state bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&state).Encode(header))
+	return &state
+}
+
+func TestLoadStateRejectsNewerVersion(t *testing.T) {
+	s := createTestSystem(false)
+	require.NoError(t, runFrames(s, 5))
+	before := takeSystemSnapshot(s)
+
+	state := encodeStateHeader(t, saveStateHeader{
+		Magic:          saveStateMagic,
+		Version:        saveStateVersion + 1,
+		GlobalChecksum: s.cartridgeHeader.GlobalChecksum,
+	})
+
+	err := s.LoadState(state)
+	assert.ErrorContains(t, err, "newer than supported")
+	assert.Equal(t, before, takeSystemSnapshot(s))
+}
+
+func TestLoadStateRejectsDifferentGame(t *testing.T) {
+	s := createTestSystem(false)
+	require.NoError(t, runFrames(s, 5))
+	before := takeSystemSnapshot(s)
+
+	state := encodeStateHeader(t, saveStateHeader{
+		Magic:          saveStateMagic,
+		Version:        saveStateVersion,
+		Title:          "OTHER GAME",
+		GlobalChecksum: s.cartridgeHeader.GlobalChecksum + 1,
+	})
+
+	err := s.LoadState(state)
+	assert.ErrorContains(t, err, "different game: OTHER GAME")
+	assert.Equal(t, before, takeSystemSnapshot(s))
+}
+
+func TestLoadStateRejectsOlderVersion(t *testing.T) {
+	s := createTestSystem(false)
+	require.NoError(t, runFrames(s, 5))
+	before := takeSystemSnapshot(s)
+
+	state := encodeStateHeader(t, saveStateHeader{
+		Magic:          saveStateMagic,
+		Version:        saveStateOldestVersion - 1,
+		GlobalChecksum: s.cartridgeHeader.GlobalChecksum,
+	})
+
+	err := s.LoadState(state)
+	assert.ErrorContains(t, err, "older than the oldest supported")
+	assert.Equal(t, before, takeSystemSnapshot(s))
+}
+
+func TestLoadStateRejectsDifferentHardware(t *testing.T) {
+	s := createTestSystem(false)
+	require.NoError(t, runFrames(s, 5))
+	before := takeSystemSnapshot(s)
+
+	state := encodeStateHeader(t, saveStateHeader{
+		Magic:          saveStateMagic,
+		Version:        saveStateVersion,
+		Hardware:       CGB,
+		GlobalChecksum: s.cartridgeHeader.GlobalChecksum,
+	})
+
+	err := s.LoadState(state)
+	assert.ErrorContains(t, err, "for a Game Boy Color but the system is a Game Boy")
+	assert.Equal(t, before, takeSystemSnapshot(s))
+}
@@ -0,0 +1,72 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Counts VBlank interrupts in DE and LCD STAT interrupts in BC with the STAT
+// interrupt sources enabled
+func createSTATSystem(t *testing.T, stat uint8, lyc uint8) *System {
+	return createProgramSystem(t, []uint8{
+		0xF3,             // DI
+		0x01, 0x00, 0x00, // LD BC,0x0000
+		0x11, 0x00, 0x00, // LD DE,0x0000
+		0x3E, lyc, // LD A,lyc
+		0xE0, 0x45, // LDH (LYC),A
+		0x3E, stat, // LD A,stat
+		0xE0, 0x41, // LDH (STAT),A
+		0x3E, 0x03, // LD A,0x03
+		0xE0, 0xFF, // LDH (IE),A
+		0xAF,       // XOR A
+		0xE0, 0x0F, // LDH (IF),A
+		0x3E, 0x91, // LD A,0x91
+		0xE0, 0x40, // LDH (LCDC),A
+		0xFB,       // EI
+		0x76,       // HALT
+		0x18, 0xFD, // JR -3
+	}, map[uint16][]uint8{
+		// VBlank interrupt
+		0x0040: {0x13, 0xD9}, // INC DE, RETI
+		// LCD STAT interrupt
+		0x0048: {0x03, 0xD9}, // INC BC, RETI
+	})
+}
+
+// The STAT interrupts between the first VBlank and the one frames later
+func countSTATInterrupts(t *testing.T, s *System, frames uint16) uint16 {
+	require.NoError(t, runFrames(s, 1))
+	start := s.regs.Get16(cpu.DE)
+
+	runUntilVBlanks := func(count uint16) {
+		for s.regs.Get16(cpu.DE) < start+count {
+			_, _, err := s.SingleInstruction()
+			require.NoError(t, err)
+		}
+	}
+
+	runUntilVBlanks(1)
+	before := s.regs.Get16(cpu.BC)
+	runUntilVBlanks(1 + frames)
+	return s.regs.Get16(cpu.BC) - before
+}
+
+func TestSTATLYCInterruptOncePerFrame(t *testing.T) {
+	s := createSTATSystem(t, 0x40, 0x10)
+	assert.Equal(t, uint16(10), countSTATInterrupts(t, s, 10))
+}
+
+func TestSTATHBlankInterruptOncePerLine(t *testing.T) {
+	s := createSTATSystem(t, 0x08, 0x10)
+	assert.Equal(t, uint16(10*144), countSTATInterrupts(t, s, 10))
+}
+
+func TestSTATSourcesShareOneLine(t *testing.T) {
+	// The line stays high from the HBlank before LY 16 to the end of the
+	// HBlank on LY 16 so neither of them requests another interrupt
+	s := createSTATSystem(t, 0x48, 0x10)
+	assert.Equal(t, uint16(10*143), countSTATInterrupts(t, s, 10))
+}
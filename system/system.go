@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
+	"github.com/f1gopher/gbpixellib/apu"
 	"github.com/f1gopher/gbpixellib/cpu"
 	"github.com/f1gopher/gbpixellib/debugger"
 	"github.com/f1gopher/gbpixellib/display"
@@ -13,6 +15,8 @@ import (
 	"github.com/f1gopher/gbpixellib/interupt"
 	"github.com/f1gopher/gbpixellib/log"
 	"github.com/f1gopher/gbpixellib/memory"
+	"github.com/f1gopher/gbpixellib/serial"
+	"github.com/f1gopher/gbpixellib/sgb"
 	"github.com/f1gopher/gbpixellib/timer"
 )
 
@@ -20,15 +24,17 @@ const cyclesPerSecond = 4194304
 const framesPerSecond = 60
 const cyclesPerFrame = cyclesPerSecond / framesPerSecond
 const cyclesPerMCycle = 4
-const mCyclesPerFrame = cyclesPerFrame / cyclesPerMCycle
-const dmaMCycles = 160
 const handleInterruptMCycles = 5
 
+// The CPU is stopped for a while when changing speed
+const speedSwitchMCycles = 2050
+
 const haltExecutionName = "**HALTED**"
+const stopExecutionName = "**STOPPED**"
 const interruptExecutionName = "**INTERRUPT** - "
 
 type CartridgeState struct {
-	CurrentROMBank uint8
+	CurrentROMBank uint16
 	CurrentRAMBank uint8
 }
 
@@ -36,6 +42,7 @@ type System struct {
 	bios      string
 	rom       string
 	isTestROM bool
+	hardware  Hardware
 
 	debugger        debugger.Debugger
 	log             *log.Log
@@ -47,41 +54,97 @@ type System struct {
 	interuptHandler *interupt.Handler
 	controller      *input.Input
 	timer           *timer.Timer
+	apu             *apu.Apu
+	serial          *serial.Serial
+	sgb             *sgb.SuperGameBoy
 	cartridgeHeader *CartridgeHeader
 	cartridge       memory.Cartridge
+	clocked         memory.ClockedCartridge
+	rtc             memory.RealTimeClock
+	clock           memory.ClockSource
+	rumbleCallback  func(on bool)
+	linkTimeout     time.Duration
+	camera          memory.CameraCartridge
+	imageSource     memory.ImageSource
+
+	batteryPath             string
+	batteryRAM              []uint8
+	framesSinceBatteryFlush int
 
 	currentDisplay string
 	displayLock    sync.Mutex
 
 	dump dumpInterface
+
+	// Display cycles into the current frame
+	frameCycles uint
+	frame       uint
+	schedule    inputSchedule
+
+	// The boot ROM that was loaded and the one to use instead when playing
+	// a movie
+	biosData     []byte
+	biosOverride []byte
+	movie        *movie
+
+	rewind rewindBuffer
+
+	// Set while running again from a rewind snapshot so the host doesn't see
+	// things like the rumble motor happen a second time. Anything that still
+	// differs is reported when it finishes.
+	replaying bool
+
+	// The motor as the cartridge has it and as the host was last told
+	rumbleOn       bool
+	rumbleReported bool
 }
 
-func CreateSystem(bios string, rom string, useDebugger bool) *System {
+func CreateSystem(bios string, rom string, hardware Hardware, renderer display.Renderer, useDebugger bool) *System {
 	l := log.CreateLog("./log.txt")
 	debugger, registers, memory, memoryBus := debugger.CreateDebugger(l, useDebugger)
 	system := System{
 		debugger:  debugger,
 		log:       l,
 		isTestROM: false,
+		hardware:  hardware,
 		bios:      bios,
 		rom:       rom,
 		memory:    memory,
 		bus:       memoryBus,
 		regs:      registers,
+
+		linkTimeout: serial.DefaultTransferTimeout,
 	}
 	system.cpu = cpu.CreateCPU(l, system.regs, system.memory)
 	system.interuptHandler = interupt.CreateHandler(system.memory, system.regs)
-	system.screen = display.CreateScreen(system.memory, system.interuptHandler)
+	system.screen = display.CreateScreen(system.bus, system.interuptHandler, renderer)
 	system.controller = input.CreateInput(system.bus, system.interuptHandler)
 	memoryBus.SetIO(system.controller, system.interuptHandler)
-	system.timer = timer.CreateTimer(system.memory, system.interuptHandler)
+	system.timer = timer.CreateTimer(system.interuptHandler)
 	memoryBus.SetTimer(system.timer)
+	system.apu = apu.CreateAPU()
+	memoryBus.SetAudio(system.apu)
+	system.serial = serial.CreateSerial(system.interuptHandler)
+	memoryBus.SetSerial(system.serial)
+
+	if hardware == CGB {
+		memoryBus.EnableColor()
+		system.screen.SetColorVideo(memoryBus)
+		system.screen.SetHBlankListener(memoryBus)
+	}
+
+	if hardware == SGB {
+		system.sgb = sgb.CreateSuperGameBoy()
+		memoryBus.SetJoypadListener(system.sgb)
+		system.screen.SetFrameListener(system.sgb)
+	}
 
 	system.dump = dumpInterface{
 		regs:             system.regs,
 		cpu:              system.cpu,
 		memory:           system.bus,
 		screen:           system.screen,
+		timer:            system.timer,
 		cartridge:        system.cartridge,
 		executionHistory: make([]ExecutionInfo, 0),
 	}
@@ -118,11 +181,16 @@ func (s *System) Start() {
 	var err error
 
 	if !s.isTestROM {
-		bios, err = os.ReadFile(s.bios)
-		if err != nil {
-			panic(errors.Join(err, errors.New("Failed to load bios")))
+		if s.biosOverride != nil {
+			bios = s.biosOverride
+		} else {
+			bios, err = os.ReadFile(s.bios)
+			if err != nil {
+				panic(errors.Join(err, errors.New("Failed to load bios")))
+			}
 		}
 	}
+	s.biosData = bios
 
 	rom, err = os.ReadFile(s.rom)
 	if err != nil {
@@ -131,7 +199,7 @@ func (s *System) Start() {
 	s.cartridgeHeader = readHeader(&rom)
 
 	if !s.IsCartridgeSupported() {
-		s.log.Debug(fmt.Sprintf("Unsupported cartridge type: %s", s.cartridgeHeader.CartridgeType))
+		s.log.Debug(fmt.Sprintf("Unsupported cartridge type: 0x%02X", s.cartridgeHeader.CartridgeType))
 	}
 
 	s.cartridge = memory.CreateCartridge(
@@ -141,16 +209,115 @@ func (s *System) Start() {
 		&rom)
 	s.dump.cartridge = s.cartridge
 
+	s.clocked = nil
+	if clocked, ok := s.cartridge.(memory.ClockedCartridge); ok {
+		s.clocked = clocked
+	}
+
+	s.rtc = nil
+	if rtc, ok := s.cartridge.(memory.RealTimeClock); ok {
+		s.rtc = rtc
+		if s.clock != nil {
+			s.rtc.SetClockSource(s.clock)
+		}
+	}
+
+	s.camera = nil
+	if camera, ok := s.cartridge.(memory.CameraCartridge); ok {
+		s.camera = camera
+		s.camera.SetImageSource(s.imageSource)
+	}
+
+	if rumble, ok := s.cartridge.(memory.RumbleCartridge); ok {
+		rumble.SetRumbleCallback(s.rumble)
+	}
+	// A new cartridge starts with the motor off
+	s.rumble(false)
+
+	s.loadBatteryRAM()
+
 	s.bus.Load(&bios, s.cartridge)
+
+	if s.sgb != nil {
+		s.sgb.SetEnabled(s.cartridgeHeader.SupportsSGB())
+	}
+
+	// Without the boot ROM to pick the mode use the header
+	if s.isTestROM {
+		s.bus.SetCompatibilityMode(s.cartridgeHeader.CGBFlag&0x80 == 0)
+	}
+}
+
+func (s *System) Hardware() Hardware {
+	return s.hardware
+}
+
+// When disabled the CPU can use video memory in any PPU mode and during OAM
+// DMA. Games rely on the restrictions so only turn them off for debugging.
+func (s *System) SetAccessRestrictions(enabled bool) {
+	s.bus.SetAccessRestrictions(enabled)
+}
+
+// In double speed the CPU runs twice as fast as the display and sound
+func (s *System) displayCyclesPerMCycle() uint {
+	if s.bus.DoubleSpeed() {
+		return cyclesPerMCycle / 2
+	}
+
+	return cyclesPerMCycle
+}
+
+// STOP switches speed on the Game Boy Color if KEY1 was written to prepare
+// for it. Otherwise the CPU stops with DIV reset and the LCD blank until a
+// button is pressed. Returns the M-cycles the CPU is stopped for by a speed
+// switch.
+func (s *System) handleSTOP() uint {
+	if !s.regs.GetSTOP() {
+		return 0
+	}
+
+	if s.bus.SwitchSpeedIfPrepared() {
+		s.regs.SetSTOP(false)
+		return speedSwitchMCycles
+	}
+
+	s.bus.WriteByte(memory.DividerRegister, 0x00)
+	s.screen.Blank()
+	return 0
+}
+
+// Nothing runs while stopped. Wakes up when a joypad line goes low.
+func (s *System) stoppedCycle() string {
+	if s.controller.SelectedLines() != 0x0F {
+		s.regs.SetSTOP(false)
+		return "**UNSTOP**"
+	}
+
+	return stopExecutionName
 }
 
 func (s *System) Reset() {
+	// Save the game before the cartridge is replaced
+	if err := s.SaveBatteryRAM(); err != nil {
+		s.log.Debug(err.Error())
+	}
+
 	s.memory.Reset()
 	s.cpu.Reset()
 	s.interuptHandler.Reset()
 	s.screen.Reset()
+	s.apu.Reset()
+	s.serial.Reset()
+	if s.sgb != nil {
+		s.sgb.Reset()
+	}
 	if s.isTestROM {
 		s.cpu.InitForTestROM()
+		// Games check A to see which console they are running on. Getting it
+		// wrong makes them try to switch speed with STOP.
+		if s.hardware != CGB {
+			s.regs.Set8(cpu.A, 0x01)
+		}
 		// Disable bios because we load as a ROM
 		s.memory.WriteByte(0xFF50, 0xFF)
 	} else {
@@ -159,172 +326,208 @@ func (s *System) Reset() {
 	s.controller.Reset()
 	s.timer.Reset()
 	s.dump.reset()
+	s.frame = 0
+	s.frameCycles = 0
 	s.debugger.StartCycle(0, 0)
 	s.Start()
+
+	if err := s.restartRewind(); err != nil {
+		s.log.Debug(err.Error())
+	}
 }
 
 func (s *System) Render(callback func(x int, y int, color display.ScreenColor)) {
 	s.displayLock.Lock()
 	defer s.displayLock.Unlock()
 
+	// The Super Game Boy adds a border around the screen
+	if s.sgb != nil {
+		s.sgb.Render(callback)
+		return
+	}
+
 	s.screen.Render(callback)
 }
 
+// Runs until the frame is finished or a breakpoint is hit. After a breakpoint
+// the next call carries on with the same frame rather than starting a new one,
+// so frames always end at the same point however the system was stopped.
+// SingleInstruction finishes frames too so Frame() and input scheduled for a
+// frame work when single stepping.
 func (s *System) SingleFrame() (breakpoint bool, mCyclesCompleted uint, err error) {
 
-	prevCompleted := false
-	didDMA := false
-	mCyclesCompleted = 0
-	wasHalted := false
-	var x uint
-	info := ExecutionInfo{}
+	// Count display cycles because in double speed there are twice as many
+	// M-cycles in a frame
+	for s.frameCycles < cyclesPerFrame {
+		mCycles, completed, err := s.step()
+		mCyclesCompleted += mCycles
 
-	s.debugger.StartCycle(s.dump.mCycle, info.ProgramCounter)
+		if err != nil {
+			return false, mCyclesCompleted, err
+		}
 
-	for x = 0; x < mCyclesPerFrame; {
-		mCyclesCompleted = 1
-		info.Opcode = 0
-		info.StartMCycle = s.dump.mCycle
-		info.ProgramCounter = s.cpu.GetOpcodePC()
-		info.StartCPU = *s.dump.getCPUStateOnly()
+		if completed && s.debugger.HasHitBreakpoint() {
+			return true, mCyclesCompleted, nil
+		}
+	}
 
-		if prevCompleted {
-			s.debugger.StartCycle(s.dump.mCycle, info.ProgramCounter)
+	return false, mCyclesCompleted, s.endFrame()
+}
 
-			if didDMA = s.memory.ExecuteDMAIfPending(); didDMA {
-				info.Name = "**DMA**"
-				mCyclesCompleted += dmaMCycles
+func (s *System) SingleInstruction() (breakpoint bool, mCyclesCompleted uint, err error) {
+	mCyclesCompleted, err = s.stepInstruction()
+	if err != nil {
+		return false, mCyclesCompleted, err
+	}
 
-				if s.debugger.HasHitBreakpoint() {
-					return true, x, nil
-				}
+	return s.debugger.HasHitBreakpoint(), mCyclesCompleted, nil
+}
 
-			} else {
-				wasHalted = s.regs.GetHALT()
-				if wasHalted {
-					if s.interuptHandler.HasInterrupt() {
-						s.regs.SetHALT(false)
-						info.Name = "**UNHALT**"
-					} else {
-						info.Name = "**HALTED**"
-					}
-					mCyclesCompleted++
-					s.dump.appendExecutionHistory(&info)
-				} else {
-					// If handled an interrupt don't process any instructions this cycle
-					if interupted, name := s.interuptHandler.Update(s.cpu.GetOpcodePC()); interupted {
-						if err := s.cpu.DoInterruptCycle(); err != nil {
-							return false, mCyclesCompleted, err
-						}
-						mCyclesCompleted = handleInterruptMCycles
-						info.Name = interruptExecutionName + name
-						s.dump.appendExecutionHistory(&info)
-						s.screen.UpdateForCycles(mCyclesCompleted * cyclesPerMCycle)
-						x += mCyclesCompleted
-						s.dump.mCycle += mCyclesCompleted
-
-						if s.debugger.HasHitBreakpoint() {
-							return true, x, nil
-						}
-
-						continue
-					}
-				}
-			}
+// Steps until the CPU is between instructions, finishing the frame if it
+// fills up on the way
+func (s *System) stepInstruction() (mCyclesCompleted uint, err error) {
+	for {
+		mCycles, completed, err := s.step()
+		mCyclesCompleted += mCycles
 
-			s.screen.UpdateForCycles(mCyclesCompleted * cyclesPerMCycle)
+		if err != nil {
+			return mCyclesCompleted, err
 		}
 
-		if !didDMA && !wasHalted {
-			_, prevCompleted, info.Opcode, info.Name, err = s.cpu.ExecuteMCycle()
-
-			if err != nil {
-				return false, x, err
-			}
-
-			if prevCompleted {
-				s.dump.appendExecutionHistory(&info)
+		if s.frameCycles >= cyclesPerFrame {
+			if err := s.endFrame(); err != nil {
+				return mCyclesCompleted, err
 			}
 		}
 
-		s.timer.Update(uint8(mCyclesCompleted * cyclesPerMCycle))
-
-		x += mCyclesCompleted
-		s.dump.mCycle += mCyclesCompleted
-
-		if prevCompleted && s.debugger.HasHitBreakpoint() {
-			return true, x, nil
+		if completed {
+			return mCyclesCompleted, nil
 		}
 	}
-
-	return false, mCyclesCompleted, nil
 }
 
-func (s *System) SingleInstruction() (breakpoint bool, mCyclesCompleted uint, err error) {
-
-	mCyclesCompleted = 0
+// Runs a single M-cycle of the CPU, or the cycles it is held up for by HDMA,
+// HALT or an interrupt, with the hardware alongside. Every way of running the
+// system goes through here so running again from a save state with the same
+// input always does the same thing. Returns true when the CPU is between
+// instructions and breakpoints can be checked.
+func (s *System) step() (mCyclesCompleted uint, completed bool, err error) {
+	s.applyFrameInput()
+	s.applyMCycleInput()
+	s.applyReplayInput()
+	s.recordMovieInput()
+	s.recordRewindInput()
+
+	mCyclesCompleted = 1
+	cpuHeld := false
 	info := ExecutionInfo{
 		StartMCycle:    s.dump.mCycle,
 		ProgramCounter: s.cpu.GetOpcodePC(),
 		StartCPU:       *s.dump.getCPUStateOnly(),
 	}
-	s.debugger.StartCycle(s.dump.mCycle, info.ProgramCounter)
 
-	if s.memory.ExecuteDMAIfPending() {
-		mCyclesCompleted = dmaMCycles
-		info.Name = "**DMA**"
-	} else {
-		if s.regs.GetHALT() {
-			if s.interuptHandler.HasInterrupt() {
+	if s.cpu.IsBetweenInstructions() {
+		s.debugger.StartCycle(s.dump.mCycle, info.ProgramCounter)
+
+		// Nothing runs while stopped
+		if s.regs.GetSTOP() {
+			info.Name = s.stoppedCycle()
+			s.dump.appendExecutionHistory(&info)
+			s.advance(mCyclesCompleted)
+			return mCyclesCompleted, true, nil
+		}
+
+		if stall := s.bus.ExecuteHDMAIfPending(); stall > 0 {
+			info.Name = "**HDMA**"
+			mCyclesCompleted += stall
+			cpuHeld = true
+		} else if s.regs.GetHALT() {
+			if s.interuptHandler.IsPending() {
 				s.regs.SetHALT(false)
 				info.Name = "**UNHALT**"
 			} else {
 				info.Name = haltExecutionName
 			}
 			mCyclesCompleted++
-
-		} else {
+			s.dump.appendExecutionHistory(&info)
+			cpuHeld = true
+		} else if s.interuptHandler.Update(s.cpu.GetOpcodePC()) {
 			// If handled an interrupt don't process any instructions this cycle
-			if interupted, name := s.interuptHandler.Update(s.cpu.GetOpcodePC()); interupted {
-				if err := s.cpu.DoInterruptCycle(); err != nil {
-					return false, mCyclesCompleted, err
-				}
-
-				info.Name = interruptExecutionName + name
-				s.dump.appendExecutionHistory(&info)
-				mCyclesCompleted = handleInterruptMCycles
-				s.screen.UpdateForCycles(mCyclesCompleted * cyclesPerMCycle)
-
-				return s.debugger.HasHitBreakpoint(), mCyclesCompleted, nil
+			name, err := s.dispatchInterrupt()
+			if err != nil {
+				return mCyclesCompleted, false, err
 			}
+			mCyclesCompleted = handleInterruptMCycles
+			info.Name = interruptExecutionName + name
+			s.dump.appendExecutionHistory(&info)
+			s.advance(mCyclesCompleted)
+			return mCyclesCompleted, true, nil
+		}
+	}
 
-			var completed bool
-			for {
-				_, completed, info.Opcode, info.Name, err = s.cpu.ExecuteMCycle()
+	completed = cpuHeld
+	if !cpuHeld {
+		_, completed, info.Opcode, info.Name, err = s.cpu.ExecuteMCycle()
 
-				mCyclesCompleted++
+		if err != nil {
+			return mCyclesCompleted, false, err
+		}
 
-				if err != nil {
-					return false, mCyclesCompleted, errors.Join(errors.New("Tick incomplete"), err)
-				}
+		if completed {
+			s.dump.appendExecutionHistory(&info)
 
-				if completed {
-					break
-				}
+			if stall := s.handleSTOP(); stall > 0 {
+				mCyclesCompleted += stall
 			}
 		}
 	}
 
-	// Update timers
-	s.screen.UpdateForCycles(mCyclesCompleted * cyclesPerMCycle)
+	// The hardware, including OAM DMA, runs alongside every CPU M-cycle
+	s.updateHardware(mCyclesCompleted)
+	s.advance(mCyclesCompleted)
 
-	s.timer.Update(uint8(mCyclesCompleted * cyclesPerMCycle))
+	return mCyclesCompleted, completed, nil
+}
 
-	s.dump.appendExecutionHistory(&info)
+func (s *System) advance(mCycles uint) {
+	s.frameCycles += mCycles * s.displayCyclesPerMCycle()
+	s.dump.mCycle += mCycles
+}
 
-	s.dump.mCycle += mCyclesCompleted
+func (s *System) endFrame() error {
+	s.frameCycles = 0
+	s.flushBatteryRAMPeriodically()
+	s.frame++
 
-	return s.debugger.HasHitBreakpoint(), mCyclesCompleted, nil
+	if err := s.checkMovieFrame(); err != nil {
+		return err
+	}
+
+	return s.updateRewind()
+}
+
+// The hardware keeps running while the interrupt is dispatched so each M-cycle
+// is done separately
+func (s *System) dispatchInterrupt() (name string, err error) {
+	for {
+		completed, interruptName := s.interuptHandler.DispatchMCycle()
+		s.updateHardware(1)
+
+		if completed {
+			return interruptName, s.cpu.DoInterruptCycle()
+		}
+	}
+}
+
+func (s *System) updateHardware(mCycles uint) {
+	s.bus.UpdateDMA(mCycles)
+	s.screen.UpdateForCycles(mCycles * s.displayCyclesPerMCycle())
+	s.timer.Update(mCycles * cyclesPerMCycle)
+	s.apu.UpdateForCycles(mCycles * s.displayCyclesPerMCycle())
+	s.serial.UpdateForCycles(mCycles * cyclesPerMCycle)
+	if s.clocked != nil {
+		s.clocked.UpdateForCycles(mCycles * s.displayCyclesPerMCycle())
+	}
 }
 
 func (s *System) State() string {
@@ -355,13 +558,133 @@ func (s *System) Dump() Dump {
 }
 
 func (s *System) Debug() Debug {
-	return s.debugger
+	return &systemDebug{
+		Debugger: s.debugger,
+		system:   s,
+	}
 }
 
 func (s *System) Joypad() Joypad {
 	return s.controller
 }
 
+// Sets where the cartridge real time clock gets the wall clock time from when
+// catching up for time spent not running. Use a fixed clock for repeatable
+// tests, set before loading the game so it is used for the .sav file too.
+func (s *System) SetClockSource(clock memory.ClockSource) {
+	s.clock = clock
+	if s.rtc != nil {
+		s.rtc.SetClockSource(clock)
+	}
+}
+
+// Sets the picture the Game Boy Camera sees when it takes a photo
+func (s *System) SetCameraImageSource(source memory.ImageSource) {
+	s.imageSource = source
+	if s.camera != nil {
+		s.camera.SetImageSource(source)
+	}
+}
+
+// Called when the rumble motor on a rumble cartridge turns on or off
+func (s *System) SetRumbleCallback(callback func(on bool)) {
+	s.rumbleCallback = callback
+}
+
+// Frames run again from a rewind snapshot have already been seen and heard by
+// the host
+func (s *System) startReplay() {
+	s.replaying = true
+	s.apu.SetMuted(true)
+}
+
+func (s *System) endReplay() {
+	s.replaying = false
+	s.apu.SetMuted(false)
+	s.reportRumble()
+}
+
+func (s *System) rumble(on bool) {
+	s.rumbleOn = on
+	if !s.replaying {
+		s.reportRumble()
+	}
+}
+
+func (s *System) reportRumble() {
+	if s.rumbleOn == s.rumbleReported {
+		return
+	}
+
+	s.rumbleReported = s.rumbleOn
+	if s.rumbleCallback != nil {
+		s.rumbleCallback(s.rumbleOn)
+	}
+}
+
+func (s *System) Audio() Audio {
+	return s.apu
+}
+
+// Plugs the link cable into another system running in the same process
+func ConnectLink(first *System, second *System) {
+	first.serial.Disconnect()
+	second.serial.Disconnect()
+	serial.ConnectInProcess(first.serial, second.serial)
+}
+
+// Waits for another system to connect the link cable over TCP on localhost
+func (s *System) ListenLink(port int) error {
+	peer, err := serial.ListenTCP(port, s.serial)
+	if err != nil {
+		return err
+	}
+
+	peer.SetTimeout(s.linkTimeout)
+	s.SetLinkPeer(peer)
+	return nil
+}
+
+func (s *System) DialLink(port int) error {
+	peer, err := serial.DialTCP(port, s.serial)
+	if err != nil {
+		return err
+	}
+
+	peer.SetTimeout(s.linkTimeout)
+	s.SetLinkPeer(peer)
+	return nil
+}
+
+// How long a TCP link cable waits for the other end to reply. The emulator
+// stalls while waiting if the reply is late so keep it short for a fast
+// connection. Set before connecting.
+func (s *System) SetLinkTimeout(timeout time.Duration) {
+	s.linkTimeout = timeout
+}
+
+// Plugs the link cable into a custom transport. The transport should call
+// LinkReceive when the other end starts a transfer.
+func (s *System) SetLinkPeer(peer serial.LinkPeer) {
+	s.serial.Disconnect()
+	s.serial.Connect(peer)
+}
+
+func (s *System) LinkReceive(value uint8) uint8 {
+	return s.serial.Receive(value)
+}
+
+func (s *System) DisconnectLink() {
+	s.serial.Disconnect()
+}
+
 func (s *System) DisplayConfig() display.DisplayConfig {
-	return s.screen.DisplayConfig()
+	config := s.screen.DisplayConfig()
+	if s.sgb != nil {
+		config.Width = sgb.FrameWidth
+		config.Height = sgb.FrameHeight
+		config.Color = true
+	}
+
+	return config
 }
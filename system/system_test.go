@@ -0,0 +1,70 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/display"
+	"github.com/stretchr/testify/require"
+)
+
+const testBIOS = "../bios/dmg.bin"
+const testROM = "../rom/test/cpu_instrs/individual/01-special.gb"
+
+func createTestSystem(useDebugger bool) *System {
+	s := CreateSystem(testBIOS, testROM, DMG, display.PixelFIFO, useDebugger)
+	s.LoadTestROM(testROM)
+	return s
+}
+
+// Builds a ROM with no mapper that runs program from 0x0100, with code at
+// other addresses such as the interrupt vectors
+func createProgramSystem(t *testing.T, program []uint8, code map[uint16][]uint8) *System {
+	rom := make([]uint8, 0x8000)
+	copy(rom[0x0100:], program)
+	for address, data := range code {
+		copy(rom[address:], data)
+	}
+
+	path := filepath.Join(t.TempDir(), "program.gb")
+	require.NoError(t, os.WriteFile(path, rom, 0644))
+
+	s := CreateSystem(testBIOS, path, DMG, display.PixelFIFO, false)
+	s.LoadTestROM(path)
+	return s
+}
+
+func runFrames(s *System, frames int) error {
+	for x := 0; x < frames; x++ {
+		if _, _, err := s.SingleFrame(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// What the tests compare to check two systems are at the same point
+type systemSnapshot struct {
+	Frame  uint
+	CPU    CPUState
+	Timer  TimerState
+	Memory []uint8
+	Screen uint64
+}
+
+func takeSystemSnapshot(s *System) systemSnapshot {
+	memory := make([]uint8, 0x8000)
+	for x := range memory {
+		memory[x] = s.Dump().DumpMemoryValue(uint16(0x8000 + x))
+	}
+
+	return systemSnapshot{
+		Frame:  s.Frame(),
+		CPU:    *s.dump.getCPUStateOnly(),
+		Timer:  *s.Dump().GetTimerState(),
+		Memory: memory,
+		Screen: s.screenHash(),
+	}
+}
@@ -1,99 +1,208 @@
 package timer
 
 import (
+	"encoding/gob"
+
 	"github.com/f1gopher/gbpixellib/interupt"
 	"github.com/f1gopher/gbpixellib/memory"
 )
 
-type MemoryInterface interface {
-	ReadByte(address uint16) uint8
-	WriteByte(address uint16, value uint8)
-	WriteDividerRegister(value uint8)
-}
-
 type interruptInterface interface {
 	Request(i interupt.Interupt)
 }
 
+// DIV is the top 8 bits of a 16 bit counter that goes up every clock. TIMA
+// goes up when the counter bit selected by TAC changes from 1 to 0 so writing
+// DIV or TAC can make it go up too.
 type Timer struct {
-	mem      MemoryInterface
 	interupt interruptInterface
 
-	frequencyCounter int
+	counter uint16
+	tima    uint8
+	tma     uint8
+	tac     uint8
+
+	// TIMA reads 0 for an M-cycle after overflowing and is then reloaded
+	// from TMA
+	overflow bool
+	reloaded bool
 }
 
 const timerCounter = 0xFF05
 const timerModulo = 0xFF06
 const timerControl = 0xFF07
 
-func CreateTimer(mem MemoryInterface, interupt interruptInterface) *Timer {
+// Bits 3-7 of the control register are unused and always read as 1
+const controlReadMask = 0xF8
+
+// The timer always counts at the normal speed of the CPU
+const cyclesPerMCycle = 4
+
+func CreateTimer(interupt interruptInterface) *Timer {
 	return &Timer{
-		mem:              mem,
-		interupt:         interupt,
-		frequencyCounter: 0,
+		interupt: interupt,
 	}
 }
 
 func (t *Timer) Reset() {
-	t.frequencyCounter = 0
+	t.counter = 0
+	t.tima = 0
+	t.tma = 0
+	t.tac = 0
+	t.overflow = false
+	t.reloaded = false
+}
+
+// The full 16 bit counter, DIV is the top 8 bits
+func (t *Timer) SystemCounter() uint16 {
+	return t.counter
 }
 
-func (t *Timer) TimerDivideWrite() {
-	t.frequencyCounter = 0
-	control := t.mem.ReadByte(timerControl)
-	if memory.GetBit(control, 2) {
-		t.mem.WriteByte(timerCounter, t.mem.ReadByte(timerCounter)+1)
+func (t *Timer) Update(cycles uint) {
+	for x := uint(0); x < cycles; x += cyclesPerMCycle {
+		t.reloaded = false
+		if t.overflow {
+			t.overflow = false
+			t.reloaded = true
+			t.tima = t.tma
+			t.interupt.Request(interupt.Time)
+		}
+
+		t.setCounter(t.counter + cyclesPerMCycle)
 	}
-	t.mem.WriteDividerRegister(0)
-}
-
-func (t *Timer) Update(cycles uint8) {
-	// Divider Register
-	current := t.mem.ReadByte(memory.DividerRegister)
-	current += cycles / 2
-	t.mem.WriteDividerRegister(current)
-
-	// Timer Counter
-	control := t.mem.ReadByte(timerControl)
-
-	frequency := 0
-	switch control & 0x3 {
-	case 0b00:
-		frequency = 1024
-	case 0b01:
-		frequency = 16
-	case 0b10:
-		frequency = 64
-	case 0b11:
-		frequency = 256
+}
+
+// The counter bit checked for each TAC frequency
+//
+// 00 4096Hz bit 9
+// 01 262144Hz bit 3
+// 10 65536Hz bit 5
+// 11 16384Hz bit 7
+func (t *Timer) signal(counter uint16, control uint8) bool {
+	if !memory.GetBit(control, 2) {
+		return false
 	}
 
-	counter := t.mem.ReadByte(timerCounter)
-	enabled := memory.GetBit(control, 2)
+	bit := [...]int{9, 3, 5, 7}[control&0x03]
+	return memory.GetBitForShort(counter, bit)
+}
 
-	var x uint8
-	for x = 0; x < cycles; x++ {
+func (t *Timer) setCounter(value uint16) {
+	before := t.signal(t.counter, t.tac)
+	t.counter = value
+	if before && !t.signal(t.counter, t.tac) {
+		t.increment()
+	}
+}
 
-		t.frequencyCounter++
-		if t.frequencyCounter < frequency {
-			continue
-		}
-		t.frequencyCounter = 0
+func (t *Timer) setControl(value uint8) {
+	before := t.signal(t.counter, t.tac)
+	t.tac = value & 0x07
+	if before && !t.signal(t.counter, t.tac) {
+		t.increment()
+	}
+}
 
-		// Increment if enabled
-		if enabled {
-			var newValue uint8
+func (t *Timer) increment() {
+	t.tima++
+	if t.tima == 0x00 {
+		t.overflow = true
+	}
+}
 
-			if uint16(counter)+1 > 0x00FF {
-				newValue = t.mem.ReadByte(timerModulo)
+func (t *Timer) ReadBit(address uint16, bit uint8) bool {
+	return (t.ReadByte(address)>>bit)&0x01 == 0x01
+}
+
+func (t *Timer) ReadByte(address uint16) byte {
+	switch address {
+	case memory.DividerRegister:
+		return uint8(t.counter >> 8)
+	case timerCounter:
+		return t.tima
+	case timerModulo:
+		return t.tma
+	default:
+		return t.tac | controlReadMask
+	}
+}
+
+func (t *Timer) ReadShort(address uint16) uint16 {
+	lsb := t.ReadByte(address)
+	msb := t.ReadByte(address + 1)
+	return uint16(msb)<<8 | uint16(lsb)
+}
 
-				// Request interrupt
-				t.interupt.Request(interupt.Time)
-			} else {
-				newValue = counter + 1
-			}
+func (t *Timer) WriteBit(address uint16, bit uint8, value bool) {
+	current := t.ReadByte(address)
+	if value {
+		current = current | 0x01<<bit
+	} else {
+		current = current &^ (0x01 << bit)
+	}
+	t.WriteByte(address, current)
+}
+
+func (t *Timer) WriteShort(address uint16, value uint16) {
+	t.WriteByte(address, uint8(value))
+	t.WriteByte(address+1, uint8(value>>8))
+}
 
-			t.mem.WriteByte(timerCounter, newValue)
+func (t *Timer) WriteByte(address uint16, value byte) {
+	switch address {
+	case memory.DividerRegister:
+		// Any write clears the whole counter
+		t.setCounter(0)
+	case timerCounter:
+		// Writing while TIMA reads 0 cancels the reload and the interrupt.
+		// Writing on the cycle it is reloaded is ignored.
+		if t.reloaded {
+			return
 		}
+		t.overflow = false
+		t.tima = value
+	case timerModulo:
+		// Writing on the cycle TIMA is reloaded also changes TIMA
+		t.tma = value
+		if t.reloaded {
+			t.tima = value
+		}
+	default:
+		t.setControl(value)
 	}
 }
+
+type timerState struct {
+	Counter  uint16
+	TIMA     uint8
+	TMA      uint8
+	TAC      uint8
+	Overflow bool
+	Reloaded bool
+}
+
+func (t *Timer) SaveState(enc *gob.Encoder) error {
+	return enc.Encode(timerState{
+		Counter:  t.counter,
+		TIMA:     t.tima,
+		TMA:      t.tma,
+		TAC:      t.tac,
+		Overflow: t.overflow,
+		Reloaded: t.reloaded,
+	})
+}
+
+func (t *Timer) LoadState(dec *gob.Decoder) error {
+	var state timerState
+	if err := dec.Decode(&state); err != nil {
+		return err
+	}
+
+	t.counter = state.Counter
+	t.tima = state.TIMA
+	t.tma = state.TMA
+	t.tac = state.TAC
+	t.overflow = state.Overflow
+	t.reloaded = state.Reloaded
+	return nil
+}
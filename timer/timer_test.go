@@ -0,0 +1,150 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/f1gopher/gbpixellib/interupt"
+	"github.com/f1gopher/gbpixellib/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+type interruptRecorder struct {
+	requested []interupt.Interupt
+}
+
+func (i *interruptRecorder) Request(value interupt.Interupt) {
+	i.requested = append(i.requested, value)
+}
+
+func TestDividerIsTopOfCounter(t *testing.T) {
+	timer := CreateTimer(&interruptRecorder{})
+
+	timer.Update(252)
+	timer.Update(4)
+	assert.Equal(t, uint16(0x0100), timer.SystemCounter())
+	assert.Equal(t, uint8(0x01), timer.ReadByte(memory.DividerRegister))
+
+	timer.WriteByte(memory.DividerRegister, 0x55)
+	assert.Equal(t, uint16(0x0000), timer.SystemCounter())
+}
+
+func TestCountsOnFallingEdge(t *testing.T) {
+	timer := CreateTimer(&interruptRecorder{})
+
+	// Bit 3 so TIMA goes up every 16 clocks
+	timer.WriteByte(timerControl, 0x05)
+	timer.Update(12)
+	assert.Equal(t, uint8(0), timer.ReadByte(timerCounter))
+	timer.Update(4)
+	assert.Equal(t, uint8(1), timer.ReadByte(timerCounter))
+}
+
+func TestDividerWriteGlitch(t *testing.T) {
+	timer := CreateTimer(&interruptRecorder{})
+
+	timer.WriteByte(timerControl, 0x05)
+	timer.Update(8)
+
+	// Bit 3 is set so clearing the counter is a falling edge
+	timer.WriteByte(memory.DividerRegister, 0x00)
+	assert.Equal(t, uint8(1), timer.ReadByte(timerCounter))
+}
+
+func TestControlWriteGlitch(t *testing.T) {
+	timer := CreateTimer(&interruptRecorder{})
+
+	timer.WriteByte(timerControl, 0x05)
+	timer.Update(8)
+
+	// Disabling the timer while the bit is set is a falling edge
+	timer.WriteByte(timerControl, 0x01)
+	assert.Equal(t, uint8(1), timer.ReadByte(timerCounter))
+	assert.Equal(t, uint8(0xF9), timer.ReadByte(timerControl))
+}
+
+func overflow(timer *Timer) {
+	timer.WriteByte(timerModulo, 0x80)
+	timer.WriteByte(timerCounter, 0xFF)
+	timer.WriteByte(timerControl, 0x05)
+	timer.Update(16)
+}
+
+func TestReloadIsDelayed(t *testing.T) {
+	interrupts := &interruptRecorder{}
+	timer := CreateTimer(interrupts)
+
+	overflow(timer)
+	assert.Equal(t, uint8(0x00), timer.ReadByte(timerCounter))
+	assert.Empty(t, interrupts.requested)
+
+	timer.Update(4)
+	assert.Equal(t, uint8(0x80), timer.ReadByte(timerCounter))
+	assert.Equal(t, []interupt.Interupt{interupt.Time}, interrupts.requested)
+}
+
+func TestWriteBeforeReloadCancelsIt(t *testing.T) {
+	interrupts := &interruptRecorder{}
+	timer := CreateTimer(interrupts)
+
+	overflow(timer)
+	timer.WriteByte(timerCounter, 0x10)
+	timer.Update(4)
+
+	assert.Equal(t, uint8(0x10), timer.ReadByte(timerCounter))
+	assert.Empty(t, interrupts.requested)
+}
+
+func TestWritesDuringReload(t *testing.T) {
+	timer := CreateTimer(&interruptRecorder{})
+
+	overflow(timer)
+	timer.Update(4)
+
+	// TIMA writes are ignored and TMA writes go to TIMA as well
+	timer.WriteByte(timerCounter, 0x10)
+	assert.Equal(t, uint8(0x80), timer.ReadByte(timerCounter))
+	timer.WriteByte(timerModulo, 0x20)
+	assert.Equal(t, uint8(0x20), timer.ReadByte(timerCounter))
+
+	timer.Update(4)
+	timer.WriteByte(timerCounter, 0x10)
+	assert.Equal(t, uint8(0x10), timer.ReadByte(timerCounter))
+}
+
+func TestUpdateMoreThan255Cycles(t *testing.T) {
+	timer := CreateTimer(&interruptRecorder{})
+
+	// Bit 9 so TIMA goes up every 1024 clocks. A CGB speed switch stalls
+	// for 2050 M-cycles.
+	timer.WriteByte(timerControl, 0x04)
+	timer.Update(2050 * 4)
+
+	assert.Equal(t, uint16(0x2008), timer.SystemCounter())
+	assert.Equal(t, uint8(0x20), timer.ReadByte(memory.DividerRegister))
+	assert.Equal(t, uint8(8), timer.ReadByte(timerCounter))
+}
+
+func TestUpdateInOneGoMatchesSteps(t *testing.T) {
+	interrupts := &interruptRecorder{}
+	timer := CreateTimer(interrupts)
+	stepInterrupts := &interruptRecorder{}
+	stepped := CreateTimer(stepInterrupts)
+
+	// Overflows TIMA more than once
+	for _, x := range []*Timer{timer, stepped} {
+		x.WriteByte(timerModulo, 0xF0)
+		x.WriteByte(timerCounter, 0xF0)
+		x.WriteByte(timerControl, 0x05)
+	}
+
+	timer.Update(1000)
+	for x := 0; x < 1000; x += 4 {
+		stepped.Update(4)
+	}
+
+	assert.Equal(t, stepped.SystemCounter(), timer.SystemCounter())
+	assert.Equal(t, stepped.ReadByte(memory.DividerRegister), timer.ReadByte(memory.DividerRegister))
+	assert.Equal(t, stepped.ReadByte(timerCounter), timer.ReadByte(timerCounter))
+	assert.Len(t, interrupts.requested, 3)
+	assert.Equal(t, stepInterrupts.requested, interrupts.requested)
+}